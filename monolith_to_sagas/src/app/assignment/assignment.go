@@ -0,0 +1,191 @@
+package assignment
+
+import (
+	"automation/app/configuration"
+	"automation/app/files"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-kit/kit/log"
+)
+
+type AssignmentHandler interface {
+	AssignOrchestrators(*files.Decomposition, []*configuration.FunctionalityResult, int) (*AssignmentReport, error)
+}
+
+type DefaultHandler struct {
+	logger log.Logger
+}
+
+func New(logger log.Logger) AssignmentHandler {
+	return &DefaultHandler{
+		logger: log.With(logger, "module", "assignmentHandler"),
+	}
+}
+
+// AssignmentReport compares the orchestrators chosen for the functionalities of a decomposition
+// together, with a maximum of functionalities orchestrated by each cluster, with the ones chosen
+// independently for each functionality. The complexity of a redesign is the sum of its
+// functionality and system complexities.
+type AssignmentReport struct {
+	Decomposition               string
+	MaxOrchestrationsPerCluster int
+	Functionalities             []*OrchestratorAssignment
+	TotalComplexity             int
+	IndependentTotalComplexity  int
+	ClusterLoads                map[int]int
+	IndependentClusterLoads     map[int]int
+}
+
+type OrchestratorAssignment struct {
+	Functionality             string
+	OrchestratorID            int
+	Complexity                int
+	IndependentOrchestratorID int
+	IndependentComplexity     int
+	Redesign                  *files.FunctionalityRedesign
+}
+
+// AssignOrchestrators chooses a saga redesign for each functionality of the decomposition so the
+// sum of their complexities is minimal and no cluster orchestrates more than the given number of
+// functionalities, where zero does not limit them. The complexities of each candidate redesign do
+// not depend on the orchestrators of the other functionalities, so the assignment is a minimum
+// cost flow from the functionalities to the clusters, whose capacity is the maximum.
+func (svc *DefaultHandler) AssignOrchestrators(
+	decomposition *files.Decomposition, functionalities []*configuration.FunctionalityResult, maxOrchestrationsPerCluster int,
+) (*AssignmentReport, error) {
+	report := &AssignmentReport{
+		Decomposition:               decomposition.Name,
+		MaxOrchestrationsPerCluster: maxOrchestrationsPerCluster,
+		Functionalities:             []*OrchestratorAssignment{},
+		ClusterLoads:                map[int]int{},
+		IndependentClusterLoads:     map[int]int{},
+	}
+
+	decompositionFunctionalities := []*configuration.FunctionalityResult{}
+	for _, functionality := range functionalities {
+		if functionality.Decomposition == decomposition && len(functionality.SagaRedesigns) > 0 {
+			decompositionFunctionalities = append(decompositionFunctionalities, functionality)
+		}
+	}
+	sort.SliceStable(decompositionFunctionalities, func(i, j int) bool {
+		return decompositionFunctionalities[i].Controller.Name < decompositionFunctionalities[j].Controller.Name
+	})
+
+	clusterIDs := []int{}
+	for clusterName := range decomposition.Clusters {
+		clusterID, _ := strconv.Atoi(clusterName)
+		clusterIDs = append(clusterIDs, clusterID)
+	}
+	sort.Ints(clusterIDs)
+
+	clusterNodes := map[int]int{}
+	for idx, clusterID := range clusterIDs {
+		clusterNodes[clusterID] = 1 + len(decompositionFunctionalities) + idx
+	}
+
+	capacity := maxOrchestrationsPerCluster
+	if capacity <= 0 {
+		capacity = len(decompositionFunctionalities)
+	}
+
+	source := 0
+	sink := 1 + len(decompositionFunctionalities) + len(clusterIDs)
+	network := newFlowNetwork(sink + 1)
+
+	// the costs are scaled so the ties are broken by the ranking of the redesigns of each
+	// functionality, which keeps its independent choice when the clusters are not full
+	scale := 1
+	for _, functionality := range decompositionFunctionalities {
+		if len(functionality.SagaRedesigns)+1 > scale {
+			scale = len(functionality.SagaRedesigns) + 1
+		}
+	}
+
+	candidateEdges := make([]map[int]*flowEdge, len(decompositionFunctionalities))
+	for idx, functionality := range decompositionFunctionalities {
+		node := 1 + idx
+		network.addEdge(source, node, 1, 0)
+
+		candidateEdges[idx] = map[int]*flowEdge{}
+		for rank, redesign := range functionality.SagaRedesigns {
+			clusterNode, found := clusterNodes[redesign.OrchestratorID]
+			if !found {
+				continue
+			}
+			candidateEdges[idx][rank] = network.addEdge(node, clusterNode, 1, complexity(redesign)*scale+rank)
+		}
+	}
+
+	for _, clusterID := range clusterIDs {
+		network.addEdge(clusterNodes[clusterID], sink, capacity, 0)
+	}
+
+	if flow := network.minCostFlow(source, sink); flow < len(decompositionFunctionalities) {
+		err := fmt.Errorf(
+			"the %d functionalities of decomposition %s cannot be orchestrated by at most %d functionalities per cluster",
+			len(decompositionFunctionalities), decomposition.Name, maxOrchestrationsPerCluster,
+		)
+		svc.logger.Log(err)
+		return nil, err
+	}
+
+	for idx, functionality := range decompositionFunctionalities {
+		independentRedesign := functionality.GetBestRedesign()
+		assignment := &OrchestratorAssignment{
+			Functionality:             functionality.Controller.Name,
+			IndependentOrchestratorID: independentRedesign.OrchestratorID,
+			IndependentComplexity:     complexity(independentRedesign),
+		}
+
+		for rank, edge := range candidateEdges[idx] {
+			if edge.flow > 0 {
+				assignment.Redesign = functionality.SagaRedesigns[rank]
+				assignment.OrchestratorID = assignment.Redesign.OrchestratorID
+				assignment.Complexity = complexity(assignment.Redesign)
+			}
+		}
+
+		report.Functionalities = append(report.Functionalities, assignment)
+		report.TotalComplexity += assignment.Complexity
+		report.IndependentTotalComplexity += assignment.IndependentComplexity
+		report.ClusterLoads[assignment.OrchestratorID]++
+		report.IndependentClusterLoads[assignment.IndependentOrchestratorID]++
+	}
+
+	return report, nil
+}
+
+func complexity(redesign *files.FunctionalityRedesign) int {
+	return redesign.FunctionalityComplexity + redesign.SystemComplexity
+}
+
+func (r *AssignmentReport) Dataset() [][]string {
+	data := [][]string{{
+		"Decomposition",
+		"Feature",
+		"Independent Orchestrator",
+		"Independent Complexity",
+		"Independent Orchestrator Load",
+		"Assigned Orchestrator",
+		"Assigned Complexity",
+		"Assigned Orchestrator Load",
+		"Complexity Difference",
+	}}
+
+	for _, assignment := range r.Functionalities {
+		data = append(data, []string{
+			r.Decomposition,
+			assignment.Functionality,
+			strconv.Itoa(assignment.IndependentOrchestratorID),
+			strconv.Itoa(assignment.IndependentComplexity),
+			strconv.Itoa(r.IndependentClusterLoads[assignment.IndependentOrchestratorID]),
+			strconv.Itoa(assignment.OrchestratorID),
+			strconv.Itoa(assignment.Complexity),
+			strconv.Itoa(r.ClusterLoads[assignment.OrchestratorID]),
+			strconv.Itoa(assignment.Complexity - assignment.IndependentComplexity),
+		})
+	}
+	return data
+}
@@ -0,0 +1,117 @@
+package assignment_test
+
+import (
+	"automation/app/assignment"
+	"automation/app/common/log"
+	"automation/app/configuration"
+	"automation/app/files"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignOrchestrators(t *testing.T) {
+	handler := assignment.New(log.NewNopLogger())
+	decomposition := &files.Decomposition{
+		Name: "Decomposition",
+		Clusters: map[string]*files.Cluster{
+			"0": {Name: "0"},
+			"1": {Name: "1"},
+			"2": {Name: "2"},
+		},
+	}
+
+	// the pairs of orchestrator and complexity of the saga redesigns of a functionality, ordered
+	// from the best to the worst as the redesign handler does
+	type candidates struct {
+		functionality string
+		redesigns     [][2]int
+	}
+
+	cases := []struct {
+		name                       string
+		candidates                 []candidates
+		maxLoad                    int
+		orchestrators              map[string]int
+		clusterLoads               map[int]int
+		totalComplexity            int
+		independentTotalComplexity int
+		fails                      bool
+	}{
+		{
+			"independent choices without a limit",
+			[]candidates{
+				{"First", [][2]int{{0, 2}, {1, 5}}},
+				{"Second", [][2]int{{0, 3}, {2, 3}}},
+			},
+			0,
+			map[string]int{"First": 0, "Second": 0},
+			map[int]int{0: 2},
+			5,
+			5,
+			false,
+		},
+		{
+			// moving Second costs 1 and Third 3, while moving First costs 4
+			"cluster capacity",
+			[]candidates{
+				{"First", [][2]int{{0, 2}, {1, 6}}},
+				{"Second", [][2]int{{0, 2}, {2, 3}}},
+				{"Third", [][2]int{{0, 1}, {1, 4}, {2, 9}}},
+			},
+			1,
+			map[string]int{"First": 0, "Second": 2, "Third": 1},
+			map[int]int{0: 1, 1: 1, 2: 1},
+			9,
+			5,
+			false,
+		},
+		{
+			"full clusters",
+			[]candidates{
+				{"First", [][2]int{{0, 1}}},
+				{"Second", [][2]int{{0, 1}}},
+			},
+			1,
+			nil,
+			nil,
+			0,
+			0,
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		functionalities := []*configuration.FunctionalityResult{}
+		for _, candidate := range c.candidates {
+			functionality := &configuration.FunctionalityResult{
+				Decomposition: decomposition,
+				Controller:    &files.Controller{Name: candidate.functionality},
+			}
+			for _, redesign := range candidate.redesigns {
+				functionality.SagaRedesigns = append(functionality.SagaRedesigns, &files.FunctionalityRedesign{
+					Name:                    candidate.functionality,
+					OrchestratorID:          redesign[0],
+					FunctionalityComplexity: redesign[1],
+				})
+			}
+			functionalities = append(functionalities, functionality)
+		}
+
+		report, err := handler.AssignOrchestrators(decomposition, functionalities, c.maxLoad)
+		if c.fails {
+			assert.NotNil(t, err, c.name)
+			continue
+		}
+		assert.Nil(t, err, c.name)
+
+		orchestrators := map[string]int{}
+		for _, assigned := range report.Functionalities {
+			orchestrators[assigned.Functionality] = assigned.OrchestratorID
+		}
+		assert.Equal(t, c.orchestrators, orchestrators, c.name)
+		assert.Equal(t, c.clusterLoads, report.ClusterLoads, c.name)
+		assert.Equal(t, c.totalComplexity, report.TotalComplexity, c.name)
+		assert.Equal(t, c.independentTotalComplexity, report.IndependentTotalComplexity, c.name)
+	}
+}
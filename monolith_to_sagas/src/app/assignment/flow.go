@@ -0,0 +1,84 @@
+package assignment
+
+import "math"
+
+type flowEdge struct {
+	to       int
+	capacity int
+	cost     int
+	flow     int
+	reverse  *flowEdge
+}
+
+func (e *flowEdge) residual() int {
+	return e.capacity - e.flow
+}
+
+type flowNetwork struct {
+	edges [][]*flowEdge
+}
+
+func newFlowNetwork(nodesCount int) *flowNetwork {
+	return &flowNetwork{edges: make([][]*flowEdge, nodesCount)}
+}
+
+// addEdge adds the edge and its residual reverse edge, returning the first one so its flow can
+// be read after the flow is calculated
+func (n *flowNetwork) addEdge(from int, to int, capacity int, cost int) *flowEdge {
+	edge := &flowEdge{to: to, capacity: capacity, cost: cost}
+	reverse := &flowEdge{to: from, cost: -cost, reverse: edge}
+	edge.reverse = reverse
+
+	n.edges[from] = append(n.edges[from], edge)
+	n.edges[to] = append(n.edges[to], reverse)
+	return edge
+}
+
+// minCostFlow sends as much flow as possible from the source to the sink, always through the
+// cheapest path of the residual network, found by Bellman-Ford since the reverse edges have
+// negative costs, and returns the flow sent
+func (n *flowNetwork) minCostFlow(source int, sink int) int {
+	var totalFlow int
+
+	for {
+		distances := make([]int, len(n.edges))
+		previous := make([]*flowEdge, len(n.edges))
+		for node := range distances {
+			distances[node] = math.MaxInt32
+		}
+		distances[source] = 0
+
+		for updated := true; updated; {
+			updated = false
+			for node, edges := range n.edges {
+				if distances[node] == math.MaxInt32 {
+					continue
+				}
+				for _, edge := range edges {
+					if edge.residual() > 0 && distances[node]+edge.cost < distances[edge.to] {
+						distances[edge.to] = distances[node] + edge.cost
+						previous[edge.to] = edge
+						updated = true
+					}
+				}
+			}
+		}
+
+		if distances[sink] == math.MaxInt32 {
+			return totalFlow
+		}
+
+		pathFlow := math.MaxInt32
+		for node := sink; node != source; node = previous[node].reverse.to {
+			if previous[node].residual() < pathFlow {
+				pathFlow = previous[node].residual()
+			}
+		}
+
+		for node := sink; node != source; node = previous[node].reverse.to {
+			previous[node].flow += pathFlow
+			previous[node].reverse.flow -= pathFlow
+		}
+		totalFlow += pathFlow
+	}
+}
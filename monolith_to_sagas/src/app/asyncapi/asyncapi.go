@@ -0,0 +1,337 @@
+package asyncapi
+
+import (
+	"automation/app/common/names"
+	"automation/app/configuration"
+	"automation/app/files"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	asyncAPIVersion   = "2.6.0"
+	contractVersion   = "1.0.0"
+	jsonContentType   = "application/json"
+	sagaIDField       = "sagaId"
+	statusField       = "status"
+	sagaIDLocation    = "$message.payload#/" + sagaIDField
+	schemasReference  = "#/components/schemas/"
+	messagesReference = "#/components/messages/"
+)
+
+type AsyncAPIHandler interface {
+	GenerateDocument(string, []*configuration.FunctionalityResult, map[string]string) *Document
+}
+
+type DefaultHandler struct {
+	logger log.Logger
+}
+
+func New(logger log.Logger) AsyncAPIHandler {
+	return &DefaultHandler{
+		logger: log.With(logger, "module", "asyncAPIHandler"),
+	}
+}
+
+type Document struct {
+	AsyncAPI           string              `json:"asyncapi"`
+	Info               *Info               `json:"info"`
+	DefaultContentType string              `json:"defaultContentType"`
+	Channels           map[string]*Channel `json:"channels"`
+	Components         *Components         `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Channel struct {
+	Description string     `json:"description,omitempty"`
+	Publish     *Operation `json:"publish,omitempty"`
+	Subscribe   *Operation `json:"subscribe,omitempty"`
+}
+
+type Operation struct {
+	OperationID string            `json:"operationId"`
+	Summary     string            `json:"summary,omitempty"`
+	Message     *OperationMessage `json:"message"`
+}
+
+type OperationMessage struct {
+	OneOf []*Reference `json:"oneOf"`
+}
+
+type Reference struct {
+	Ref string `json:"$ref"`
+}
+
+type Components struct {
+	Messages map[string]*Message `json:"messages"`
+	Schemas  map[string]*Schema  `json:"schemas"`
+}
+
+type Message struct {
+	Name          string         `json:"name"`
+	Title         string         `json:"title,omitempty"`
+	Summary       string         `json:"summary,omitempty"`
+	ContentType   string         `json:"contentType,omitempty"`
+	CorrelationID *CorrelationID `json:"correlationId,omitempty"`
+	Payload       *Schema        `json:"payload"`
+}
+
+type CorrelationID struct {
+	Description string `json:"description,omitempty"`
+	Location    string `json:"location"`
+}
+
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+}
+
+// participantChannels keeps the messages a participant receives on its command channel and
+// sends on its reply channel
+type participantChannels struct {
+	Cluster  string
+	Commands []*Reference
+	Replies  []*Reference
+}
+
+// GenerateDocument builds the messaging contract of the codebase from the best redesign of each
+// functionality. Every invocation the orchestrator makes to another cluster is a command sent
+// to the command channel of that cluster, answered on its reply channel, and compensatable
+// steps have an additional compensation command and reply. The payloads carry the identifiers
+// of the entities accessed, the new state of the written entities in the command and the state
+// of the read entities in the reply.
+func (svc *DefaultHandler) GenerateDocument(codebaseName string, functionalities []*configuration.FunctionalityResult, idToEntityMap map[string]string) *Document {
+	document := &Document{
+		AsyncAPI: asyncAPIVersion,
+		Info: &Info{
+			Title:       fmt.Sprintf("%s sagas", codebaseName),
+			Version:     contractVersion,
+			Description: fmt.Sprintf("Command and reply messages exchanged by the saga orchestrators of %s", codebaseName),
+		},
+		DefaultContentType: jsonContentType,
+		Channels:           map[string]*Channel{},
+		Components: &Components{
+			Messages: map[string]*Message{},
+			Schemas:  map[string]*Schema{},
+		},
+	}
+
+	sortedFunctionalities := make([]*configuration.FunctionalityResult, len(functionalities))
+	copy(sortedFunctionalities, functionalities)
+	sort.SliceStable(sortedFunctionalities, func(i, j int) bool {
+		if sortedFunctionalities[i].Decomposition.Name != sortedFunctionalities[j].Decomposition.Name {
+			return sortedFunctionalities[i].Decomposition.Name < sortedFunctionalities[j].Decomposition.Name
+		}
+		return sortedFunctionalities[i].Controller.Name < sortedFunctionalities[j].Controller.Name
+	})
+
+	channels := map[string]*participantChannels{}
+	channelNames := []string{}
+	getChannels := func(decomposition *files.Decomposition, clusterID int) *participantChannels {
+		channelName := clusterChannelName(decomposition, clusterID)
+		participant, found := channels[channelName]
+		if !found {
+			participant = &participantChannels{Cluster: strconv.Itoa(clusterID)}
+			channels[channelName] = participant
+			channelNames = append(channelNames, channelName)
+		}
+		return participant
+	}
+
+	for _, functionality := range sortedFunctionalities {
+		redesign := functionality.GetBestRedesign()
+		if redesign == nil || redesign.Choreography {
+			continue
+		}
+
+		var stepIdx int
+		for idx, invocation := range redesign.Redesign {
+			if invocation.ClusterID == -1 || len(invocation.ClusterAccesses) == 0 {
+				continue
+			}
+			stepIdx++
+
+			if invocation.ClusterID == redesign.OrchestratorID {
+				continue
+			}
+
+			prefix := svc.messagePrefix(document, functionality, stepIdx-1)
+			participant := getChannels(functionality.Decomposition, invocation.ClusterID)
+			readEntities, writtenEntities := svc.accessedEntities(document, invocation, idToEntityMap)
+			stepDescription := fmt.Sprintf(
+				"step %d of %s, a %s invocation of cluster %d",
+				stepIdx-1, functionality.Controller.Name, strings.ToLower(redesign.GetStepType(idx)), invocation.ClusterID,
+			)
+
+			participant.Commands = append(participant.Commands, svc.addMessage(
+				document, prefix+"Command", "Executes "+stepDescription, commandPayload(readEntities, writtenEntities),
+			))
+			participant.Replies = append(participant.Replies, svc.addMessage(
+				document, prefix+"Reply", "Result of "+stepDescription, replyPayload(readEntities),
+			))
+
+			if redesign.GetStepType(idx) == "COMPENSATABLE" {
+				participant.Commands = append(participant.Commands, svc.addMessage(
+					document, prefix+"CompensationCommand", "Compensates "+stepDescription, commandPayload([]string{}, writtenEntities),
+				))
+				participant.Replies = append(participant.Replies, svc.addMessage(
+					document, prefix+"CompensationReply", "Result of the compensation of "+stepDescription, replyPayload([]string{}),
+				))
+			}
+		}
+	}
+
+	for _, channelName := range channelNames {
+		participant := channels[channelName]
+		operationPrefix := names.Identifier(channelName)
+
+		document.Channels[channelName+"/commands"] = &Channel{
+			Description: fmt.Sprintf("Commands sent by the orchestrators to cluster %s", participant.Cluster),
+			Publish: &Operation{
+				OperationID: names.LowerFirst(operationPrefix) + "ReceiveCommand",
+				Summary:     fmt.Sprintf("Cluster %s executes a saga step", participant.Cluster),
+				Message:     &OperationMessage{OneOf: participant.Commands},
+			},
+		}
+
+		document.Channels[channelName+"/replies"] = &Channel{
+			Description: fmt.Sprintf("Replies sent by cluster %s to the orchestrators", participant.Cluster),
+			Subscribe: &Operation{
+				OperationID: names.LowerFirst(operationPrefix) + "SendReply",
+				Summary:     fmt.Sprintf("Cluster %s reports the result of a saga step", participant.Cluster),
+				Message:     &OperationMessage{OneOf: participant.Replies},
+			},
+		}
+	}
+
+	return document
+}
+
+// messagePrefix names the messages of a step after its functionality, qualified with the
+// decomposition when the functionality was already redesigned in another decomposition
+func (svc *DefaultHandler) messagePrefix(document *Document, functionality *configuration.FunctionalityResult, stepIdx int) string {
+	prefix := fmt.Sprintf("%sStep%d", names.Identifier(functionality.Controller.Name), stepIdx)
+	if _, exists := document.Components.Messages[prefix+"Command"]; exists {
+		prefix = names.Identifier(functionality.Decomposition.Name) + prefix
+	}
+	return prefix
+}
+
+func (svc *DefaultHandler) addMessage(document *Document, name string, summary string, payload *Schema) *Reference {
+	document.Components.Messages[name] = &Message{
+		Name:        name,
+		Title:       name,
+		Summary:     summary,
+		ContentType: jsonContentType,
+		CorrelationID: &CorrelationID{
+			Description: "Identifier of the saga instance",
+			Location:    sagaIDLocation,
+		},
+		Payload: payload,
+	}
+	return &Reference{Ref: messagesReference + name}
+}
+
+// accessedEntities returns the names of the entities only read and of the entities written by
+// the invocation, registering the schema of each entity in the document
+func (svc *DefaultHandler) accessedEntities(document *Document, invocation *files.Invocation, idToEntityMap map[string]string) ([]string, []string) {
+	accessTypes := map[string]string{}
+	entityNames := []string{}
+	for accessIdx := range invocation.ClusterAccesses {
+		name := names.EntityName(invocation.GetAccessEntityID(accessIdx), idToEntityMap)
+		accessType, found := accessTypes[name]
+		if !found {
+			entityNames = append(entityNames, name)
+		}
+
+		if accessType != "" && accessType != invocation.GetAccessType(accessIdx) {
+			accessTypes[name] = "RW"
+		} else {
+			accessTypes[name] = invocation.GetAccessType(accessIdx)
+		}
+
+		if _, exists := document.Components.Schemas[name]; !exists {
+			document.Components.Schemas[name] = &Schema{
+				Type:        "object",
+				Description: fmt.Sprintf("State of the %s entity", name),
+			}
+		}
+	}
+
+	readEntities := []string{}
+	writtenEntities := []string{}
+	for _, name := range entityNames {
+		if accessTypes[name] == "R" {
+			readEntities = append(readEntities, name)
+			continue
+		}
+		writtenEntities = append(writtenEntities, name)
+	}
+	return readEntities, writtenEntities
+}
+
+func commandPayload(readEntities []string, writtenEntities []string) *Schema {
+	payload := newPayload()
+	for _, name := range readEntities {
+		payload.Properties[names.LowerFirst(names.Identifier(name))+"Id"] = &Schema{
+			Type:        "string",
+			Description: fmt.Sprintf("Identifier of the %s to read", name),
+		}
+	}
+	for _, name := range writtenEntities {
+		payload.Properties[names.LowerFirst(names.Identifier(name))+"Id"] = &Schema{
+			Type:        "string",
+			Description: fmt.Sprintf("Identifier of the %s to write", name),
+		}
+		payload.Properties[names.LowerFirst(names.Identifier(name))] = &Schema{Ref: schemasReference + name}
+	}
+	return payload
+}
+
+func replyPayload(readEntities []string) *Schema {
+	payload := newPayload()
+	payload.Properties[statusField] = &Schema{
+		Type: "string",
+		Enum: []string{"SUCCEEDED", "FAILED"},
+	}
+	payload.Required = append(payload.Required, statusField)
+
+	for _, name := range readEntities {
+		payload.Properties[names.LowerFirst(names.Identifier(name))] = &Schema{Ref: schemasReference + name}
+	}
+	return payload
+}
+
+func newPayload() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			sagaIDField: {Type: "string", Description: "Identifier of the saga instance"},
+		},
+		Required: []string{sagaIDField},
+	}
+}
+
+func clusterChannelName(decomposition *files.Decomposition, clusterID int) string {
+	segments := []string{}
+	for _, segment := range []string{decomposition.DendogramName, decomposition.Name} {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	segments = append(segments, fmt.Sprintf("cluster%d", clusterID))
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,63 @@
+package asyncapi_test
+
+import (
+	"automation/app/asyncapi"
+	"automation/app/common/fixtures"
+	"automation/app/common/log"
+	"automation/app/configuration"
+	"automation/app/files"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateDocument(t *testing.T) {
+	handler := asyncapi.New(log.NewNopLogger())
+
+	decomposition := &files.Decomposition{Name: "N3", DendogramName: "ldod"}
+	controller := &files.Controller{Name: "VirtualEditionController.approveParticipant"}
+	redesign := fixtures.Trace(controller.Name,
+		fixtures.Invocation(0, []interface{}{"R", float64(1)}),
+		fixtures.Invocation(1, []interface{}{"W", float64(2)}),
+		fixtures.Invocation(2, []interface{}{"W", float64(3)}),
+		fixtures.Invocation(1, []interface{}{"R", float64(2)}),
+	)
+
+	functionalities := []*configuration.FunctionalityResult{{
+		Codebase:      "ldod",
+		Decomposition: decomposition,
+		Controller:    controller,
+		SagaRedesigns: []*files.FunctionalityRedesign{redesign},
+	}}
+
+	idToEntityMap := map[string]string{
+		"1": "User",
+		"2": "VirtualEdition",
+		"3": "Member",
+	}
+
+	document := handler.GenerateDocument("ldod", functionalities, idToEntityMap)
+
+	assert.Equal(t, "2.6.0", document.AsyncAPI)
+	assert.Len(t, document.Channels, 4)
+	assert.NotContains(t, document.Channels, "ldod/N3/cluster0/commands")
+
+	commands := document.Channels["ldod/N3/cluster1/commands"].Publish.Message.OneOf
+	assert.Equal(t, []*asyncapi.Reference{
+		{Ref: "#/components/messages/VirtualEditionControllerApproveParticipantStep1Command"},
+		{Ref: "#/components/messages/VirtualEditionControllerApproveParticipantStep1CompensationCommand"},
+		{Ref: "#/components/messages/VirtualEditionControllerApproveParticipantStep3Command"},
+	}, commands)
+
+	command := document.Components.Messages["VirtualEditionControllerApproveParticipantStep1Command"]
+	assert.Contains(t, command.Payload.Properties, "virtualEditionId")
+	assert.Equal(t, "#/components/schemas/VirtualEdition", command.Payload.Properties["virtualEdition"].Ref)
+
+	reply := document.Components.Messages["VirtualEditionControllerApproveParticipantStep3Reply"]
+	assert.Equal(t, "#/components/schemas/VirtualEdition", reply.Payload.Properties["virtualEdition"].Ref)
+	assert.Equal(t, []string{"sagaId", "status"}, reply.Payload.Required)
+
+	assert.NotContains(t, document.Components.Messages, "VirtualEditionControllerApproveParticipantStep2CompensationCommand")
+	assert.NotContains(t, document.Components.Messages, "VirtualEditionControllerApproveParticipantStep0Command")
+	assert.Contains(t, document.Components.Schemas, "Member")
+}
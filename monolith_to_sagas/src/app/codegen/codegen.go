@@ -0,0 +1,314 @@
+package codegen
+
+import (
+	"automation/app/common/names"
+	"automation/app/files"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	templateFileExtension = ".tmpl"
+	defaultMaxAttempts    = 3
+	defaultBackoffMillis  = 100
+	maxEntitiesInStepName = 3
+)
+
+type CodegenHandler interface {
+	RegisterTemplate(string, string, string) error
+	LoadTemplates(string) error
+	GetTemplateExtension(string) (string, error)
+	GenerateOrchestrator(string, *files.Controller, *files.FunctionalityRedesign, map[string]string) (string, error)
+}
+
+type DefaultHandler struct {
+	logger    log.Logger
+	mutex     sync.RWMutex
+	templates map[string]*sagaTemplate
+}
+
+type sagaTemplate struct {
+	Extension string
+	Template  *template.Template
+}
+
+func New(logger log.Logger) CodegenHandler {
+	handler := &DefaultHandler{
+		logger:    log.With(logger, "module", "codegenHandler"),
+		templates: map[string]*sagaTemplate{},
+	}
+
+	for name, builtIn := range builtInTemplates {
+		err := handler.RegisterTemplate(name, builtIn.Extension, builtIn.Text)
+		if err != nil {
+			handler.logger.Log("Failed to register built-in template %s | %s", name, err.Error())
+		}
+	}
+
+	return handler
+}
+
+// RegisterTemplate adds a template under the given name, replacing any template with the same
+// name, including the built-in ones. The extension is used for the generated files.
+func (svc *DefaultHandler) RegisterTemplate(name string, extension string, text string) error {
+	parsedTemplate, err := template.New(name).Funcs(templateFunctions).Parse(text)
+	if err != nil {
+		svc.logger.Log(err)
+		return err
+	}
+
+	svc.mutex.Lock()
+	svc.templates[name] = &sagaTemplate{
+		Extension: extension,
+		Template:  parsedTemplate,
+	}
+	svc.mutex.Unlock()
+
+	return nil
+}
+
+// LoadTemplates registers every file of the folder named <name>.<extension>.tmpl, such as
+// kotlin.kt.tmpl, as the template <name>
+func (svc *DefaultHandler) LoadTemplates(folder string) error {
+	paths, err := filepath.Glob(filepath.Join(folder, "*"+templateFileExtension))
+	if err != nil {
+		svc.logger.Log(err)
+		return err
+	}
+
+	for _, path := range paths {
+		parts := strings.SplitN(strings.TrimSuffix(filepath.Base(path), templateFileExtension), ".", 2)
+		if len(parts) != 2 {
+			svc.logger.Log("Ignoring template without extension %s", path)
+			continue
+		}
+
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			svc.logger.Log(err)
+			return err
+		}
+
+		err = svc.RegisterTemplate(parts[0], parts[1], string(text))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (svc *DefaultHandler) GetTemplateExtension(name string) (string, error) {
+	svc.mutex.RLock()
+	defer svc.mutex.RUnlock()
+
+	sagaTemplate, found := svc.templates[name]
+	if !found {
+		return "", fmt.Errorf("template %s is not registered", name)
+	}
+	return sagaTemplate.Extension, nil
+}
+
+// GenerateOrchestrator renders the template for the saga redesign of the controller. Go code
+// is formatted, so a template producing invalid Go fails the generation.
+func (svc *DefaultHandler) GenerateOrchestrator(
+	templateName string, controller *files.Controller, redesign *files.FunctionalityRedesign, idToEntityMap map[string]string,
+) (string, error) {
+	svc.mutex.RLock()
+	sagaTemplate, found := svc.templates[templateName]
+	svc.mutex.RUnlock()
+	if !found {
+		return "", fmt.Errorf("template %s is not registered", templateName)
+	}
+
+	var buffer bytes.Buffer
+	err := sagaTemplate.Template.Execute(&buffer, svc.buildSagaModel(controller, redesign, idToEntityMap))
+	if err != nil {
+		svc.logger.Log(err)
+		return "", err
+	}
+
+	if sagaTemplate.Extension != "go" {
+		return buffer.String(), nil
+	}
+
+	formatted, err := format.Source(buffer.Bytes())
+	if err != nil {
+		svc.logger.Log(err)
+		return "", err
+	}
+	return string(formatted), nil
+}
+
+// SagaModel is the data given to the templates. Every type declared by a saga starts with its
+// name, so the sagas of a decomposition can be generated in the same package.
+type SagaModel struct {
+	Functionality string
+	Name          string
+	Orchestrator  *Participant
+	Participants  []*Participant
+	Steps         []*Step
+}
+
+type Participant struct {
+	ClusterID   int
+	ServiceName string
+	FieldName   string
+	Entities    []string
+	Steps       []*Step
+}
+
+type Step struct {
+	Index         int
+	Name          string
+	MethodName    string
+	Type          string
+	Participant   *Participant
+	Accesses      []*Access
+	Local         bool
+	Pivot         bool
+	Compensatable bool
+	Retriable     bool
+	RetryPolicy   *RetryPolicy
+}
+
+type Access struct {
+	Entity string
+	Mode   string
+}
+
+type RetryPolicy struct {
+	MaxAttempts   int
+	BackoffMillis int
+}
+
+func (svc *DefaultHandler) buildSagaModel(controller *files.Controller, redesign *files.FunctionalityRedesign, idToEntityMap map[string]string) *SagaModel {
+	model := &SagaModel{
+		Functionality: controller.Name,
+		Name:          identifier(controller.Name),
+		Participants:  []*Participant{},
+		Steps:         []*Step{},
+	}
+
+	participants := map[int]*Participant{}
+	getParticipant := func(clusterID int) *Participant {
+		participant, found := participants[clusterID]
+		if !found {
+			participant = &Participant{
+				ClusterID:   clusterID,
+				ServiceName: fmt.Sprintf("%sCluster%dService", model.Name, clusterID),
+				FieldName:   fmt.Sprintf("cluster%dService", clusterID),
+				Entities:    names.EntityNames(controller.EntitiesPerCluster[strconv.Itoa(clusterID)], idToEntityMap),
+				Steps:       []*Step{},
+			}
+			participants[clusterID] = participant
+			model.Participants = append(model.Participants, participant)
+		}
+		return participant
+	}
+
+	if !redesign.Choreography {
+		model.Orchestrator = getParticipant(redesign.OrchestratorID)
+	}
+
+	for idx, invocation := range redesign.Redesign {
+		if invocation.ClusterID == -1 || len(invocation.ClusterAccesses) == 0 {
+			continue
+		}
+
+		stepType := redesign.GetStepType(idx)
+		step := &Step{
+			Index:         len(model.Steps),
+			Type:          stepType,
+			Participant:   getParticipant(invocation.ClusterID),
+			Accesses:      []*Access{},
+			Local:         !redesign.Choreography && invocation.ClusterID == redesign.OrchestratorID,
+			Pivot:         stepType == "PIVOT",
+			Compensatable: stepType == "COMPENSATABLE",
+			Retriable:     stepType == "RETRIABLE",
+		}
+
+		for accessIdx := range invocation.ClusterAccesses {
+			step.Accesses = append(step.Accesses, &Access{
+				Entity: names.EntityName(invocation.GetAccessEntityID(accessIdx), idToEntityMap),
+				Mode:   invocation.GetAccessType(accessIdx),
+			})
+		}
+
+		if step.Retriable {
+			step.RetryPolicy = &RetryPolicy{
+				MaxAttempts:   defaultMaxAttempts,
+				BackoffMillis: defaultBackoffMillis,
+			}
+		}
+
+		step.Name = stepName(step, invocation.ContainsLock())
+		step.MethodName = names.LowerFirst(step.Name)
+
+		step.Participant.Steps = append(step.Participant.Steps, step)
+		model.Steps = append(model.Steps, step)
+	}
+
+	sort.SliceStable(model.Participants, func(i, j int) bool {
+		return model.Participants[i].ClusterID < model.Participants[j].ClusterID
+	})
+
+	return model
+}
+
+// SagaFileName returns the name of the file of the saga of the controller, which Java requires
+// to match the name of its public class
+func SagaFileName(controllerName string, extension string) string {
+	return fmt.Sprintf("%sSaga.%s", identifier(controllerName), extension)
+}
+
+func stepName(step *Step, containsLock bool) string {
+	verb := "Read"
+	if containsLock {
+		verb = "Update"
+	}
+
+	name := fmt.Sprintf("Step%d%s", step.Index, verb)
+	for idx, access := range step.Accesses {
+		if idx == maxEntitiesInStepName {
+			break
+		}
+		name += identifier(access.Entity)
+	}
+	return name
+}
+
+// identifier converts a name such as VirtualEditionController.approveParticipant into an
+// exported identifier valid in both Go and Java
+func identifier(name string) string {
+	result := names.Identifier(name)
+	if result == "" || unicode.IsDigit(rune(result[0])) {
+		result = "Saga" + result
+	}
+	return result
+}
+
+func describeAccesses(accesses []*Access) string {
+	descriptions := []string{}
+	for _, access := range accesses {
+		descriptions = append(descriptions, fmt.Sprintf("%s (%s)", access.Entity, access.Mode))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+var templateFunctions = template.FuncMap{
+	"join":     strings.Join,
+	"accesses": describeAccesses,
+	"lower":    names.LowerFirst,
+}
@@ -0,0 +1,135 @@
+package codegen_test
+
+import (
+	"automation/app/codegen"
+	"automation/app/common/fixtures"
+	"automation/app/common/log"
+	"automation/app/files"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateOrchestrator(t *testing.T) {
+	folder, err := ioutil.TempDir("", "templates")
+	assert.NoError(t, err)
+	defer os.RemoveAll(folder)
+
+	text := "{{range .Steps}}{{.Name}} {{.Type}}\n{{end}}"
+	err = ioutil.WriteFile(filepath.Join(folder, "steps.txt.tmpl"), []byte(text), 0644)
+	assert.NoError(t, err)
+
+	handler := codegen.New(log.NewNopLogger())
+	assert.NoError(t, handler.LoadTemplates(folder))
+
+	extension, err := handler.GetTemplateExtension("steps")
+	assert.NoError(t, err)
+	assert.Equal(t, "txt", extension)
+
+	controller := &files.Controller{
+		Name:               "OrderController.placeOrder",
+		Type:               "SAGA",
+		EntitiesPerCluster: map[string][]int{"0": {1}, "1": {2}, "2": {3}},
+	}
+	redesign := fixtures.Trace(controller.Name,
+		fixtures.Invocation(0, []interface{}{"R", 1}),
+		fixtures.Invocation(1, []interface{}{"RW", 2}),
+		fixtures.Invocation(2, []interface{}{"W", 3}),
+		fixtures.Invocation(1, []interface{}{"R", 2}),
+	)
+	idToEntityMap := map[string]string{"1": "Customer", "2": "Order", "3": "Payment"}
+
+	cases := []struct {
+		language    string
+		contains    []string
+		notContains []string
+	}{
+		{
+			"go",
+			[]string{
+				"type OrderControllerPlaceOrderSaga struct",
+				"CompensateStep1UpdateOrder(ctx context.Context) error",
+				"s.retry(ctx, 3, 100*time.Millisecond, s.cluster1Service.Step3ReadOrder)",
+			},
+			[]string{"CompensateStep2UpdatePayment"},
+		},
+		{
+			"java-spring",
+			[]string{
+				"public class OrderControllerPlaceOrderSaga {",
+				"interface OrderControllerPlaceOrderCluster1Service {",
+				"compensations.push(cluster1Service::compensateStep1UpdateOrder);",
+				"retryTemplate(3, 100).execute(context -> {",
+			},
+			[]string{"compensateStep2UpdatePayment"},
+		},
+		{
+			"steps",
+			[]string{"Step0ReadCustomer RETRIABLE\nStep1UpdateOrder COMPENSATABLE\nStep2UpdatePayment PIVOT\nStep3ReadOrder RETRIABLE\n"},
+			[]string{},
+		},
+	}
+
+	for _, c := range cases {
+		code, err := handler.GenerateOrchestrator(c.language, controller, redesign, idToEntityMap)
+		assert.NoError(t, err, c.language)
+		for _, text := range c.contains {
+			assert.Contains(t, code, text, c.language)
+		}
+		for _, text := range c.notContains {
+			assert.NotContains(t, code, text, c.language)
+		}
+	}
+	assert.Equal(t, "OrderControllerPlaceOrderSaga.java", codegen.SagaFileName(controller.Name, "java"))
+}
+
+func TestGenerateGoOrchestratorsInTheSamePackage(t *testing.T) {
+	handler := codegen.New(log.NewNopLogger())
+
+	// the sagas share the participants of clusters 1 and 2
+	sagas := []struct {
+		controller *files.Controller
+		redesign   *files.FunctionalityRedesign
+	}{
+		{
+			&files.Controller{Name: "OrderController.placeOrder", EntitiesPerCluster: map[string][]int{"0": {1}, "1": {2}, "2": {3}}},
+			fixtures.Trace("OrderController.placeOrder",
+				fixtures.Invocation(0, []interface{}{"R", 1}),
+				fixtures.Invocation(1, []interface{}{"RW", 2}),
+				fixtures.Invocation(2, []interface{}{"W", 3}),
+			),
+		},
+		{
+			&files.Controller{Name: "OrderController.cancelOrder", EntitiesPerCluster: map[string][]int{"1": {2}, "2": {3}}},
+			fixtures.Trace("OrderController.cancelOrder",
+				fixtures.Invocation(1, []interface{}{"R", 2}),
+				fixtures.Invocation(2, []interface{}{"W", 3}),
+			),
+		},
+	}
+	sagas[1].redesign.OrchestratorID = 1
+	idToEntityMap := map[string]string{"1": "Customer", "2": "Order", "3": "Payment"}
+
+	fileSet := token.NewFileSet()
+	sagaFiles := []*ast.File{}
+	for _, saga := range sagas {
+		code, err := handler.GenerateOrchestrator("go", saga.controller, saga.redesign, idToEntityMap)
+		assert.NoError(t, err)
+
+		file, err := parser.ParseFile(fileSet, codegen.SagaFileName(saga.controller.Name, "go"), code, parser.AllErrors)
+		assert.NoError(t, err, saga.controller.Name)
+		sagaFiles = append(sagaFiles, file)
+	}
+
+	config := &types.Config{Importer: importer.ForCompiler(fileSet, "source", nil)}
+	_, err := config.Check("sagas", fileSet, sagaFiles, nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,191 @@
+package codegen
+
+type builtInTemplate struct {
+	Extension string
+	Text      string
+}
+
+var builtInTemplates = map[string]builtInTemplate{
+	"go": {
+		Extension: "go",
+		Text:      goTemplate,
+	},
+	"java-spring": {
+		Extension: "java",
+		Text:      javaSpringTemplate,
+	},
+}
+
+const goTemplate = `// Code generated from the saga redesign of {{.Functionality}}.
+
+package sagas
+
+import (
+	"context"
+	"time"
+)
+{{range .Participants}}
+// {{.ServiceName}} is the participant of cluster {{.ClusterID}}, which owns {{join .Entities ", "}}
+type {{.ServiceName}} interface {
+{{- range .Steps}}
+	// {{.Name}} accesses {{accesses .Accesses}}
+	{{.Name}}(ctx context.Context) error
+{{- if .Compensatable}}
+	Compensate{{.Name}}(ctx context.Context) error
+{{- end}}
+{{- end}}
+}
+{{end}}
+// {{.Name}}Saga executes {{.Functionality}}{{if .Orchestrator}}, orchestrated by cluster {{.Orchestrator.ClusterID}}{{end}}
+type {{.Name}}Saga struct {
+{{- range .Participants}}
+	{{.FieldName}} {{.ServiceName}}
+{{- end}}
+}
+
+func New{{.Name}}Saga({{range $idx, $participant := .Participants}}{{if $idx}}, {{end}}{{$participant.FieldName}} {{$participant.ServiceName}}{{end}}) *{{.Name}}Saga {
+	return &{{.Name}}Saga{
+{{- range .Participants}}
+		{{.FieldName}}: {{.FieldName}},
+{{- end}}
+	}
+}
+
+// Execute runs the steps in order. When a step up to the pivot fails, the completed
+// compensatable steps are compensated in reverse order.
+func (s *{{.Name}}Saga) Execute(ctx context.Context) error {
+	compensations := []func(context.Context) error{}
+{{range .Steps}}
+	// Step {{.Index}}: {{.Type}}{{if .Local}} local{{end}} invocation of cluster {{.Participant.ClusterID}}, accessing {{accesses .Accesses}}
+{{- if .Retriable}}
+	if err := s.retry(ctx, {{.RetryPolicy.MaxAttempts}}, {{.RetryPolicy.BackoffMillis}}*time.Millisecond, s.{{.Participant.FieldName}}.{{.Name}}); err != nil {
+{{- else}}
+	if err := s.{{.Participant.FieldName}}.{{.Name}}(ctx); err != nil {
+{{- end}}
+		return s.compensate(ctx, compensations, err)
+	}
+{{- if .Compensatable}}
+	compensations = append(compensations, s.{{.Participant.FieldName}}.Compensate{{.Name}})
+{{- end}}
+{{- if .Pivot}}
+	// the saga can no longer be rolled back after the pivot
+	compensations = nil
+{{- end}}
+{{end}}
+	return nil
+}
+
+func (s *{{.Name}}Saga) compensate(ctx context.Context, compensations []func(context.Context) error, cause error) error {
+	for idx := len(compensations) - 1; idx >= 0; idx-- {
+		if err := compensations[idx](ctx); err != nil {
+			return err
+		}
+	}
+	return cause
+}
+
+func (s *{{.Name}}Saga) retry(ctx context.Context, maxAttempts int, backoff time.Duration, step func(context.Context) error) error {
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if err = step(ctx); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+	return err
+}
+`
+
+const javaSpringTemplate = `// Code generated from the saga redesign of {{.Functionality}}.
+
+package sagas;
+
+import java.util.ArrayDeque;
+import java.util.Deque;
+
+import org.springframework.retry.backoff.FixedBackOffPolicy;
+import org.springframework.retry.policy.SimpleRetryPolicy;
+import org.springframework.retry.support.RetryTemplate;
+import org.springframework.stereotype.Service;
+{{range .Participants}}
+/**
+ * Participant of cluster {{.ClusterID}}, which owns {{join .Entities ", "}}
+ */
+interface {{.ServiceName}} {
+{{- range .Steps}}
+
+    /** Accesses {{accesses .Accesses}} */
+    void {{.MethodName}}();
+{{- if .Compensatable}}
+
+    void compensate{{.Name}}();
+{{- end}}
+{{- end}}
+}
+{{end}}
+/**
+ * Executes {{.Functionality}}{{if .Orchestrator}}, orchestrated by cluster {{.Orchestrator.ClusterID}}{{end}}
+ */
+@Service
+public class {{.Name}}Saga {
+{{- range .Participants}}
+
+    private final {{.ServiceName}} {{.FieldName}};
+{{- end}}
+
+    public {{.Name}}Saga({{range $idx, $participant := .Participants}}{{if $idx}}, {{end}}{{$participant.ServiceName}} {{$participant.FieldName}}{{end}}) {
+{{- range .Participants}}
+        this.{{.FieldName}} = {{.FieldName}};
+{{- end}}
+    }
+
+    /**
+     * Runs the steps in order. When a step up to the pivot fails, the completed
+     * compensatable steps are compensated in reverse order.
+     */
+    public void execute() {
+        Deque<Runnable> compensations = new ArrayDeque<>();
+        try {
+{{- range .Steps}}
+
+            // Step {{.Index}}: {{.Type}}{{if .Local}} local{{end}} invocation of cluster {{.Participant.ClusterID}}, accessing {{accesses .Accesses}}
+{{- if .Retriable}}
+            retryTemplate({{.RetryPolicy.MaxAttempts}}, {{.RetryPolicy.BackoffMillis}}).execute(context -> {
+                {{.Participant.FieldName}}.{{.MethodName}}();
+                return null;
+            });
+{{- else}}
+            {{.Participant.FieldName}}.{{.MethodName}}();
+{{- end}}
+{{- if .Compensatable}}
+            compensations.push({{.Participant.FieldName}}::compensate{{.Name}});
+{{- end}}
+{{- if .Pivot}}
+            // the saga can no longer be rolled back after the pivot
+            compensations.clear();
+{{- end}}
+{{- end}}
+        } catch (RuntimeException e) {
+            while (!compensations.isEmpty()) {
+                compensations.pop().run();
+            }
+            throw e;
+        }
+    }
+
+    private static RetryTemplate retryTemplate(int maxAttempts, long backoffMillis) {
+        FixedBackOffPolicy backOffPolicy = new FixedBackOffPolicy();
+        backOffPolicy.setBackOffPeriod(backoffMillis);
+
+        RetryTemplate retryTemplate = new RetryTemplate();
+        retryTemplate.setRetryPolicy(new SimpleRetryPolicy(maxAttempts));
+        retryTemplate.setBackOffPolicy(backOffPolicy);
+        return retryTemplate;
+    }
+}
+`
@@ -0,0 +1,24 @@
+// Package fixtures builds the invocations and traces the tests of the redesigns are written with
+package fixtures
+
+import "automation/app/files"
+
+// Invocation returns an invocation of the cluster with the given accesses, e.g. {"W", 1}
+func Invocation(clusterID int, accesses ...[]interface{}) *files.Invocation {
+	return &files.Invocation{ClusterID: clusterID, ClusterAccesses: accesses}
+}
+
+// Trace returns the redesign of the functionality used for the metrics, with the root invocation
+// followed by the given invocations, numbered in order
+func Trace(name string, invocations ...*files.Invocation) *files.FunctionalityRedesign {
+	trace := &files.FunctionalityRedesign{
+		Name:           name,
+		UsedForMetrics: true,
+		Redesign:       []*files.Invocation{{Name: name, ID: -1, ClusterID: -1}},
+	}
+	for idx, invocation := range invocations {
+		invocation.ID = idx
+		trace.Redesign = append(trace.Redesign, invocation)
+	}
+	return trace
+}
@@ -0,0 +1,54 @@
+package names
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EntityName returns the name of the entity, or Entity<id> when it is not in the map
+func EntityName(entityID int, idToEntityMap map[string]string) string {
+	name, found := idToEntityMap[strconv.Itoa(entityID)]
+	if !found {
+		return fmt.Sprintf("Entity%d", entityID)
+	}
+	return name
+}
+
+func EntityNames(entityIDs []int, idToEntityMap map[string]string) []string {
+	names := []string{}
+	for _, entityID := range entityIDs {
+		names = append(names, EntityName(entityID, idToEntityMap))
+	}
+	return names
+}
+
+// Identifier converts a name such as VirtualEditionController.approveParticipant into the
+// pascal case VirtualEditionControllerApproveParticipant
+func Identifier(name string) string {
+	var builder strings.Builder
+	upperNext := true
+	for _, character := range name {
+		if !unicode.IsLetter(character) && !unicode.IsDigit(character) {
+			upperNext = true
+			continue
+		}
+
+		if upperNext {
+			character = unicode.ToUpper(character)
+			upperNext = false
+		}
+		builder.WriteRune(character)
+	}
+	return builder.String()
+}
+
+func LowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
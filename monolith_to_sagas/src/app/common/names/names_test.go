@@ -0,0 +1,20 @@
+package names_test
+
+import (
+	"automation/app/common/names"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityNames(t *testing.T) {
+	idToEntityMap := map[string]string{"1": "User"}
+
+	assert.Equal(t, []string{"User", "Entity2"}, names.EntityNames([]int{1, 2}, idToEntityMap))
+}
+
+func TestIdentifier(t *testing.T) {
+	assert.Equal(t, "VirtualEditionControllerApproveParticipant", names.Identifier("VirtualEditionController.approveParticipant"))
+	assert.Equal(t, "OrderPlaced", names.Identifier("order-placed"))
+	assert.Equal(t, "virtualEdition", names.LowerFirst("VirtualEdition"))
+}
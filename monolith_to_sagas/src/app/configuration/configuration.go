@@ -1,6 +1,9 @@
 package configuration
 
-import "time"
+import (
+	"automation/app/files"
+	"time"
+)
 
 type Execution struct {
 	Configuration  *Configuration `json:"configuration,omitempty"`
@@ -11,7 +14,7 @@ func (e *Execution) GenerateResults() Results {
 	results := Results{
 		Datasets: &Datasets{},
 	}
-	results.GenerateDefaultDatasets()
+	results.GenerateDefaultDatasets(e.Configuration)
 
 	e.ResultsBatches = append(e.ResultsBatches, results)
 	return results
@@ -36,6 +39,7 @@ type Configuration struct {
 	OnlyJoaoControllers     bool                    `json:"only_joao_controllers,omitempty"`
 	GenerateComplexitiesCSV bool                    `json:"generate_complexities_csv,omitempty"`
 	GenerateMetricsCSV      bool                    `json:"generate_metrics_csv,omitempty"`
+	GenerateCouplingCSV     bool                    `json:"generate_coupling_csv,omitempty"`
 	Executions              int                     `json:"executions,omitempty"`
 	Codebases               []CodebaseConfiguration `json:"codebases,omitempty"`
 
@@ -45,12 +49,88 @@ type Configuration struct {
 	ExcludeLowDistanceRedesigns           bool    `json:"exclude_low_distance_redesigns,omitempty"`
 	AcceptableComplexityDistanceThreshold float32 `json:"acceptable_complexity_distance_threshold,omitempty"`
 	OnlyExportBestRedesign                bool    `json:"only_export_best_redesign,omitempty"`
+	CompareChoreographies                 bool    `json:"compare_choreographies,omitempty"`
+	DetectParallelSteps                   bool    `json:"detect_parallel_steps,omitempty"`
+	ReorderInvocations                    bool    `json:"reorder_invocations,omitempty"`
+	AuditMerges                           bool    `json:"audit_merges,omitempty"`
+
+	// Estimation of every decomposition of the dendrograms instead of the one of the cut value,
+	// reporting the cut of each dendrogram with the lowest total saga complexity
+	SweepDecompositions bool `json:"sweep_decompositions,omitempty"`
+
+	// Comparison of the sagas of the expert decomposition of each dendrogram with the ones of the
+	// automatic decomposition closest to it
+	CompareExpertDecompositions bool `json:"compare_expert_decompositions,omitempty"`
+
+	// Comparison of API composition and replicated views for the queries that read several clusters
+	RedesignQueries bool `json:"redesign_queries,omitempty"`
+
+	// Performance estimation of the redesigns
+	CalculatePerformance bool      `json:"calculate_performance,omitempty"`
+	MinimizeLatency      bool      `json:"minimize_latency,omitempty"`
+	CostModel            CostModel `json:"cost_model,omitempty"`
+
+	// Simulated executions of the redesigns with injected failures
+	SimulateFailures bool         `json:"simulate_failures,omitempty"`
+	FailureModel     FailureModel `json:"failure_model,omitempty"`
+
+	// Concurrent executions of the chosen redesigns of each decomposition
+	SimulateContention bool            `json:"simulate_contention,omitempty"`
+	ContentionModel    ContentionModel `json:"contention_model,omitempty"`
+
+	// Names of the registered metrics calculated for every redesign and exported as columns, and
+	// the ones used to rank the redesigns, by priority, before the complexities
+	Metrics        []string `json:"metrics,omitempty"`
+	RankingMetrics []string `json:"ranking_metrics,omitempty"`
+
+	// Orchestrators of the functionalities of each decomposition chosen together, so no cluster
+	// orchestrates more than the given number of functionalities, where zero does not limit them
+	AssignOrchestratorsGlobally bool `json:"assign_orchestrators_globally,omitempty"`
+	MaxOrchestrationsPerCluster int  `json:"max_orchestrations_per_cluster,omitempty"`
+
+	// Suggestions of read-only replicas for the entities read from other clusters, written by at
+	// most the given share of the functionalities that access them
+	SuggestReplicas      bool    `json:"suggest_replicas,omitempty"`
+	ReplicaMaxWriteRatio float32 `json:"replica_max_write_ratio,omitempty"`
+
+	// Exports of the chosen redesigns
+	GenerateSequenceDiagrams bool `json:"generate_sequence_diagrams,omitempty"`
+	GenerateGraphs           bool `json:"generate_graphs,omitempty"`
+	GenerateDependencyGraphs bool `json:"generate_dependency_graphs,omitempty"`
+	GenerateWorkflows        bool `json:"generate_workflows,omitempty"`
+	GenerateAsyncAPI         bool `json:"generate_async_api,omitempty"`
+	GenerateTestPlans        bool `json:"generate_test_plans,omitempty"`
+
+	// Names of the templates used to generate orchestrator skeletons, the built-in ones are go
+	// and java-spring, and the folder with additional templates named <name>.<extension>.tmpl
+	CodeTemplates       []string `json:"code_templates,omitempty"`
+	CodeTemplatesFolder string   `json:"code_templates_folder,omitempty"`
 
 	// StdOut configurations
 	PrintTraces                bool   `json:"print_traces,omitempty"`
 	PrintSpecificFunctionality string `json:"print_specific_functionality,omitempty"`
 }
 
+// ShouldCalculatePerformance checks if the latency and network cost of the redesigns is needed,
+// either to be exported or to rank the redesigns
+func (c *Configuration) ShouldCalculatePerformance() bool {
+	return c.CalculatePerformance || c.MinimizeLatency
+}
+
+// MetricsToCalculate returns the registered metrics that are either exported or used in ranking,
+// without repetitions
+func (c *Configuration) MetricsToCalculate() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, name := range append(append([]string{}, c.Metrics...), c.RankingMetrics...) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func (c *Configuration) GenerateDefaultCodebaseConfiguration() {
 	if c.LdodOnly {
 		codebasesConfig := []CodebaseConfiguration{
@@ -382,6 +462,53 @@ func (c *Configuration) GenerateDefaultCodebaseConfiguration() {
 	}
 }
 
+// CostModel estimates the cost of executing a redesign. Latencies are in milliseconds and
+// sizes in bytes. The remote invocation latency is the round trip of an invocation of another
+// cluster, whatever the number of messages it exchanges.
+type CostModel struct {
+	RemoteInvocationLatency float32 `json:"remote_invocation_latency,omitempty"`
+	LocalAccessCost         float32 `json:"local_access_cost,omitempty"`
+	MessageHeaderSize       int     `json:"message_header_size,omitempty"`
+	EntityAccessSize        int     `json:"entity_access_size,omitempty"`
+}
+
+// FailureModel configures the failures injected in the simulated executions of the redesigns.
+// The probabilities are per attempt of a local transaction, the steps after the pivot are
+// retried up to MaxRetries times and the seed makes the simulations reproducible.
+type FailureModel struct {
+	RemoteStepFailureProbability float32 `json:"remote_step_failure_probability,omitempty"`
+	LocalStepFailureProbability  float32 `json:"local_step_failure_probability,omitempty"`
+	MaxRetries                   int     `json:"max_retries,omitempty"`
+	Runs                         int     `json:"runs,omitempty"`
+	Seed                         int64   `json:"seed,omitempty"`
+}
+
+// ContentionModel configures the concurrent executions of the sagas. Arrival rates are in sagas
+// per second, either the same for every functionality or overridden by functionality name, and
+// the lock timeout and duration are in milliseconds, like the cost model.
+type ContentionModel struct {
+	ArrivalRate  float32            `json:"arrival_rate,omitempty"`
+	ArrivalRates map[string]float32 `json:"arrival_rates,omitempty"`
+	LockTimeout  float32            `json:"lock_timeout,omitempty"`
+	Duration     float32            `json:"duration,omitempty"`
+	Seed         int64              `json:"seed,omitempty"`
+}
+
+// OptimizationModel configures the local search of a better decomposition. Each iteration moves
+// an entity to another cluster or, with the merge probability, merges two clusters, and the
+// annealing temperature is multiplied by the cooling rate after each iteration. The decompositions
+// with fewer than MinClusters clusters or with a cluster with more than MaxClusterSize entities
+// are not considered, and a zero MaxClusterSize does not limit the size of the clusters.
+type OptimizationModel struct {
+	Iterations         int     `json:"iterations,omitempty"`
+	InitialTemperature float64 `json:"initial_temperature,omitempty"`
+	CoolingRate        float64 `json:"cooling_rate,omitempty"`
+	MergeProbability   float64 `json:"merge_probability,omitempty"`
+	MinClusters        int     `json:"min_clusters,omitempty"`
+	MaxClusterSize     int     `json:"max_cluster_size,omitempty"`
+	Seed               int64   `json:"seed,omitempty"`
+}
+
 type CodebaseConfiguration struct {
 	Name                    string   `json:"name,omitempty"`
 	CutValue                float32  `json:"cut_value,omitempty"`
@@ -408,6 +535,25 @@ func (c *CodebaseConfiguration) ShouldRefactorController(name string, controller
 	return shouldRefactor
 }
 
+// ShouldRefactorQuery checks if the controller is a query that reads entities of more than one
+// cluster, restricted to the controllers to refactor when those are given
+func (c *CodebaseConfiguration) ShouldRefactorQuery(name string, controllerType string, clustersCount int) bool {
+	if controllerType != "QUERY" || clustersCount <= 1 {
+		return false
+	}
+
+	if len(c.ControllersToRefactor) > 0 {
+		for _, controllerName := range c.ControllersToRefactor {
+			if name == controllerName {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
 type Results struct {
 	Datasets                    *Datasets       `json:"datasets,omitempty"`
 	ExecutionTime               time.Duration   `json:"execution_times,omitempty"`
@@ -444,7 +590,7 @@ func GetLowestAndHighestDuration(durations []time.Duration) (time.Duration, time
 	return highest, lowest
 }
 
-func (r *Results) GenerateDefaultDatasets() {
+func (r *Results) GenerateDefaultDatasets(configuration *Configuration) {
 	r.Datasets = &Datasets{
 		MetricsDataset: [][]string{
 			{
@@ -499,10 +645,172 @@ func (r *Results) GenerateDefaultDatasets() {
 				"FCCP",
 			},
 		},
+		CouplingDataset: [][]string{
+			{
+				"Codebase",
+				"Dendrogram",
+				"Decomposition",
+				"Cluster",
+				"Initial Coupling",
+				"Redesigned Coupling",
+				"Coupling Difference",
+			},
+		},
+		QueriesDataset: [][]string{
+			{
+				"Codebase",
+				"Dendrogram",
+				"Decomposition",
+				"Feature",
+				"Clusters Read",
+				"Entities Read",
+				"Aggregator",
+				"API Composition Invocations Count",
+				"API Composition Inconsistency Complexity",
+				"View Updating Features",
+				"View Inconsistency Complexity",
+				"Preferred Query Style",
+			},
+		},
+		SweepDataset: [][]string{
+			{
+				"Codebase",
+				"Dendrogram",
+				"Decomposition",
+				"Cut Value",
+				"Expert",
+				"Clusters Count",
+				"Features Count",
+				"Total Functionality Complexity",
+				"Total System Complexity",
+				"Total Complexity",
+				"Average Complexity",
+				"Best Cut",
+			},
+		},
+		ExpertDataset: [][]string{
+			{
+				"Codebase",
+				"Dendrogram",
+				"Expert Decomposition",
+				"Automatic Decomposition",
+				"Automatic Cut Value",
+				"Feature",
+				"Expert Clusters Count",
+				"Automatic Clusters Count",
+				"Expert Orchestrator",
+				"Expert Orchestrator Entities",
+				"Automatic Orchestrator",
+				"Automatic Orchestrator Entities",
+				"Expert Functionality Complexity",
+				"Automatic Functionality Complexity",
+				"Expert Functionality Complexity Reduction",
+				"Automatic Functionality Complexity Reduction",
+				"Expert System Complexity",
+				"Automatic System Complexity",
+				"Expert System Complexity Reduction",
+				"Automatic System Complexity Reduction",
+				"Easier Saga",
+			},
+		},
+	}
+
+	if configuration.CompareChoreographies {
+		r.Datasets.ComplexitiesDataset[0] = append(r.Datasets.ComplexitiesDataset[0],
+			"Orchestration Events Count",
+			"Orchestration Coupling",
+			"Choreography System Complexity",
+			"Choreography Functionality Complexity",
+			"Choreography Invocations Count",
+			"Choreography Events Count",
+			"Choreography Coupling",
+			"Preferred Saga Style",
+		)
+	}
+
+	if configuration.DetectParallelSteps {
+		r.Datasets.ComplexitiesDataset[0] = append(r.Datasets.ComplexitiesDataset[0],
+			"Critical Path Length",
+			"Max Fan-Out",
+		)
+	}
+
+	if configuration.ReorderInvocations {
+		r.Datasets.ComplexitiesDataset[0] = append(r.Datasets.ComplexitiesDataset[0],
+			"Reorderings Count",
+		)
+	}
+
+	if configuration.CalculatePerformance {
+		r.Datasets.ComplexitiesDataset[0] = append(r.Datasets.ComplexitiesDataset[0],
+			"Initial Latency",
+			"Final Latency",
+			"Latency Reduction",
+			"Initial Messages Count",
+			"Final Messages Count",
+			"Initial Messages Size",
+			"Final Messages Size",
+		)
+	}
+
+	if configuration.SimulateFailures {
+		r.Datasets.ComplexitiesDataset[0] = append(r.Datasets.ComplexitiesDataset[0],
+			"Abort Probability",
+			"Expected Compensations",
+			"Expected Rolled Back Steps",
+			"Expected Retries",
+			"Failure Cost",
+			"Failure Cost Rank",
+		)
+	}
+
+	for _, name := range configuration.Metrics {
+		r.Datasets.ComplexitiesDataset[0] = append(r.Datasets.ComplexitiesDataset[0],
+			"Initial "+name,
+			"Final "+name,
+		)
+	}
+
+	if configuration.GenerateDependencyGraphs {
+		r.Datasets.MetricsDataset[0] = append(r.Datasets.MetricsDataset[0],
+			"CDD",
+			"CDDF",
+		)
+		r.Datasets.ComplexitiesDataset[0] = append(r.Datasets.ComplexitiesDataset[0],
+			"CDD",
+			"CDDF",
+		)
+	}
+
+	if configuration.SweepDecompositions {
+		r.Datasets.MetricsDataset[0] = append(r.Datasets.MetricsDataset[0], "Decomposition")
+		r.Datasets.ComplexitiesDataset[0] = append(r.Datasets.ComplexitiesDataset[0], "Decomposition")
 	}
 }
 
 type Datasets struct {
-	MetricsDataset      [][]string `json:"metrics_dataset,omitempty"`
-	ComplexitiesDataset [][]string `json:"complexities_dataset,omitempty"`
+	MetricsDataset      [][]string             `json:"metrics_dataset,omitempty"`
+	ComplexitiesDataset [][]string             `json:"complexities_dataset,omitempty"`
+	CouplingDataset     [][]string             `json:"coupling_dataset,omitempty"`
+	QueriesDataset      [][]string             `json:"queries_dataset,omitempty"`
+	SweepDataset        [][]string             `json:"sweep_dataset,omitempty"`
+	ExpertDataset       [][]string             `json:"expert_dataset,omitempty"`
+	Functionalities     []*FunctionalityResult `json:"-"`
+}
+
+// FunctionalityResult keeps the saga redesigns of a functionality, ordered from best to worst,
+// so they can be exported after the estimation of the codebase is done
+type FunctionalityResult struct {
+	Codebase        string
+	Decomposition   *files.Decomposition
+	Controller      *files.Controller
+	InitialRedesign *files.FunctionalityRedesign
+	SagaRedesigns   []*files.FunctionalityRedesign
+}
+
+func (f *FunctionalityResult) GetBestRedesign() *files.FunctionalityRedesign {
+	if len(f.SagaRedesigns) == 0 {
+		return nil
+	}
+	return f.SagaRedesigns[0]
 }
@@ -0,0 +1,282 @@
+package diagrams
+
+import (
+	"automation/app/files"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+)
+
+type DiagramsHandler interface {
+	GenerateMermaidDiagrams(*files.Controller, *files.FunctionalityRedesign, *files.FunctionalityRedesign, map[string]string) string
+	GeneratePlantUMLDiagrams(*files.Controller, *files.FunctionalityRedesign, *files.FunctionalityRedesign, map[string]string) string
+}
+
+type DefaultHandler struct {
+	logger log.Logger
+}
+
+func New(logger log.Logger) DiagramsHandler {
+	return &DefaultHandler{
+		logger: log.With(logger, "module", "diagramsHandler"),
+	}
+}
+
+type lifeline struct {
+	ID    string
+	Label string
+}
+
+type message struct {
+	From  string
+	To    string
+	Label string
+	Reply bool
+	Pivot bool
+}
+
+type sequence struct {
+	Title     string
+	Note      string
+	Lifelines []*lifeline
+	Messages  []*message
+}
+
+const readOnlyNote = "Read-only saga without a pivot transaction"
+
+// GenerateMermaidDiagrams renders the initial trace and the saga of the controller as Mermaid
+// sequence diagrams inside a markdown document
+func (svc *DefaultHandler) GenerateMermaidDiagrams(
+	controller *files.Controller, initialRedesign *files.FunctionalityRedesign, sagaRedesign *files.FunctionalityRedesign, idToEntityMap map[string]string,
+) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# %s\n", controller.Name)
+
+	for _, sequence := range svc.buildSequences(controller, initialRedesign, sagaRedesign, idToEntityMap) {
+		fmt.Fprintf(&builder, "\n## %s\n\n```mermaid\nsequenceDiagram\n", sequence.Title)
+
+		for _, lifeline := range sequence.Lifelines {
+			fmt.Fprintf(&builder, "    participant %s as %s\n", lifeline.ID, lifeline.Label)
+		}
+
+		if sequence.Note != "" {
+			fmt.Fprintf(&builder, "    Note over %s: %s\n", sequence.Lifelines[0].ID, escapeMermaid(sequence.Note))
+		}
+
+		for _, message := range sequence.Messages {
+			arrow := "->>"
+			if message.Reply {
+				arrow = "-->>"
+			}
+
+			if message.Pivot {
+				fmt.Fprintf(&builder, "    rect rgb(255, 228, 196)\n")
+				if message.From == message.To {
+					fmt.Fprintf(&builder, "    Note over %s: Pivot\n", message.From)
+				} else {
+					fmt.Fprintf(&builder, "    Note over %s,%s: Pivot\n", message.From, message.To)
+				}
+			}
+
+			fmt.Fprintf(&builder, "    %s%s%s: %s\n", message.From, arrow, message.To, escapeMermaid(message.Label))
+
+			if message.Pivot {
+				fmt.Fprintf(&builder, "    end\n")
+			}
+		}
+
+		fmt.Fprintf(&builder, "```\n")
+	}
+
+	return builder.String()
+}
+
+// GeneratePlantUMLDiagrams renders the initial trace and the saga of the controller as PlantUML
+// sequence diagrams, one @startuml block for each
+func (svc *DefaultHandler) GeneratePlantUMLDiagrams(
+	controller *files.Controller, initialRedesign *files.FunctionalityRedesign, sagaRedesign *files.FunctionalityRedesign, idToEntityMap map[string]string,
+) string {
+	var builder strings.Builder
+
+	for idx, sequence := range svc.buildSequences(controller, initialRedesign, sagaRedesign, idToEntityMap) {
+		if idx > 0 {
+			fmt.Fprintf(&builder, "\n")
+		}
+
+		fmt.Fprintf(&builder, "@startuml\ntitle %s - %s\n\n", controller.Name, sequence.Title)
+
+		for _, lifeline := range sequence.Lifelines {
+			fmt.Fprintf(&builder, "participant \"%s\" as %s\n", lifeline.Label, lifeline.ID)
+		}
+		fmt.Fprintf(&builder, "\n")
+
+		if sequence.Note != "" {
+			fmt.Fprintf(&builder, "note over %s : %s\n", sequence.Lifelines[0].ID, sequence.Note)
+		}
+
+		for _, message := range sequence.Messages {
+			arrow := "->"
+			if message.Reply {
+				arrow = "-->"
+			}
+
+			if message.Pivot {
+				fmt.Fprintf(&builder, "%s -[#OrangeRed]> %s : <b>%s</b>\n", message.From, message.To, message.Label)
+				fmt.Fprintf(&builder, "note right #FFE4C4: pivot transaction\n")
+				continue
+			}
+
+			fmt.Fprintf(&builder, "%s %s %s : %s\n", message.From, arrow, message.To, message.Label)
+		}
+
+		fmt.Fprintf(&builder, "@enduml\n")
+	}
+
+	return builder.String()
+}
+
+func (svc *DefaultHandler) buildSequences(
+	controller *files.Controller, initialRedesign *files.FunctionalityRedesign, sagaRedesign *files.FunctionalityRedesign, idToEntityMap map[string]string,
+) []*sequence {
+	initialSequence := svc.buildTraceSequence(controller, initialRedesign, idToEntityMap)
+	initialSequence.Title = "Initial redesign"
+
+	var sagaSequence *sequence
+	if sagaRedesign.Choreography {
+		sagaSequence = svc.buildTraceSequence(controller, sagaRedesign, idToEntityMap)
+		sagaSequence.Title = "Choreographed saga"
+	} else {
+		sagaSequence = svc.buildOrchestratedSequence(sagaRedesign, idToEntityMap)
+		sagaSequence.Title = fmt.Sprintf("Saga orchestrated by cluster %d", sagaRedesign.OrchestratorID)
+	}
+
+	return []*sequence{initialSequence, sagaSequence}
+}
+
+// buildTraceSequence follows the trace, where each invocation is called by the previous one
+func (svc *DefaultHandler) buildTraceSequence(controller *files.Controller, redesign *files.FunctionalityRedesign, idToEntityMap map[string]string) *sequence {
+	sequence := &sequence{
+		Lifelines: []*lifeline{{ID: "F", Label: controller.Name}},
+		Messages:  []*message{},
+	}
+
+	pivotIdx := redesign.GetPivotInvocationIdx()
+	if pivotIdx == -1 {
+		sequence.Note = readOnlyNote
+	}
+
+	caller := "F"
+	for idx, invocation := range redesign.Redesign {
+		if invocation.ClusterID == -1 {
+			continue
+		}
+
+		callee := clusterLifelineID(invocation.ClusterID)
+		sequence.Messages = append(sequence.Messages, &message{
+			From:  caller,
+			To:    callee,
+			Label: invocationLabel(redesign, idx, idToEntityMap),
+			Pivot: idx == pivotIdx,
+		})
+		caller = callee
+	}
+
+	sequence.Lifelines = append(sequence.Lifelines, clusterLifelines(redesign, -1)...)
+	return sequence
+}
+
+// buildOrchestratedSequence sends a command and waits for a reply for each participant invocation,
+// while the invocations of the orchestrator are executed locally
+func (svc *DefaultHandler) buildOrchestratedSequence(redesign *files.FunctionalityRedesign, idToEntityMap map[string]string) *sequence {
+	orchestrator := clusterLifelineID(redesign.OrchestratorID)
+	sequence := &sequence{
+		Lifelines: []*lifeline{{ID: orchestrator, Label: fmt.Sprintf("Orchestrator (Cluster %d)", redesign.OrchestratorID)}},
+		Messages:  []*message{},
+	}
+
+	pivotIdx := redesign.GetPivotInvocationIdx()
+	if pivotIdx == -1 {
+		sequence.Note = readOnlyNote
+	}
+
+	for idx, invocation := range redesign.Redesign {
+		if invocation.ClusterID == -1 || len(invocation.ClusterAccesses) == 0 {
+			continue
+		}
+
+		participant := clusterLifelineID(invocation.ClusterID)
+		sequence.Messages = append(sequence.Messages, &message{
+			From:  orchestrator,
+			To:    participant,
+			Label: invocationLabel(redesign, idx, idToEntityMap),
+			Pivot: idx == pivotIdx,
+		})
+
+		if participant != orchestrator {
+			sequence.Messages = append(sequence.Messages, &message{
+				From:  participant,
+				To:    orchestrator,
+				Label: "reply",
+				Reply: true,
+			})
+		}
+	}
+
+	sequence.Lifelines = append(sequence.Lifelines, clusterLifelines(redesign, redesign.OrchestratorID)...)
+	return sequence
+}
+
+func clusterLifelines(redesign *files.FunctionalityRedesign, excludedClusterID int) []*lifeline {
+	clusterIDs := []int{}
+	found := map[int]bool{}
+	for _, invocation := range redesign.Redesign {
+		if invocation.ClusterID == -1 || invocation.ClusterID == excludedClusterID || found[invocation.ClusterID] {
+			continue
+		}
+		found[invocation.ClusterID] = true
+		clusterIDs = append(clusterIDs, invocation.ClusterID)
+	}
+	sort.Ints(clusterIDs)
+
+	lifelines := []*lifeline{}
+	for _, clusterID := range clusterIDs {
+		lifelines = append(lifelines, &lifeline{
+			ID:    clusterLifelineID(clusterID),
+			Label: fmt.Sprintf("Cluster %d", clusterID),
+		})
+	}
+	return lifelines
+}
+
+func clusterLifelineID(clusterID int) string {
+	return fmt.Sprintf("C%d", clusterID)
+}
+
+func invocationLabel(redesign *files.FunctionalityRedesign, idx int, idToEntityMap map[string]string) string {
+	invocation := redesign.Redesign[idx]
+
+	accesses := []string{}
+	for accessIdx := range invocation.ClusterAccesses {
+		entityID := strconv.Itoa(invocation.GetAccessEntityID(accessIdx))
+		entityName, found := idToEntityMap[entityID]
+		if !found {
+			entityName = entityID
+		}
+		accesses = append(accesses, fmt.Sprintf("%s (%s)", entityName, invocation.GetAccessType(accessIdx)))
+	}
+
+	// without a pivot the steps only read, so they are neither compensated nor retried after it
+	stepType := redesign.GetStepType(idx)
+	if redesign.GetPivotInvocationIdx() == -1 {
+		stepType = "READ-ONLY"
+	}
+
+	return fmt.Sprintf("[%s] %s", stepType, strings.Join(accesses, ", "))
+}
+
+func escapeMermaid(label string) string {
+	return strings.NewReplacer(";", ",", "#", "").Replace(label)
+}
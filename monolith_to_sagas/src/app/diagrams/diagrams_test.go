@@ -0,0 +1,85 @@
+package diagrams_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/common/log"
+	"automation/app/diagrams"
+	"automation/app/files"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// assertGolden compares the diagram with the golden file of the testdata folder, which is
+// rewritten instead when the tests run with -update
+func assertGolden(t *testing.T, name string, diagram string) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(diagram), 0644))
+	}
+
+	golden, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(golden), diagram)
+}
+
+func TestGenerateDiagrams(t *testing.T) {
+	handler := diagrams.New(log.NewNopLogger())
+
+	orderInitialRedesign := fixtures.Trace("OrderController.placeOrder",
+		fixtures.Invocation(0, []interface{}{"R", 1}),
+		fixtures.Invocation(1, []interface{}{"W", 2}),
+		fixtures.Invocation(0, []interface{}{"W", 1}),
+		fixtures.Invocation(2, []interface{}{"R", 3}),
+	)
+	catalogInitialRedesign := fixtures.Trace("CatalogController.listProducts",
+		fixtures.Invocation(0, []interface{}{"R", 1}),
+		fixtures.Invocation(1, []interface{}{"R", 2}),
+	)
+
+	cases := []struct {
+		golden          string
+		controller      *files.Controller
+		initialRedesign *files.FunctionalityRedesign
+		sagaRedesign    *files.FunctionalityRedesign
+		idToEntityMap   map[string]string
+	}{
+		{
+			"orchestrated",
+			&files.Controller{Name: "OrderController.placeOrder"},
+			orderInitialRedesign,
+			&files.FunctionalityRedesign{
+				Name:           "OrderController.placeOrder",
+				OrchestratorID: 0,
+				Redesign: []*files.Invocation{
+					{ID: 0, ClusterID: 0, ClusterAccesses: [][]interface{}{{"RW", 1}}},
+					{ID: 1, ClusterID: 1, ClusterAccesses: [][]interface{}{{"W", 2}}},
+					{ID: 2, ClusterID: 0},
+					{ID: 3, ClusterID: 2, ClusterAccesses: [][]interface{}{{"R", 3}}},
+				},
+			},
+			map[string]string{"1": "Order", "2": "Payment", "3": "Shipment"},
+		},
+		{
+			"read-only",
+			&files.Controller{Name: "CatalogController.listProducts"},
+			catalogInitialRedesign,
+			&files.FunctionalityRedesign{
+				Name:         "CatalogController.listProducts",
+				Choreography: true,
+				Redesign:     catalogInitialRedesign.Redesign,
+			},
+			map[string]string{"1": "Product", "2": "Stock"},
+		},
+	}
+
+	for _, c := range cases {
+		assertGolden(t, c.golden+".md", handler.GenerateMermaidDiagrams(c.controller, c.initialRedesign, c.sagaRedesign, c.idToEntityMap))
+		assertGolden(t, c.golden+".puml", handler.GeneratePlantUMLDiagrams(c.controller, c.initialRedesign, c.sagaRedesign, c.idToEntityMap))
+	}
+}
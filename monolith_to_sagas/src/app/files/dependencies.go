@@ -0,0 +1,144 @@
+package files
+
+import "sort"
+
+// DependencyGraph has an edge from an invocation to each later invocation that depends on its
+// data. Invocations are identified by their index in the redesign and the depth of an invocation
+// is the length of the longest path of dependencies that ends in it.
+type DependencyGraph struct {
+	Nodes []*DependencyNode `json:"nodes"`
+	Edges []*DependencyEdge `json:"edges"`
+}
+
+type DependencyNode struct {
+	Invocation int `json:"invocation"`
+	ClusterID  int `json:"cluster_id"`
+	Depth      int `json:"depth"`
+}
+
+// DependencyEdge goes from an invocation to a later one that depends on its data. The shared
+// entities are accessed by both and written by at least one of them, so their order cannot change.
+// The read entities are read by the first one when the second one writes, which may use the
+// values read to decide what to write.
+type DependencyEdge struct {
+	From           int   `json:"from"`
+	To             int   `json:"to"`
+	SharedEntities []int `json:"shared_entities,omitempty"`
+	ReadEntities   []int `json:"read_entities,omitempty"`
+}
+
+// IsReadDependency checks if the later invocation may depend on the values read by the earlier one
+func (e *DependencyEdge) IsReadDependency() bool {
+	return len(e.ReadEntities) > 0
+}
+
+// InvocationDependencies returns the edges from the previous invocations the invocation depends
+// on, from the closest to the furthest one. Invocations without accesses do not have dependencies.
+func InvocationDependencies(invocations []*Invocation, idx int) []*DependencyEdge {
+	dependencies := []*DependencyEdge{}
+	for prevIdx := idx - 1; prevIdx >= 0; prevIdx-- {
+		if edge := dependencyEdge(invocations, prevIdx, idx); edge != nil {
+			dependencies = append(dependencies, edge)
+		}
+	}
+	return dependencies
+}
+
+// DependsOn checks if the invocation depends on the data of the earlier invocation
+func (i *Invocation) DependsOn(earlier *Invocation) bool {
+	sharedEntities, readEntities := dependencyEntities(earlier, i)
+	return len(sharedEntities) > 0 || len(readEntities) > 0
+}
+
+func BuildDependencyGraph(invocations []*Invocation) *DependencyGraph {
+	graph := &DependencyGraph{
+		Nodes: []*DependencyNode{},
+		Edges: []*DependencyEdge{},
+	}
+
+	depths := make([]int, len(invocations))
+	for idx, invocation := range invocations {
+		if invocation.ClusterID == -1 {
+			continue
+		}
+
+		dependencies := InvocationDependencies(invocations, idx)
+		sort.Slice(dependencies, func(i, j int) bool {
+			return dependencies[i].From < dependencies[j].From
+		})
+
+		for _, edge := range dependencies {
+			if depths[edge.From]+1 > depths[idx] {
+				depths[idx] = depths[edge.From] + 1
+			}
+			graph.Edges = append(graph.Edges, edge)
+		}
+
+		graph.Nodes = append(graph.Nodes, &DependencyNode{
+			Invocation: idx,
+			ClusterID:  invocation.ClusterID,
+			Depth:      depths[idx],
+		})
+	}
+
+	return graph
+}
+
+// ClusterDepths returns the highest depth of the invocations of each cluster
+func (g *DependencyGraph) ClusterDepths() map[int]int {
+	depths := map[int]int{}
+	for _, node := range g.Nodes {
+		if depth, found := depths[node.ClusterID]; !found || node.Depth > depth {
+			depths[node.ClusterID] = node.Depth
+		}
+	}
+	return depths
+}
+
+func dependencyEdge(invocations []*Invocation, from int, to int) *DependencyEdge {
+	earlier := invocations[from]
+	later := invocations[to]
+	if earlier.ClusterID == -1 || later.ClusterID == -1 {
+		return nil
+	}
+
+	sharedEntities, readEntities := dependencyEntities(earlier, later)
+	if len(sharedEntities) == 0 && len(readEntities) == 0 {
+		return nil
+	}
+
+	return &DependencyEdge{
+		From:           from,
+		To:             to,
+		SharedEntities: sharedEntities,
+		ReadEntities:   readEntities,
+	}
+}
+
+func dependencyEntities(earlier *Invocation, later *Invocation) ([]int, []int) {
+	sharedEntities := []int{}
+	for idx := range earlier.ClusterAccesses {
+		entityID := earlier.GetAccessEntityID(idx)
+		for laterIdx := range later.ClusterAccesses {
+			if later.GetAccessEntityID(laterIdx) != entityID {
+				continue
+			}
+
+			if earlier.GetAccessType(idx) != "R" || later.GetAccessType(laterIdx) != "R" {
+				sharedEntities = append(sharedEntities, entityID)
+				break
+			}
+		}
+	}
+
+	readEntities := []int{}
+	if later.ContainsLock() {
+		for idx := range earlier.ClusterAccesses {
+			if accessType := earlier.GetAccessType(idx); accessType == "R" || accessType == "RW" {
+				readEntities = append(readEntities, earlier.GetAccessEntityID(idx))
+			}
+		}
+	}
+
+	return sharedEntities, readEntities
+}
@@ -0,0 +1,60 @@
+package files_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/files"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvocationDependenciesFollowTheDataOfTheEntities(t *testing.T) {
+	invocations := []*files.Invocation{
+		fixtures.Invocation(0, []interface{}{"W", 1}),
+		fixtures.Invocation(1, []interface{}{"R", 2}),
+		fixtures.Invocation(0),
+		fixtures.Invocation(2, []interface{}{"R", 1}),
+		fixtures.Invocation(3, []interface{}{"W", 4}),
+	}
+
+	// reading an entity depends on the invocation that wrote it, but not on other reads
+	readDependencies := files.InvocationDependencies(invocations, 3)
+	assert.Len(t, readDependencies, 1)
+	assert.Equal(t, 0, readDependencies[0].From)
+	assert.Equal(t, []int{1}, readDependencies[0].SharedEntities)
+	assert.False(t, readDependencies[0].IsReadDependency())
+
+	// writing may depend on every value read before, even of other entities
+	writeDependencies := files.InvocationDependencies(invocations, 4)
+	assert.Len(t, writeDependencies, 2)
+	assert.Equal(t, 3, writeDependencies[0].From)
+	assert.Equal(t, 1, writeDependencies[1].From)
+	assert.Equal(t, []int{2}, writeDependencies[1].ReadEntities)
+	assert.Empty(t, writeDependencies[1].SharedEntities)
+
+	assert.Empty(t, files.InvocationDependencies(invocations, 2))
+	assert.True(t, invocations[4].DependsOn(invocations[1]))
+	assert.False(t, invocations[1].DependsOn(invocations[0]))
+}
+
+func TestBuildDependencyGraphCalculatesDepths(t *testing.T) {
+	invocations := []*files.Invocation{
+		fixtures.Invocation(-1),
+		fixtures.Invocation(0, []interface{}{"R", 1}),
+		fixtures.Invocation(1, []interface{}{"RW", 2}),
+		fixtures.Invocation(2, []interface{}{"R", 3}),
+		fixtures.Invocation(1, []interface{}{"R", 2}),
+	}
+
+	graph := files.BuildDependencyGraph(invocations)
+
+	assert.Len(t, graph.Nodes, 4)
+	assert.Len(t, graph.Edges, 2)
+	assert.Equal(t, 1, graph.Edges[0].From)
+	assert.Equal(t, 2, graph.Edges[0].To)
+	assert.Equal(t, []int{1}, graph.Edges[0].ReadEntities)
+	assert.Equal(t, 2, graph.Edges[1].From)
+	assert.Equal(t, 4, graph.Edges[1].To)
+	assert.Equal(t, []int{2}, graph.Edges[1].SharedEntities)
+	assert.Equal(t, map[int]int{0: 0, 1: 2, 2: 0}, graph.ClusterDepths())
+}
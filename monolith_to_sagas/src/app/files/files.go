@@ -21,6 +21,9 @@ type FilesHandler interface {
 	ReadCodebase(string) (*Codebase, error)
 	ReadIDToEntityFile(string) (map[string]string, error)
 	GenerateCSV(string, [][]string) error
+	GenerateJSON(string, interface{}) error
+	GenerateTextFile(string, string) error
+	GenerateJSONLines(string, []interface{}) error
 }
 
 type DefaultHandler struct {
@@ -109,3 +112,64 @@ func (svc *DefaultHandler) GenerateCSV(filename string, data [][]string) error {
 
 	return nil
 }
+
+func (svc *DefaultHandler) GenerateJSON(filename string, data interface{}) error {
+	path := outputPath + filename
+
+	byteValue, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		svc.logger.Log(err)
+		return err
+	}
+
+	err = ioutil.WriteFile(path, byteValue, 0644)
+	if err != nil {
+		svc.logger.Log(err)
+		return err
+	}
+
+	return nil
+}
+
+// GenerateTextFile writes the content to the file, creating its folder if needed
+func (svc *DefaultHandler) GenerateTextFile(filename string, content string) error {
+	path := outputPath + filename
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		svc.logger.Log(err)
+		return err
+	}
+
+	err = ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		svc.logger.Log(err)
+		return err
+	}
+
+	return nil
+}
+
+// GenerateJSONLines writes each record as a JSON object in its own line
+func (svc *DefaultHandler) GenerateJSONLines(filename string, records []interface{}) error {
+	path := outputPath + filename
+
+	var content []byte
+	for _, record := range records {
+		byteValue, err := json.Marshal(record)
+		if err != nil {
+			svc.logger.Log(err)
+			return err
+		}
+		content = append(content, byteValue...)
+		content = append(content, '\n')
+	}
+
+	err := ioutil.WriteFile(path, content, 0644)
+	if err != nil {
+		svc.logger.Log(err)
+		return err
+	}
+
+	return nil
+}
@@ -95,6 +95,10 @@ type Cluster struct {
 func (c *Cluster) AddCouplingDependency(clusterID int, entityID int) {
 	clusterName := strconv.Itoa(clusterID)
 
+	if c.CouplingDependencies == nil {
+		c.CouplingDependencies = map[string][]int{}
+	}
+
 	clusterDependencies, ok := c.CouplingDependencies[clusterName]
 	if !ok {
 		c.CouplingDependencies[clusterName] = []int{entityID}
@@ -185,12 +189,119 @@ type FunctionalityRedesign struct {
 	ClustersBesidesOrchestratorWithMultipleInvocations int           `json:"clusters_besides_orchestrator_with_multiple_invocations,omitempty"`
 	InitialInvocationsCount                            int           `json:"initial_invocations_count,omitempty"`
 	AccessesCount                                      int           `json:"accesses_count,omitempty"`
+	Choreography                                       bool          `json:"choreography,omitempty"`
+	EventsCount                                        int           `json:"events_count,omitempty"`
+	CommunicationCoupling                              int           `json:"communication_coupling,omitempty"`
+	DAG                                                *SagaDAG      `json:"dag,omitempty"`
+	Latency                                            float32       `json:"latency,omitempty"`
+	MessagesCount                                      int           `json:"messages_count,omitempty"`
+	MessagesSize                                       int           `json:"messages_size,omitempty"`
+
+	// Outcome of the simulated executions with injected failures
+	Failures *FailureSimulation `json:"failures,omitempty"`
+
+	// Values of the registered metrics selected in the configuration, by name
+	Metrics map[string]float32 `json:"metrics,omitempty"`
+
+	// Invocations of the monolith trace moved before the redesign, so they could be merged
+	Reorderings []*Reordering `json:"reorderings,omitempty"`
+
+	// Every attempt to merge two invocations of the redesign, when they are audited. When the
+	// invocations are reordered, only the attempts of the trace kept are recorded.
+	MergeDecisions []*MergeDecision `json:"merge_decisions,omitempty"`
+}
+
+// Reordering moves an invocation of the monolith trace, identified by its id in the trace, from
+// one position of the trace to an earlier one, next to an invocation of the same cluster
+type Reordering struct {
+	InvocationID int `json:"invocation_id"`
+	ClusterID    int `json:"cluster_id"`
+	FromPosition int `json:"from_position"`
+	ToPosition   int `json:"to_position"`
+}
+
+// MergeDecision records an attempt to merge the source invocation into the previous invocation of
+// the same cluster, the destination, identified by their indexes in the redesign at the time of the
+// attempt. The forbidding rule is empty when the invocations were merged.
+type MergeDecision struct {
+	Iteration             int    `json:"iteration"`
+	SourceInvocation      int    `json:"source_invocation"`
+	DestinationInvocation int    `json:"destination_invocation"`
+	ClusterID             int    `json:"cluster_id"`
+	Merged                bool   `json:"merged"`
+	ForbiddingRule        string `json:"forbidding_rule,omitempty"`
 }
 
 func (f *FunctionalityRedesign) GetInvocation(idx int) *Invocation {
 	return f.Redesign[idx]
 }
 
+// GetPivotInvocationIdx returns the index of the last invocation that writes entities, which is
+// the go/no-go point of the saga, or -1 if the redesign does not write any entity
+func (f *FunctionalityRedesign) GetPivotInvocationIdx() int {
+	for idx := len(f.Redesign) - 1; idx >= 0; idx-- {
+		if f.Redesign[idx].ClusterID != -1 && f.Redesign[idx].ContainsLock() {
+			return idx
+		}
+	}
+	return -1
+}
+
+// GetStepType classifies the invocation as a saga step. Steps before the pivot are compensated
+// when the saga fails, unless they only read, and the steps after the pivot are retried until
+// they succeed.
+func (f *FunctionalityRedesign) GetStepType(idx int) string {
+	pivotIdx := f.GetPivotInvocationIdx()
+	if idx == pivotIdx {
+		return "PIVOT"
+	}
+
+	if idx > pivotIdx {
+		return "RETRIABLE"
+	}
+
+	return f.Redesign[idx].GetTypeFromAccesses()
+}
+
+// SagaDAG groups the invocations of a redesign into stages, where the invocations of the same
+// stage do not depend on each other and can be executed in parallel
+type SagaDAG struct {
+	Nodes              []*SagaNode `json:"nodes,omitempty"`
+	Stages             [][]int     `json:"stages,omitempty"`
+	CriticalPathLength int         `json:"critical_path_length,omitempty"`
+	MaxFanOut          int         `json:"max_fan_out,omitempty"`
+}
+
+type SagaNode struct {
+	InvocationID int    `json:"invocation_id"`
+	ClusterID    int    `json:"cluster_id"`
+	Stage        int    `json:"stage"`
+	Type         string `json:"type,omitempty"`
+	DependsOn    []int  `json:"depends_on,omitempty"`
+}
+
+// CouplingMetrics is the coupling of a decomposition calculated from a set of traces, with the
+// entities each cluster depends on in the other clusters
+type CouplingMetrics struct {
+	Coupling         float32                     `json:"coupling"`
+	ClustersCoupling map[string]float32          `json:"clusters_coupling,omitempty"`
+	Dependencies     map[string]map[string][]int `json:"dependencies,omitempty"`
+}
+
+// FailureSimulation summarizes the simulated executions of a redesign with injected failures,
+// where the counts are averages per execution and the failure cost is the expected work wasted
+type FailureSimulation struct {
+	Runs             int     `json:"runs,omitempty"`
+	AbortedRuns      int     `json:"aborted_runs,omitempty"`
+	StalledRuns      int     `json:"stalled_runs,omitempty"`
+	AbortProbability float32 `json:"abort_probability,omitempty"`
+	Compensations    float32 `json:"compensations,omitempty"`
+	RolledBackSteps  float32 `json:"rolled_back_steps,omitempty"`
+	Retries          float32 `json:"retries,omitempty"`
+	FailureCost      float32 `json:"failure_cost,omitempty"`
+	FailureCostRank  int     `json:"failure_cost_rank,omitempty"`
+}
+
 type Invocation struct {
 	Name                                  string          `json:"name,omitempty"`
 	ID                                    int             `json:"id,omitempty"`
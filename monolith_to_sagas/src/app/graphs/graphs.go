@@ -0,0 +1,233 @@
+package graphs
+
+import (
+	"automation/app/configuration"
+	"automation/app/files"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+)
+
+type GraphsHandler interface {
+	GenerateCouplingGraph(*files.Decomposition) string
+	GenerateOrchestrationGraph(*files.Decomposition, []*configuration.FunctionalityResult) string
+	GenerateDependencyGraph(string, *files.FunctionalityRedesign, *files.DependencyGraph, map[string]string) string
+	RenderSVG(string) (string, error)
+}
+
+type DefaultHandler struct {
+	logger log.Logger
+}
+
+func New(logger log.Logger) GraphsHandler {
+	return &DefaultHandler{
+		logger: log.With(logger, "module", "graphsHandler"),
+	}
+}
+
+// GenerateCouplingGraph creates a DOT graph where each edge goes from a cluster to a cluster it
+// depends on, weighted by the number of distinct entities of the second that the first depends on
+func (svc *DefaultHandler) GenerateCouplingGraph(decomposition *files.Decomposition) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "digraph \"%s\" {\n", escapeDOT(decomposition.Name+" coupling"))
+	fmt.Fprintf(&builder, "  node [shape=box, style=rounded];\n")
+
+	var maxWeight int
+	for _, cluster := range decomposition.Clusters {
+		for dependencyName, entities := range cluster.CouplingDependencies {
+			if dependencyName != cluster.Name && len(entities) > maxWeight {
+				maxWeight = len(entities)
+			}
+		}
+	}
+
+	for _, clusterName := range sortedClusterNames(decomposition) {
+		cluster := decomposition.Clusters[clusterName]
+		fmt.Fprintf(&builder, "  \"%s\" [label=\"Cluster %s\\n%d entities\"];\n", clusterNodeID(clusterName), clusterName, len(cluster.Entities))
+	}
+
+	for _, clusterName := range sortedClusterNames(decomposition) {
+		cluster := decomposition.Clusters[clusterName]
+
+		dependencyNames := []string{}
+		for dependencyName := range cluster.CouplingDependencies {
+			if dependencyName != clusterName {
+				dependencyNames = append(dependencyNames, dependencyName)
+			}
+		}
+		sort.Strings(dependencyNames)
+
+		for _, dependencyName := range dependencyNames {
+			weight := len(cluster.CouplingDependencies[dependencyName])
+			fmt.Fprintf(&builder, "  \"%s\" -> \"%s\" [label=\"%d\", weight=%d, penwidth=%.2f];\n",
+				clusterNodeID(clusterName), clusterNodeID(dependencyName), weight, weight, penWidth(weight, maxWeight))
+		}
+	}
+
+	fmt.Fprintf(&builder, "}\n")
+	return builder.String()
+}
+
+// GenerateOrchestrationGraph creates a DOT graph connecting each cluster to the functionalities
+// it orchestrates in their best redesign. Clusters orchestrating more than twice the average
+// number of functionalities are highlighted.
+func (svc *DefaultHandler) GenerateOrchestrationGraph(decomposition *files.Decomposition, functionalities []*configuration.FunctionalityResult) string {
+	orchestratedFunctionalities := map[string][]string{}
+	var orchestratedCount int
+	for _, functionality := range functionalities {
+		bestRedesign := functionality.GetBestRedesign()
+		if functionality.Decomposition != decomposition || bestRedesign == nil || bestRedesign.Choreography {
+			continue
+		}
+
+		clusterName := strconv.Itoa(bestRedesign.OrchestratorID)
+		orchestratedFunctionalities[clusterName] = append(orchestratedFunctionalities[clusterName], functionality.Controller.Name)
+		orchestratedCount++
+	}
+
+	var averageOrchestrations float32
+	if len(orchestratedFunctionalities) > 0 {
+		averageOrchestrations = float32(orchestratedCount) / float32(len(orchestratedFunctionalities))
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "digraph \"%s\" {\n", escapeDOT(decomposition.Name+" orchestrators"))
+	fmt.Fprintf(&builder, "  rankdir=LR;\n")
+	fmt.Fprintf(&builder, "  node [shape=box, style=\"rounded,filled\", fillcolor=white];\n")
+
+	for _, clusterName := range sortedClusterNames(decomposition) {
+		count := len(orchestratedFunctionalities[clusterName])
+		fillColor := "white"
+		if count > 0 {
+			fillColor = "lightblue"
+		}
+		if averageOrchestrations > 0 && float32(count) > 2*averageOrchestrations {
+			fillColor = "salmon"
+		}
+
+		fmt.Fprintf(&builder, "  \"%s\" [label=\"Cluster %s\\norchestrates %d\", fillcolor=%s];\n", clusterNodeID(clusterName), clusterName, count, fillColor)
+	}
+
+	for _, clusterName := range sortedClusterNames(decomposition) {
+		functionalityNames := orchestratedFunctionalities[clusterName]
+		sort.Strings(functionalityNames)
+
+		for _, functionalityName := range functionalityNames {
+			fmt.Fprintf(&builder, "  \"%s\" [shape=ellipse, style=solid, label=\"%s\"];\n", functionalityNodeID(functionalityName), escapeDOT(functionalityName))
+			fmt.Fprintf(&builder, "  \"%s\" -> \"%s\";\n", clusterNodeID(clusterName), functionalityNodeID(functionalityName))
+		}
+	}
+
+	fmt.Fprintf(&builder, "}\n")
+	return builder.String()
+}
+
+// GenerateDependencyGraph creates a DOT graph of the invocations of the redesign, where each edge
+// goes from an invocation to a later one that depends on its data. Edges labeled with the entities
+// both access are solid, while the ones only due to the values read by the first are dashed.
+func (svc *DefaultHandler) GenerateDependencyGraph(
+	functionalityName string, redesign *files.FunctionalityRedesign, dependencyGraph *files.DependencyGraph, idToEntityMap map[string]string,
+) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "digraph \"%s\" {\n", escapeDOT(functionalityName+" dependencies"))
+	fmt.Fprintf(&builder, "  node [shape=box, style=rounded];\n")
+
+	for _, node := range dependencyGraph.Nodes {
+		invocation := redesign.Redesign[node.Invocation]
+		fmt.Fprintf(&builder, "  \"%s\" [label=\"%d: Cluster %d\\n%d accesses, depth %d\"];\n",
+			invocationNodeID(node.Invocation), node.Invocation, node.ClusterID, len(invocation.ClusterAccesses), node.Depth)
+	}
+
+	for _, edge := range dependencyGraph.Edges {
+		entityIDs := edge.SharedEntities
+		style := "solid"
+		if len(entityIDs) == 0 {
+			entityIDs = edge.ReadEntities
+			style = "dashed"
+		}
+
+		entityNames := []string{}
+		for _, entityID := range entityIDs {
+			entityNames = append(entityNames, idToEntityMap[strconv.Itoa(entityID)])
+		}
+
+		fmt.Fprintf(&builder, "  \"%s\" -> \"%s\" [label=\"%s\", style=%s];\n",
+			invocationNodeID(edge.From), invocationNodeID(edge.To), escapeDOT(strings.Join(entityNames, ", ")), style)
+	}
+
+	fmt.Fprintf(&builder, "}\n")
+	return builder.String()
+}
+
+// RenderSVG uses the Graphviz dot command to render the graph, which must be installed and in the
+// PATH. Otherwise, it returns an error wrapping exec.ErrNotFound.
+func (svc *DefaultHandler) RenderSVG(graph string) (string, error) {
+	path, err := exec.LookPath("dot")
+	if err != nil {
+		err = fmt.Errorf("graphviz dot command not found, install Graphviz to render SVG graphs: %w", err)
+		svc.logger.Log(err)
+		return "", err
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.Command(path, "-Tsvg")
+	cmd.Stdin = strings.NewReader(graph)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("graphviz dot command failed to render the graph: %v: %s", err, strings.TrimSpace(stderr.String()))
+		svc.logger.Log(err)
+		return "", err
+	}
+
+	return stdout.String(), nil
+}
+
+func sortedClusterNames(decomposition *files.Decomposition) []string {
+	clusterNames := []string{}
+	for clusterName := range decomposition.Clusters {
+		clusterNames = append(clusterNames, clusterName)
+	}
+
+	sort.Slice(clusterNames, func(i, j int) bool {
+		a, errA := strconv.Atoi(clusterNames[i])
+		b, errB := strconv.Atoi(clusterNames[j])
+		if errA != nil || errB != nil {
+			return clusterNames[i] < clusterNames[j]
+		}
+		return a < b
+	})
+
+	return clusterNames
+}
+
+func clusterNodeID(clusterName string) string {
+	return "cluster_" + escapeDOT(clusterName)
+}
+
+func functionalityNodeID(functionalityName string) string {
+	return "functionality_" + escapeDOT(functionalityName)
+}
+
+func invocationNodeID(invocationIdx int) string {
+	return "invocation_" + strconv.Itoa(invocationIdx)
+}
+
+func penWidth(weight int, maxWeight int) float32 {
+	if maxWeight == 0 {
+		return 1
+	}
+	return 1 + 4*float32(weight)/float32(maxWeight)
+}
+
+func escapeDOT(value string) string {
+	return strings.ReplaceAll(value, "\"", "\\\"")
+}
@@ -0,0 +1,133 @@
+package graphs_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/common/log"
+	"automation/app/configuration"
+	"automation/app/files"
+	"automation/app/graphs"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateGraphs(t *testing.T) {
+	handler := graphs.New(log.NewNopLogger())
+
+	decomposition := &files.Decomposition{
+		Name: "Shop",
+		Clusters: map[string]*files.Cluster{
+			"0": {
+				Name:     "0",
+				Entities: []int{1, 2},
+				CouplingDependencies: map[string][]int{
+					"1": {3, 4},
+				},
+			},
+			"1": {
+				Name:     "1",
+				Entities: []int{3, 4},
+				CouplingDependencies: map[string][]int{
+					"0": {1},
+					"1": {3},
+				},
+			},
+			"10": {
+				Name:     "10",
+				Entities: []int{5},
+			},
+		},
+	}
+
+	functionalities := []*configuration.FunctionalityResult{
+		{
+			Decomposition: decomposition,
+			Controller:    &files.Controller{Name: "ShopController.buy"},
+			SagaRedesigns: []*files.FunctionalityRedesign{{OrchestratorID: 1}},
+		},
+		{
+			Decomposition: decomposition,
+			Controller:    &files.Controller{Name: "ShopController.list"},
+			SagaRedesigns: []*files.FunctionalityRedesign{{Choreography: true}},
+		},
+		{
+			Decomposition: &files.Decomposition{Name: "Other"},
+			Controller:    &files.Controller{Name: "ShopController.sell"},
+			SagaRedesigns: []*files.FunctionalityRedesign{{OrchestratorID: 0}},
+		},
+	}
+
+	redesign := fixtures.Trace("ShopController.buy",
+		fixtures.Invocation(0, []interface{}{"R", 1}),
+		fixtures.Invocation(1, []interface{}{"W", 3}),
+		fixtures.Invocation(0, []interface{}{"W", 1}),
+	)
+	idToEntityMap := map[string]string{"1": "Order", "3": "Payment"}
+
+	cases := []struct {
+		name        string
+		graph       string
+		expectation string
+	}{
+		{
+			"coupling",
+			handler.GenerateCouplingGraph(decomposition),
+			`digraph "Shop coupling" {
+  node [shape=box, style=rounded];
+  "cluster_0" [label="Cluster 0\n2 entities"];
+  "cluster_1" [label="Cluster 1\n2 entities"];
+  "cluster_10" [label="Cluster 10\n1 entities"];
+  "cluster_0" -> "cluster_1" [label="2", weight=2, penwidth=5.00];
+  "cluster_1" -> "cluster_0" [label="1", weight=1, penwidth=3.00];
+}
+`,
+		},
+		{
+			"orchestrators",
+			handler.GenerateOrchestrationGraph(decomposition, functionalities),
+			`digraph "Shop orchestrators" {
+  rankdir=LR;
+  node [shape=box, style="rounded,filled", fillcolor=white];
+  "cluster_0" [label="Cluster 0\norchestrates 0", fillcolor=white];
+  "cluster_1" [label="Cluster 1\norchestrates 1", fillcolor=lightblue];
+  "cluster_10" [label="Cluster 10\norchestrates 0", fillcolor=white];
+  "functionality_ShopController.buy" [shape=ellipse, style=solid, label="ShopController.buy"];
+  "cluster_1" -> "functionality_ShopController.buy";
+}
+`,
+		},
+		{
+			"dependencies",
+			handler.GenerateDependencyGraph(redesign.Name, redesign, files.BuildDependencyGraph(redesign.Redesign), idToEntityMap),
+			`digraph "ShopController.buy dependencies" {
+  node [shape=box, style=rounded];
+  "invocation_1" [label="1: Cluster 0\n1 accesses, depth 0"];
+  "invocation_2" [label="2: Cluster 1\n1 accesses, depth 1"];
+  "invocation_3" [label="3: Cluster 0\n1 accesses, depth 1"];
+  "invocation_1" -> "invocation_2" [label="Order", style=dashed];
+  "invocation_1" -> "invocation_3" [label="Order", style=solid];
+}
+`,
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expectation, c.graph, c.name)
+	}
+
+	// the graphs are rendered last, as the rest is skipped without graphviz
+	svg, err := handler.RenderSVG(handler.GenerateCouplingGraph(decomposition))
+
+	if _, lookErr := exec.LookPath("dot"); lookErr != nil {
+		assert.True(t, errors.Is(err, exec.ErrNotFound))
+		assert.Contains(t, err.Error(), "graphviz dot command not found")
+		t.Skip("graphviz is not installed")
+	}
+
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(svg, "<svg"))
+	assert.Contains(t, svg, "Cluster 10")
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"automation/app/configuration"
 	"automation/app/files"
 	"strconv"
 	"sync"
@@ -22,10 +23,18 @@ var (
 )
 
 type MetricsHandler interface {
-	CalculateDecompositionMetrics(*files.Decomposition, *files.Controller, *files.FunctionalityRedesign)
-	CalculateControllerComplexityAndDependencies(*files.Decomposition, *files.Controller, *files.FunctionalityRedesign)
+	CalculateDecompositionMetrics(*Snapshot, *files.Decomposition, *files.Controller, *files.FunctionalityRedesign)
+	CalculateControllerComplexityAndDependencies(*Snapshot, *files.Decomposition, *files.Controller, *files.FunctionalityRedesign)
 	CalculateClusterComplexityAndCohesion(*files.Cluster)
-	CalculateRedesignComplexities(*files.Decomposition, *files.Controller, *files.FunctionalityRedesign)
+	CalculateRedesignComplexities(*Snapshot, *files.Controller, *files.FunctionalityRedesign)
+	CalculateCommunicationMetrics(*files.FunctionalityRedesign)
+	CalculateParallelismMetrics(*files.SagaDAG)
+	CalculateRedesignPerformance(*files.FunctionalityRedesign, bool, configuration.CostModel)
+	CalculateClusterCoupling(*files.Decomposition, *files.Cluster)
+	CalculateTracesCoupling(*files.Decomposition, []*files.FunctionalityRedesign) *files.CouplingMetrics
+	EvaluateRedesign(*Snapshot, string, *files.FunctionalityRedesign) *RedesignMetrics
+	EvaluateQuery(*Snapshot, string) *QueryMetrics
+	CalculateRegisteredMetrics(*files.Decomposition, *files.Controller, *files.FunctionalityRedesign, []string) error
 }
 
 type DefaultHandler struct {
@@ -38,114 +47,70 @@ func New(logger log.Logger) MetricsHandler {
 	}
 }
 
-func (svc *DefaultHandler) CalculateDecompositionMetrics(decomposition *files.Decomposition, controller *files.Controller, redesign *files.FunctionalityRedesign) {
-	var complexity float32
-	var cohesion float32
-	var coupling float32
+// CalculateDecompositionMetrics updates the metrics affected by the redesign of the controller:
+// its complexity, the coupling dependencies of its trace and the metrics of the clusters it
+// touches. The metrics of the decomposition are the averages of the controllers and clusters.
+func (svc *DefaultHandler) CalculateDecompositionMetrics(snapshot *Snapshot, decomposition *files.Decomposition, controller *files.Controller, redesign *files.FunctionalityRedesign) {
+	svc.EvaluateRedesign(snapshot, controller.Name, redesign).Apply(redesign)
 
-	for _, controller := range decomposition.Controllers {
-		svc.CalculateControllerComplexityAndDependencies(decomposition, controller, redesign)
-		svc.CalculateRedesignComplexities(decomposition, controller, redesign)
-		complexity += controller.Complexity
-	}
+	complexity := snapshot.controllerComplexity(controller.Name, redesign)
 
-	for _, cluster := range decomposition.Clusters {
-		svc.CalculateClusterComplexityAndCohesion(cluster)
-		cohesion += cluster.Cohesion
+	mapMutex.Lock()
+	defer mapMutex.Unlock()
 
-		// svc.CalculateClusterCoupling(cluster)
-		// coupling += cluster.Coupling
+	controller.Complexity = complexity
+	if len(controller.EntitiesPerCluster) > 1 {
+		addClusterCouplingDependencies(decomposition, redesign)
 	}
 
-	cluster_invocations := map[int]int{}
-	for _, invocation := range redesign.Redesign {
-		redesign.AccessesCount += len(invocation.ClusterAccesses)
-
-		if len(invocation.ClusterAccesses) > 0 {
-			redesign.InvocationsCount += 1
+	for clusterName := range controller.EntitiesPerCluster {
+		cluster, found := decomposition.Clusters[clusterName]
+		if !found {
+			continue
 		}
 
-		_, exists := cluster_invocations[invocation.ClusterID]
-		if !exists {
-			cluster_invocations[invocation.ClusterID] = 1
-		} else {
-			cluster_invocations[invocation.ClusterID] += 1
-		}
+		svc.CalculateClusterComplexityAndCohesion(cluster)
+		cluster.Coupling = clusterCoupling(decomposition, cluster.Name, cluster.CouplingDependencies)
 	}
 
-	for cluster_id, count := range cluster_invocations {
-		if count > 1 && cluster_id != redesign.OrchestratorID {
-			redesign.ClustersBesidesOrchestratorWithMultipleInvocations += 1
-		}
+	var controllersComplexity float32
+	for _, otherController := range decomposition.Controllers {
+		controllersComplexity += otherController.Complexity
 	}
 
-	decomposition.Complexity = complexity / float32(len(decomposition.Controllers))
+	var cohesion float32
+	var coupling float32
+	for _, cluster := range decomposition.Clusters {
+		cohesion += cluster.Cohesion
+		coupling += cluster.Coupling
+	}
+
+	decomposition.Complexity = controllersComplexity / float32(len(decomposition.Controllers))
 	decomposition.Cohesion = cohesion / float32(len(decomposition.Clusters))
 	decomposition.Coupling = coupling / float32(len(decomposition.Clusters))
 }
 
-func (svc *DefaultHandler) CalculateControllerComplexityAndDependencies(decomposition *files.Decomposition, controller *files.Controller, redesign *files.FunctionalityRedesign) {
-	if len(controller.EntitiesPerCluster) <= 1 {
-		controller.Complexity = 0
-		return
-	}
+func (svc *DefaultHandler) CalculateControllerComplexityAndDependencies(
+	snapshot *Snapshot, decomposition *files.Decomposition, controller *files.Controller, redesign *files.FunctionalityRedesign,
+) {
+	complexity := snapshot.controllerComplexity(controller.Name, redesign)
 
-	var complexity float32
-	for idx, invocation := range redesign.Redesign {
-		if invocation.ClusterID == -1 {
-			continue
-		}
-
-		cluster := decomposition.GetClusterFromID(invocation.ClusterID)
-		for i := idx; i < len(redesign.Redesign); i++ {
-			if len(redesign.GetInvocation(i).ClusterAccesses) > 0 {
-				mapMutex.Lock()
-				cluster.AddCouplingDependency(
-					redesign.GetInvocation(i).ClusterID,
-					redesign.GetInvocation(i).GetAccessEntityID(0),
-				)
-				mapMutex.Unlock()
-			}
-		}
-
-		if len(invocation.ClusterAccesses) == 0 {
-			continue
-		}
+	mapMutex.Lock()
+	defer mapMutex.Unlock()
 
-		controllersTouchingSameEntities := map[string]bool{}
-		for i := range invocation.ClusterAccesses {
-			mode := files.MapAccessTypeToMode(invocation.GetAccessType(i))
-			controllers := svc.controllersThatTouchEntity(decomposition, controller, invocation.GetAccessEntityID(i), mode)
-
-			for _, controller := range controllers {
-				_, alreadySaved := controllersTouchingSameEntities[controller]
-				if !alreadySaved {
-					controllersTouchingSameEntities[controller] = true
-				}
-			}
-		}
-		complexity += float32(len(controllersTouchingSameEntities))
+	controller.Complexity = complexity
+	if len(controller.EntitiesPerCluster) > 1 {
+		addClusterCouplingDependencies(decomposition, redesign)
 	}
-
-	controller.Complexity = float32(complexity)
-	return
 }
 
-func (svc *DefaultHandler) controllersThatTouchEntity(decomposition *files.Decomposition, controller *files.Controller, entityID int, mode int) []string {
-	var controllers []string
-
-	for _, otherController := range decomposition.Controllers {
-		entityMode, containsEntity := otherController.GetEntityMode(entityID)
-		if otherController.Name == controller.Name || len(otherController.EntitiesPerCluster) <= 1 || !containsEntity {
-			continue
+func addClusterCouplingDependencies(decomposition *files.Decomposition, redesign *files.FunctionalityRedesign) {
+	addCouplingDependencies(redesign, func(fromClusterID int, toClusterID int, entityID int) {
+		cluster := decomposition.GetClusterFromID(fromClusterID)
+		if cluster != nil {
+			cluster.AddCouplingDependency(toClusterID, entityID)
 		}
-
-		if entityMode != mode {
-			controllers = append(controllers, otherController.Name)
-		}
-	}
-
-	return controllers
+	})
 }
 
 func (svc *DefaultHandler) CalculateClusterComplexityAndCohesion(cluster *files.Cluster) {
@@ -173,115 +138,230 @@ func (svc *DefaultHandler) CalculateClusterComplexityAndCohesion(cluster *files.
 	return
 }
 
-func (svc *DefaultHandler) CalculateRedesignComplexities(decomposition *files.Decomposition, controller *files.Controller, redesign *files.FunctionalityRedesign) {
-	if controller.Type == Query {
-		svc.queryRedesignComplexity(decomposition, controller, redesign)
-	} else {
-		svc.sagasRedesignComplexity(decomposition, controller, redesign)
-	}
+func (svc *DefaultHandler) CalculateRedesignComplexities(snapshot *Snapshot, controller *files.Controller, redesign *files.FunctionalityRedesign) {
+	snapshot.redesignComplexities(controller.Name, controller.Type, redesign).applyComplexities(redesign)
 }
 
-func (svc *DefaultHandler) queryRedesignComplexity(decomposition *files.Decomposition, controller *files.Controller, redesign *files.FunctionalityRedesign) {
-	entitiesRead := controller.EntitiesTouchedInMode(files.MapAccessTypeToMode("R"))
+// CalculateCommunicationMetrics counts the messages exchanged between clusters when the redesign
+// is executed and the number of distinct pairs of clusters that communicate with each other.
+// An orchestrated saga sends a command and receives a reply for each remote invocation, while a
+// choreographed saga publishes a single event each time a participant hands off to the next one.
+func (svc *DefaultHandler) CalculateCommunicationMetrics(redesign *files.FunctionalityRedesign) {
+	redesign.EventsCount, redesign.CommunicationCoupling = communicationMetrics(redesign)
+}
 
-	var inconsistencyComplexity int
-	for _, otherController := range decomposition.Controllers {
-		var entitiesReadThatAreWrittenInOther []int
-		var clustersInCommon []*files.Cluster
+func communicationMetrics(redesign *files.FunctionalityRedesign) (int, int) {
+	var eventsCount int
+	coupledClusters := map[[2]int]bool{}
 
-		if otherController.Name == controller.Name || len(otherController.EntitiesPerCluster) <= 1 || otherController.Type != "SAGA" {
+	for idx, invocation := range redesign.Redesign {
+		if invocation.ClusterID == -1 {
 			continue
 		}
 
-		entitiesWritten := otherController.EntitiesTouchedInMode(files.MapAccessTypeToMode("W"))
-		for entity := range entitiesRead {
-			_, written := entitiesWritten[entity]
-			if written {
-				entitiesReadThatAreWrittenInOther = append(entitiesReadThatAreWrittenInOther, entity)
+		if !redesign.Choreography {
+			if invocation.ClusterID == redesign.OrchestratorID || len(invocation.ClusterAccesses) == 0 {
+				continue
 			}
-		}
 
-		for entityID := range entitiesReadThatAreWrittenInOther {
-			cluster := decomposition.GetEntityCluster(entityID)
-			clustersInCommon = append(clustersInCommon, cluster)
+			eventsCount += 2
+			coupledClusters[clusterPair(redesign.OrchestratorID, invocation.ClusterID)] = true
+			continue
 		}
 
-		if len(clustersInCommon) > 1 {
-			inconsistencyComplexity += len(clustersInCommon)
+		if idx == 0 || redesign.Redesign[idx-1].ClusterID == invocation.ClusterID {
+			continue
 		}
+
+		eventsCount++
+		coupledClusters[clusterPair(redesign.Redesign[idx-1].ClusterID, invocation.ClusterID)] = true
 	}
 
-	redesign.InconsistencyComplexity = inconsistencyComplexity
+	return eventsCount, len(coupledClusters)
 }
 
-func (svc *DefaultHandler) sagasRedesignComplexity(decomposition *files.Decomposition, controller *files.Controller, redesign *files.FunctionalityRedesign) {
-	var functionalityComplexity int
-	var systemComplexity int
+func clusterPair(a int, b int) [2]int {
+	if a > b {
+		return [2]int{b, a}
+	}
+	return [2]int{a, b}
+}
 
-	for _, invocation := range redesign.Redesign {
-		var controllerstThatReadInWrittenEntities int
-		var controllersThatWriteInReadEntities int
+// CalculateParallelismMetrics calculates the number of invocations in the longest chain of
+// dependencies of the DAG and the highest number of invocations that depend on the same one.
+// Nodes are expected to be ordered so that every node comes after the ones it depends on.
+func (svc *DefaultHandler) CalculateParallelismMetrics(dag *files.SagaDAG) {
+	pathLengths := map[int]int{}
+	dependents := map[int]int{}
+
+	var criticalPathLength int
+	var maxFanOut int
+	for _, node := range dag.Nodes {
+		pathLength := 1
+		for _, dependency := range node.DependsOn {
+			if pathLengths[dependency]+1 > pathLength {
+				pathLength = pathLengths[dependency] + 1
+			}
 
-		for i := range invocation.ClusterAccesses {
-			entity := invocation.GetAccessEntityID(i)
-			mode := files.MapAccessTypeToMode(invocation.GetAccessType(i))
+			dependents[dependency]++
+			if dependents[dependency] > maxFanOut {
+				maxFanOut = dependents[dependency]
+			}
+		}
 
-			if mode >= WriteMode { // 2 -> W, 3 -> RW
-				if invocation.Type == "COMPENSATABLE" {
-					systemComplexityResult := svc.systemComplexity(decomposition, controller, redesign, entity)
+		pathLengths[node.InvocationID] = pathLength
+		if pathLength > criticalPathLength {
+			criticalPathLength = pathLength
+		}
+	}
 
-					controllerstThatReadInWrittenEntities += systemComplexityResult
-					systemComplexity += systemComplexityResult
+	// the saga starts by forking into every invocation of the first stage
+	if len(dag.Stages) > 0 && len(dag.Stages[0]) > maxFanOut {
+		maxFanOut = len(dag.Stages[0])
+	}
 
-					functionalityComplexity++
-				}
+	dag.CriticalPathLength = criticalPathLength
+	dag.MaxFanOut = maxFanOut
+}
+
+// CalculateRedesignPerformance estimates the latency and the messages exchanged when executing
+// the redesign. In an orchestrated saga each participant invocation is a remote invocation of a
+// command and a reply, otherwise each change of cluster between consecutive invocations is a remote
+// invocation of a single message. Each remote invocation costs the latency of a round trip, no
+// matter its number of messages. When the redesign has a DAG, the invocations of the same stage
+// are executed in parallel.
+func (svc *DefaultHandler) CalculateRedesignPerformance(redesign *files.FunctionalityRedesign, orchestrated bool, costModel configuration.CostModel) {
+	invocationLatencies := map[int]float32{}
+	var latency float32
+	var messagesCount int
+	var messagesSize int
+
+	var prevInvocation *files.Invocation
+	for _, invocation := range redesign.Redesign {
+		if invocation.ClusterID == -1 {
+			continue
+		}
+
+		var messages int
+		if orchestrated {
+			if invocation.ClusterID != redesign.OrchestratorID && len(invocation.ClusterAccesses) > 0 {
+				messages = 2
 			}
+		} else if prevInvocation != nil && prevInvocation.ClusterID != invocation.ClusterID {
+			messages = 1
+		}
+		prevInvocation = invocation
 
-			if mode != WriteMode { // 1 -> R
-				costOfRead := svc.costOfRead(decomposition, controller, redesign, entity)
+		invocationLatency := float32(len(invocation.ClusterAccesses)) * costModel.LocalAccessCost
+		if messages > 0 {
+			invocationLatency += costModel.RemoteInvocationLatency
+		}
+		invocationLatencies[invocation.ID] = invocationLatency
+		latency += invocationLatency
 
-				controllersThatWriteInReadEntities += costOfRead
+		messagesCount += messages
+		messagesSize += messages * (costModel.MessageHeaderSize + len(invocation.ClusterAccesses)*costModel.EntityAccessSize)
+	}
 
-				functionalityComplexity += costOfRead
+	if redesign.DAG != nil {
+		latency = 0
+		for _, stage := range redesign.DAG.Stages {
+			var stageLatency float32
+			for _, invocationID := range stage {
+				if invocationLatencies[invocationID] > stageLatency {
+					stageLatency = invocationLatencies[invocationID]
+				}
 			}
+			latency += stageLatency
 		}
-
-		invocation.ControllerstThatReadInWrittenEntities = controllerstThatReadInWrittenEntities
-		invocation.ControllersThatWriteInReadEntities = controllersThatWriteInReadEntities
 	}
 
-	redesign.FunctionalityComplexity = functionalityComplexity
-	redesign.SystemComplexity = systemComplexity
+	redesign.Latency = latency
+	redesign.MessagesCount = messagesCount
+	redesign.MessagesSize = messagesSize
 }
 
-func (svc *DefaultHandler) systemComplexity(decomposition *files.Decomposition, controller *files.Controller, redesign *files.FunctionalityRedesign, entity int) int {
-	var systemComplexity int
+// CalculateClusterCoupling follows the definition of Mono2Micro, where the coupling of a cluster
+// is the average, over the other clusters, of the fraction of their entities it depends on
+func (svc *DefaultHandler) CalculateClusterCoupling(decomposition *files.Decomposition, cluster *files.Cluster) {
+	mapMutex.RLock()
+	cluster.Coupling = clusterCoupling(decomposition, cluster.Name, cluster.CouplingDependencies)
+	mapMutex.RUnlock()
+}
 
-	for _, otherController := range decomposition.Controllers {
-		mode, containsEntity := otherController.GetEntityMode(entity)
-		if otherController.Name == controller.Name || !containsEntity || mode == WriteMode {
-			continue
-		}
+// CalculateTracesCoupling calculates the coupling of the decomposition from the given traces
+// alone, without changing the dependencies collected in the clusters, so the coupling of the
+// monolith traces can be compared with the coupling of the redesigned ones
+func (svc *DefaultHandler) CalculateTracesCoupling(decomposition *files.Decomposition, traces []*files.FunctionalityRedesign) *files.CouplingMetrics {
+	couplingMetrics := &files.CouplingMetrics{
+		ClustersCoupling: map[string]float32{},
+		Dependencies:     map[string]map[string][]int{},
+	}
+
+	for _, trace := range traces {
+		addCouplingDependencies(trace, func(fromClusterID int, toClusterID int, entityID int) {
+			fromClusterName := strconv.Itoa(fromClusterID)
+			toClusterName := strconv.Itoa(toClusterID)
+
+			dependencies, found := couplingMetrics.Dependencies[fromClusterName]
+			if !found {
+				dependencies = map[string][]int{}
+				couplingMetrics.Dependencies[fromClusterName] = dependencies
+			}
+
+			for _, id := range dependencies[toClusterName] {
+				if id == entityID {
+					return
+				}
+			}
+			dependencies[toClusterName] = append(dependencies[toClusterName], entityID)
+		})
+	}
 
-		systemComplexity++
+	if len(decomposition.Clusters) == 0 {
+		return couplingMetrics
 	}
 
-	return systemComplexity
+	var coupling float32
+	for clusterName := range decomposition.Clusters {
+		clusterCoupling := clusterCoupling(decomposition, clusterName, couplingMetrics.Dependencies[clusterName])
+		couplingMetrics.ClustersCoupling[clusterName] = clusterCoupling
+		coupling += clusterCoupling
+	}
+	couplingMetrics.Coupling = coupling / float32(len(decomposition.Clusters))
+
+	return couplingMetrics
 }
 
-func (svc *DefaultHandler) costOfRead(decomposition *files.Decomposition, controller *files.Controller, redesign *files.FunctionalityRedesign, entity int) int {
-	var functionalityComplexity int
-	for _, otherController := range decomposition.Controllers {
-		mode, containsEntity := otherController.GetEntityMode(entity)
-		if otherController.Name == controller.Name || len(otherController.EntitiesPerCluster) <= 1 || !containsEntity {
+// addCouplingDependencies finds the dependencies of a trace, where a cluster depends on the
+// first entity accessed by the next invocation when it belongs to a different cluster
+func addCouplingDependencies(trace *files.FunctionalityRedesign, addDependency func(int, int, int)) {
+	var prevInvocation *files.Invocation
+	for _, invocation := range trace.Redesign {
+		if invocation.ClusterID == -1 || len(invocation.ClusterAccesses) == 0 {
 			continue
 		}
 
-		mode, exists := otherController.GetEntityMode(entity)
-		if exists && mode >= WriteMode {
-			functionalityComplexity++
+		if prevInvocation != nil && prevInvocation.ClusterID != invocation.ClusterID {
+			addDependency(prevInvocation.ClusterID, invocation.ClusterID, invocation.GetAccessEntityID(0))
+		}
+		prevInvocation = invocation
+	}
+}
+
+func clusterCoupling(decomposition *files.Decomposition, clusterName string, dependencies map[string][]int) float32 {
+	if len(decomposition.Clusters) <= 1 {
+		return 0
+	}
+
+	var coupling float32
+	for dependencyName, entities := range dependencies {
+		dependency, found := decomposition.Clusters[dependencyName]
+		if dependencyName == clusterName || !found || len(dependency.Entities) == 0 {
+			continue
 		}
+		coupling += float32(len(entities)) / float32(len(dependency.Entities))
 	}
 
-	return functionalityComplexity
+	return coupling / float32(len(decomposition.Clusters)-1)
 }
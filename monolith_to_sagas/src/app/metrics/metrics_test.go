@@ -0,0 +1,296 @@
+package metrics_test
+
+import (
+	"automation/app/common/log"
+	"automation/app/configuration"
+	"automation/app/files"
+	"automation/app/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateTracesCoupling(t *testing.T) {
+	handler := metrics.New(log.NewNopLogger())
+
+	decomposition := &files.Decomposition{
+		Clusters: map[string]*files.Cluster{
+			"0": {Name: "0", Entities: []int{1, 2}},
+			"1": {Name: "1", Entities: []int{3, 4, 5, 6}},
+			"2": {Name: "2", Entities: []int{7}},
+		},
+	}
+
+	traces := []*files.FunctionalityRedesign{
+		{
+			Redesign: []*files.Invocation{
+				{ID: -1, ClusterID: -1},
+				{ID: 0, ClusterID: 0, ClusterAccesses: [][]interface{}{{"R", float64(1)}}},
+				{ID: 1, ClusterID: 1, ClusterAccesses: [][]interface{}{{"W", float64(3)}, {"R", float64(4)}}},
+				{ID: 2, ClusterID: 0, ClusterAccesses: [][]interface{}{{"W", float64(2)}}},
+			},
+		},
+		{
+			Redesign: []*files.Invocation{
+				{ID: -1, ClusterID: -1},
+				{ID: 0, ClusterID: 0, ClusterAccesses: [][]interface{}{{"R", float64(2)}}},
+				{ID: 1, ClusterID: 1, ClusterAccesses: [][]interface{}{{"R", float64(5)}}},
+				{ID: 2, ClusterID: 1, ClusterAccesses: [][]interface{}{{"R", float64(6)}}},
+				{ID: 3, ClusterID: 2, ClusterAccesses: [][]interface{}{{"R", float64(7)}}},
+			},
+		},
+	}
+
+	coupling := handler.CalculateTracesCoupling(decomposition, traces)
+
+	// cluster 0 depends on entities 3 and 5 of cluster 1, cluster 1 on entity 2 of cluster 0 and
+	// entity 7 of cluster 2, and cluster 2 does not depend on any other cluster
+	assert.Equal(t, map[string][]int{"1": {3, 5}}, coupling.Dependencies["0"])
+	assert.Equal(t, map[string][]int{"0": {2}, "2": {7}}, coupling.Dependencies["1"])
+	assert.InDelta(t, 0.25, coupling.ClustersCoupling["0"], 0.0001)
+	assert.InDelta(t, 0.75, coupling.ClustersCoupling["1"], 0.0001)
+	assert.InDelta(t, 0, coupling.ClustersCoupling["2"], 0.0001)
+	assert.InDelta(t, 1.0/3, coupling.Coupling, 0.0001)
+
+	// the dependencies of the traces are not kept in the clusters
+	assert.Empty(t, decomposition.Clusters["0"].CouplingDependencies)
+}
+
+func TestCalculateClusterCoupling(t *testing.T) {
+	handler := metrics.New(log.NewNopLogger())
+
+	decomposition := &files.Decomposition{
+		Clusters: map[string]*files.Cluster{
+			"0": {Name: "0", Entities: []int{1}, CouplingDependencies: map[string][]int{"0": {1}, "1": {2}}},
+			"1": {Name: "1", Entities: []int{2, 3}},
+		},
+	}
+
+	handler.CalculateClusterCoupling(decomposition, decomposition.Clusters["0"])
+	assert.InDelta(t, 0.5, decomposition.Clusters["0"].Coupling, 0.0001)
+}
+
+func newSnapshotDecomposition() *files.Decomposition {
+	return &files.Decomposition{
+		Clusters: map[string]*files.Cluster{
+			"0": {Name: "0", Entities: []int{1}},
+			"1": {Name: "1", Entities: []int{2}},
+		},
+		Controllers: map[string]*files.Controller{
+			"Writer": {
+				Name:               "Writer",
+				Type:               metrics.Saga,
+				Entities:           map[string]int{"1": metrics.WriteMode, "2": metrics.ReadMode},
+				EntitiesPerCluster: map[string][]int{"0": {1}, "1": {2}},
+			},
+			"Reader": {
+				Name:               "Reader",
+				Type:               metrics.Saga,
+				Entities:           map[string]int{"1": metrics.ReadMode, "2": metrics.WriteMode},
+				EntitiesPerCluster: map[string][]int{"0": {1}, "1": {2}},
+			},
+		},
+		EntityIDToClusterName: map[string]string{"1": "0", "2": "1"},
+	}
+}
+
+func TestEvaluateRedesign(t *testing.T) {
+	handler := metrics.New(log.NewNopLogger())
+	decomposition := newSnapshotDecomposition()
+	snapshot := metrics.NewSnapshot(decomposition)
+
+	redesign := &files.FunctionalityRedesign{
+		OrchestratorID: 0,
+		Redesign: []*files.Invocation{
+			{ID: 0, ClusterID: 0, Type: metrics.Compensatable, ClusterAccesses: [][]interface{}{{"W", float64(1)}}},
+			{ID: 1, ClusterID: 1, Type: metrics.Compensatable, ClusterAccesses: [][]interface{}{{"R", float64(2)}}},
+		},
+	}
+
+	// the entity written by the redesign is read by the other controller, which also writes the
+	// entity the redesign reads
+	redesignMetrics := handler.EvaluateRedesign(snapshot, "Writer", redesign)
+	assert.Equal(t, 1, redesignMetrics.SystemComplexity)
+	assert.Equal(t, 2, redesignMetrics.FunctionalityComplexity)
+	assert.Equal(t, 2, redesignMetrics.InvocationsCount)
+	assert.Equal(t, 2, redesignMetrics.EventsCount)
+	assert.Equal(t, 1, redesignMetrics.Invocations[0].ControllersThatReadInWrittenEntities)
+	assert.Equal(t, 1, redesignMetrics.Invocations[1].ControllersThatWriteInReadEntities)
+
+	// neither the redesign nor the decomposition are changed until the metrics are applied
+	assert.Equal(t, 0, redesign.FunctionalityComplexity)
+	assert.Equal(t, 0, redesign.Redesign[0].ControllerstThatReadInWrittenEntities)
+	assert.Equal(t, float32(0), decomposition.Controllers["Writer"].Complexity)
+	assert.Empty(t, decomposition.Clusters["0"].CouplingDependencies)
+
+	redesignMetrics.Apply(redesign)
+	assert.Equal(t, 2, redesign.FunctionalityComplexity)
+	assert.Equal(t, 1, redesign.SystemComplexity)
+	assert.Equal(t, 1, redesign.Redesign[1].ControllersThatWriteInReadEntities)
+}
+
+func TestEvaluateRedesignMatchesRedesignComplexities(t *testing.T) {
+	handler := metrics.New(log.NewNopLogger())
+	decomposition := newSnapshotDecomposition()
+
+	newRedesign := func() *files.FunctionalityRedesign {
+		return &files.FunctionalityRedesign{
+			Redesign: []*files.Invocation{
+				{ID: 0, ClusterID: 1, Type: metrics.Compensatable, ClusterAccesses: [][]interface{}{{"W", float64(2)}}},
+				{ID: 1, ClusterID: 0, Type: metrics.Compensatable, ClusterAccesses: [][]interface{}{{"R", float64(1)}}},
+			},
+		}
+	}
+
+	evaluated := newRedesign()
+	handler.EvaluateRedesign(metrics.NewSnapshot(decomposition), "Reader", evaluated).Apply(evaluated)
+
+	calculated := newRedesign()
+	handler.CalculateRedesignComplexities(metrics.NewSnapshot(decomposition), decomposition.Controllers["Reader"], calculated)
+
+	assert.Equal(t, calculated.FunctionalityComplexity, evaluated.FunctionalityComplexity)
+	assert.Equal(t, calculated.SystemComplexity, evaluated.SystemComplexity)
+	assert.Equal(t, calculated.Redesign, evaluated.Redesign)
+}
+
+func TestCalculateDecompositionMetricsOnlyUpdatesAffectedController(t *testing.T) {
+	handler := metrics.New(log.NewNopLogger())
+	decomposition := newSnapshotDecomposition()
+	decomposition.Controllers["Reader"].Complexity = 7
+	for _, controller := range decomposition.Controllers {
+		for clusterName := range controller.EntitiesPerCluster {
+			decomposition.Clusters[clusterName].AddController(controller)
+		}
+	}
+
+	redesign := &files.FunctionalityRedesign{
+		Redesign: []*files.Invocation{
+			{ID: 0, ClusterID: 0, Type: metrics.Compensatable, ClusterAccesses: [][]interface{}{{"W", float64(1)}}},
+			{ID: 1, ClusterID: 1, Type: metrics.Compensatable, ClusterAccesses: [][]interface{}{{"R", float64(2)}}},
+		},
+	}
+
+	handler.CalculateDecompositionMetrics(metrics.NewSnapshot(decomposition), decomposition, decomposition.Controllers["Writer"], redesign)
+
+	// both invocations access an entity the reader accesses in a different mode
+	assert.Equal(t, float32(2), decomposition.Controllers["Writer"].Complexity)
+	assert.Equal(t, float32(7), decomposition.Controllers["Reader"].Complexity)
+	assert.InDelta(t, 4.5, decomposition.Complexity, 0.0001)
+	assert.Equal(t, map[string][]int{"1": {2}}, decomposition.Clusters["0"].CouplingDependencies)
+	assert.InDelta(t, 1, decomposition.Clusters["0"].Coupling, 0.0001)
+	assert.Equal(t, 2, redesign.FunctionalityComplexity)
+}
+
+func TestCalculateDecompositionMetricsUsesTheGivenController(t *testing.T) {
+	handler := metrics.New(log.NewNopLogger())
+	decomposition := newSnapshotDecomposition()
+
+	newRedesign := func() *files.FunctionalityRedesign {
+		return &files.FunctionalityRedesign{
+			Redesign: []*files.Invocation{
+				{ID: 0, ClusterID: 0, Type: metrics.Compensatable, ClusterAccesses: [][]interface{}{{"W", float64(1)}}},
+				{ID: 1, ClusterID: 1, Type: metrics.Compensatable, ClusterAccesses: [][]interface{}{{"R", float64(2)}}},
+			},
+		}
+	}
+
+	// the complexities used to be calculated for the last controller visited in the map, so the
+	// redesign of the writer could get the complexities of the same trace run by the reader
+	asWriter := newRedesign()
+	handler.CalculateRedesignComplexities(metrics.NewSnapshot(decomposition), decomposition.Controllers["Writer"], asWriter)
+	asReader := newRedesign()
+	handler.CalculateRedesignComplexities(metrics.NewSnapshot(decomposition), decomposition.Controllers["Reader"], asReader)
+	assert.Equal(t, 2, asWriter.FunctionalityComplexity)
+	assert.Equal(t, 1, asReader.FunctionalityComplexity)
+
+	for run := 0; run < 10; run++ {
+		redesign := newRedesign()
+		handler.CalculateDecompositionMetrics(metrics.NewSnapshot(decomposition), decomposition, decomposition.Controllers["Writer"], redesign)
+
+		assert.Equal(t, asWriter.FunctionalityComplexity, redesign.FunctionalityComplexity)
+		assert.Equal(t, asWriter.SystemComplexity, redesign.SystemComplexity)
+	}
+}
+
+func TestCalculateRedesignPerformanceChargesLatencyPerRemoteInvocation(t *testing.T) {
+	handler := metrics.New(log.NewNopLogger())
+	costModel := configuration.CostModel{RemoteInvocationLatency: 10, LocalAccessCost: 1, MessageHeaderSize: 100, EntityAccessSize: 10}
+
+	newPerformanceRedesign := func() *files.FunctionalityRedesign {
+		return &files.FunctionalityRedesign{
+			OrchestratorID: 0,
+			Redesign: []*files.Invocation{
+				{ID: 0, ClusterID: 0, ClusterAccesses: [][]interface{}{{"R", float64(1)}}},
+				{ID: 1, ClusterID: 1, ClusterAccesses: [][]interface{}{{"W", float64(2)}}},
+			},
+		}
+	}
+
+	orchestrated := newPerformanceRedesign()
+	handler.CalculateRedesignPerformance(orchestrated, true, costModel)
+
+	choreographed := newPerformanceRedesign()
+	handler.CalculateRedesignPerformance(choreographed, false, costModel)
+
+	// the command and the reply are a single round trip
+	assert.Equal(t, float32(12), orchestrated.Latency)
+	assert.Equal(t, 2, orchestrated.MessagesCount)
+	assert.Equal(t, 220, orchestrated.MessagesSize)
+
+	assert.Equal(t, float32(12), choreographed.Latency)
+	assert.Equal(t, 1, choreographed.MessagesCount)
+	assert.Equal(t, 110, choreographed.MessagesSize)
+}
+
+func TestCalculateCommunicationMetrics(t *testing.T) {
+	handler := metrics.New(log.NewNopLogger())
+
+	cases := []struct {
+		name             string
+		redesign         *files.FunctionalityRedesign
+		expectedEvents   int
+		expectedCoupling int
+	}{
+		{
+			// a command and a reply for each remote invocation with accesses
+			"orchestration",
+			&files.FunctionalityRedesign{
+				OrchestratorID: 1,
+				Redesign: []*files.Invocation{
+					{ClusterID: -1},
+					{ClusterID: 1, ClusterAccesses: [][]interface{}{{"W", 1}}},
+					{ClusterID: 2, ClusterAccesses: [][]interface{}{{"W", 2}}},
+					{ClusterID: 1},
+					{ClusterID: 3, ClusterAccesses: [][]interface{}{{"R", 3}}},
+					{ClusterID: 2},
+					{ClusterID: 2, ClusterAccesses: [][]interface{}{{"W", 4}}},
+				},
+			},
+			6,
+			2,
+		},
+		{
+			// an event each time a participant hands off to another one
+			"choreography",
+			&files.FunctionalityRedesign{
+				OrchestratorID: -1,
+				Choreography:   true,
+				Redesign: []*files.Invocation{
+					{ClusterID: 1, ClusterAccesses: [][]interface{}{{"W", 1}}},
+					{ClusterID: 2, ClusterAccesses: [][]interface{}{{"W", 2}}},
+					{ClusterID: 1, ClusterAccesses: [][]interface{}{{"R", 3}}},
+					{ClusterID: 3, ClusterAccesses: [][]interface{}{{"W", 4}}},
+				},
+			},
+			3,
+			2,
+		},
+	}
+
+	for _, c := range cases {
+		handler.CalculateCommunicationMetrics(c.redesign)
+
+		assert.Equal(t, c.expectedEvents, c.redesign.EventsCount, c.name)
+		assert.Equal(t, c.expectedCoupling, c.redesign.CommunicationCoupling, c.name)
+	}
+}
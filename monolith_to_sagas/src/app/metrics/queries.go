@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"sort"
+)
+
+// QueryMetrics compare the two ways of implementing a query that reads entities of several
+// clusters. With API composition an aggregator cluster invokes the other clusters and joins their
+// replies, so a saga that writes the entities read in more than one of those clusters can be
+// observed halfway, counting the clusters in common as in Mono2Micro. With a replicated view the
+// query reads a single read model, updated once by each saga that writes the entities read, so
+// it observes stale data instead, counting one per updating saga.
+type QueryMetrics struct {
+	ClustersEntitiesRead               map[int][]int
+	AggregatorID                       int
+	CompositionInvocationsCount        int
+	CompositionInconsistencyComplexity int
+	ViewUpdatingFunctionalities        []string
+	ViewInconsistencyComplexity        int
+}
+
+// EvaluateQuery calculates the metrics of the query functionality for both implementations, where
+// the aggregator is the cluster with more entities read, so most of the reads are local
+func (svc *DefaultHandler) EvaluateQuery(snapshot *Snapshot, controllerName string) *QueryMetrics {
+	return snapshot.queryMetrics(controllerName)
+}
+
+func (s *Snapshot) queryMetrics(controllerName string) *QueryMetrics {
+	queryMetrics := &QueryMetrics{
+		ClustersEntitiesRead:        map[int][]int{},
+		AggregatorID:                -1,
+		ViewUpdatingFunctionalities: []string{},
+	}
+
+	controller, found := s.controllers[controllerName]
+	if !found {
+		return queryMetrics
+	}
+
+	entitiesRead := []int{}
+	for entity, mode := range controller.entities {
+		if mode != ReadMode {
+			continue
+		}
+		entitiesRead = append(entitiesRead, entity)
+	}
+	sort.Ints(entitiesRead)
+
+	entitiesReadThatAreWrittenInOther := map[string][]int{}
+	for _, entity := range entitiesRead {
+		clusterID := s.entityClusters[entity]
+		queryMetrics.ClustersEntitiesRead[clusterID] = append(queryMetrics.ClustersEntitiesRead[clusterID], entity)
+
+		for _, otherController := range s.entityControllers[entity] {
+			if otherController.name == controllerName || otherController.clustersCount <= 1 || otherController.controllerType != Saga {
+				continue
+			}
+
+			if otherController.entities[entity] >= WriteMode {
+				entitiesReadThatAreWrittenInOther[otherController.name] = append(entitiesReadThatAreWrittenInOther[otherController.name], entity)
+			}
+		}
+	}
+
+	for clusterID, entities := range queryMetrics.ClustersEntitiesRead {
+		aggregatorEntities := queryMetrics.ClustersEntitiesRead[queryMetrics.AggregatorID]
+		if queryMetrics.AggregatorID == -1 || len(entities) > len(aggregatorEntities) ||
+			(len(entities) == len(aggregatorEntities) && clusterID < queryMetrics.AggregatorID) {
+			queryMetrics.AggregatorID = clusterID
+		}
+	}
+	if len(queryMetrics.ClustersEntitiesRead) > 0 {
+		queryMetrics.CompositionInvocationsCount = len(queryMetrics.ClustersEntitiesRead) - 1
+	}
+
+	for otherControllerName, entities := range entitiesReadThatAreWrittenInOther {
+		clustersInCommon := map[int]bool{}
+		for _, entityID := range entities {
+			clustersInCommon[s.entityClusters[entityID]] = true
+		}
+
+		if len(clustersInCommon) > 1 {
+			queryMetrics.CompositionInconsistencyComplexity += len(clustersInCommon)
+		}
+
+		queryMetrics.ViewUpdatingFunctionalities = append(queryMetrics.ViewUpdatingFunctionalities, otherControllerName)
+	}
+	sort.Strings(queryMetrics.ViewUpdatingFunctionalities)
+	queryMetrics.ViewInconsistencyComplexity = len(queryMetrics.ViewUpdatingFunctionalities)
+
+	return queryMetrics
+}
@@ -0,0 +1,52 @@
+package metrics_test
+
+import (
+	"automation/app/common/log"
+	"automation/app/files"
+	"automation/app/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateQuery(t *testing.T) {
+	handler := metrics.New(log.NewNopLogger())
+	decomposition := &files.Decomposition{
+		Controllers: map[string]*files.Controller{
+			"Query": {
+				Name:               "Query",
+				Type:               metrics.Query,
+				Entities:           map[string]int{"1": metrics.ReadMode, "2": metrics.ReadMode, "3": metrics.ReadMode},
+				EntitiesPerCluster: map[string][]int{"0": {1}, "1": {2, 3}},
+			},
+			"WriteBoth": {
+				Name:               "WriteBoth",
+				Type:               metrics.Saga,
+				Entities:           map[string]int{"1": metrics.WriteMode, "2": metrics.ReadWriteMode},
+				EntitiesPerCluster: map[string][]int{"0": {1}, "1": {2}},
+			},
+			"WriteOne": {
+				Name:               "WriteOne",
+				Type:               metrics.Saga,
+				Entities:           map[string]int{"3": metrics.WriteMode, "4": metrics.ReadMode},
+				EntitiesPerCluster: map[string][]int{"1": {3}, "2": {4}},
+			},
+		},
+		EntityIDToClusterName: map[string]string{"1": "0", "2": "1", "3": "1", "4": "2"},
+	}
+
+	queryMetrics := handler.EvaluateQuery(metrics.NewSnapshot(decomposition), "Query")
+
+	assert.Equal(t, map[int][]int{0: {1}, 1: {2, 3}}, queryMetrics.ClustersEntitiesRead)
+	assert.Equal(t, 1, queryMetrics.AggregatorID)
+	assert.Equal(t, 1, queryMetrics.CompositionInvocationsCount)
+
+	// only the saga that writes the entities read in both clusters can be observed halfway
+	assert.Equal(t, 2, queryMetrics.CompositionInconsistencyComplexity)
+	assert.Equal(t, []string{"WriteBoth", "WriteOne"}, queryMetrics.ViewUpdatingFunctionalities)
+	assert.Equal(t, 2, queryMetrics.ViewInconsistencyComplexity)
+
+	redesign := &files.FunctionalityRedesign{}
+	handler.EvaluateRedesign(metrics.NewSnapshot(decomposition), "Query", redesign).Apply(redesign)
+	assert.Equal(t, 2, redesign.InconsistencyComplexity)
+}
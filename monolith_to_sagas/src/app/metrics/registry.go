@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"automation/app/files"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Metric is a measure of a redesign of a controller, where lower values are better when the
+// metric is used to rank the redesigns. The candidate redesigns of a controller are measured
+// concurrently, so a metric must not change the decomposition or the controller.
+type Metric interface {
+	Calculate(*files.Decomposition, *files.Controller, *files.FunctionalityRedesign) float32
+}
+
+// MetricFunc allows an ordinary function to be registered as a metric
+type MetricFunc func(*files.Decomposition, *files.Controller, *files.FunctionalityRedesign) float32
+
+func (f MetricFunc) Calculate(decomposition *files.Decomposition, controller *files.Controller, redesign *files.FunctionalityRedesign) float32 {
+	return f(decomposition, controller, redesign)
+}
+
+var (
+	registryMutex = sync.RWMutex{}
+	registry      = map[string]Metric{}
+
+	// built-in metrics that are only calculated when the performance of the redesigns is estimated
+	performanceMetrics = map[string]bool{"latency": true}
+)
+
+// The built-in metrics expose the measures calculated by the handler, which are calculated before
+// the registered metrics, so they can be selected and ranked in the same way as the custom ones
+func init() {
+	Register("functionality_complexity", MetricFunc(func(_ *files.Decomposition, _ *files.Controller, redesign *files.FunctionalityRedesign) float32 {
+		return float32(redesign.FunctionalityComplexity)
+	}))
+	Register("system_complexity", MetricFunc(func(_ *files.Decomposition, _ *files.Controller, redesign *files.FunctionalityRedesign) float32 {
+		return float32(redesign.SystemComplexity)
+	}))
+	Register("inconsistency_complexity", MetricFunc(func(_ *files.Decomposition, _ *files.Controller, redesign *files.FunctionalityRedesign) float32 {
+		return float32(redesign.InconsistencyComplexity)
+	}))
+	Register("invocations_count", MetricFunc(func(_ *files.Decomposition, _ *files.Controller, redesign *files.FunctionalityRedesign) float32 {
+		return float32(redesign.InvocationsCount)
+	}))
+	Register("events_count", MetricFunc(func(_ *files.Decomposition, _ *files.Controller, redesign *files.FunctionalityRedesign) float32 {
+		return float32(redesign.EventsCount)
+	}))
+	Register("communication_coupling", MetricFunc(func(_ *files.Decomposition, _ *files.Controller, redesign *files.FunctionalityRedesign) float32 {
+		return float32(redesign.CommunicationCoupling)
+	}))
+	Register("latency", MetricFunc(func(_ *files.Decomposition, _ *files.Controller, redesign *files.FunctionalityRedesign) float32 {
+		return redesign.Latency
+	}))
+}
+
+// Register makes a metric available by name, failing if the name is empty or already taken
+func Register(name string, metric Metric) error {
+	if name == "" {
+		return fmt.Errorf("metric name is empty")
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, found := registry[name]; found {
+		return fmt.Errorf("metric %s is already registered", name)
+	}
+
+	registry[name] = metric
+	return nil
+}
+
+func GetMetric(name string) (Metric, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	metric, found := registry[name]
+	return metric, found
+}
+
+// RegisteredMetrics returns the names of every registered metric, sorted by name
+func RegisteredMetrics() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := []string{}
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateMetrics checks if every given metric is registered and, for the metrics of the cost
+// model, if the performance of the redesigns is calculated
+func ValidateMetrics(names []string, calculatePerformance bool) error {
+	for _, name := range names {
+		if _, found := GetMetric(name); !found {
+			return fmt.Errorf("metric %s is not registered, the registered metrics are %v", name, RegisteredMetrics())
+		}
+		if performanceMetrics[name] && !calculatePerformance {
+			return fmt.Errorf("metric %s needs the performance of the redesigns, enable calculate_performance or minimize_latency", name)
+		}
+	}
+	return nil
+}
+
+// CalculateRegisteredMetrics calculates the given registered metrics of the redesign and keeps
+// them in its metrics, by name
+func (svc *DefaultHandler) CalculateRegisteredMetrics(
+	decomposition *files.Decomposition, controller *files.Controller, redesign *files.FunctionalityRedesign, names []string,
+) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	if redesign.Metrics == nil {
+		redesign.Metrics = map[string]float32{}
+	}
+
+	for _, name := range names {
+		metric, found := GetMetric(name)
+		if !found {
+			err := fmt.Errorf("metric %s is not registered", name)
+			svc.logger.Log(err)
+			return err
+		}
+
+		redesign.Metrics[name] = metric.Calculate(decomposition, controller, redesign)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package metrics_test
+
+import (
+	"automation/app/common/log"
+	"automation/app/files"
+	"automation/app/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterMetric(t *testing.T) {
+	remoteInvocations := metrics.MetricFunc(func(_ *files.Decomposition, _ *files.Controller, redesign *files.FunctionalityRedesign) float32 {
+		var count float32
+		for _, invocation := range redesign.Redesign {
+			if invocation.ClusterID != redesign.OrchestratorID {
+				count++
+			}
+		}
+		return count
+	})
+
+	// the registry is global, so the metric is already registered when the test runs again
+	if _, found := metrics.GetMetric("remote_invocations"); !found {
+		assert.NoError(t, metrics.Register("remote_invocations", remoteInvocations))
+	}
+	assert.Error(t, metrics.Register("remote_invocations", remoteInvocations))
+	assert.Error(t, metrics.Register("", remoteInvocations))
+	assert.Contains(t, metrics.RegisteredMetrics(), "remote_invocations")
+	assert.NoError(t, metrics.ValidateMetrics([]string{"remote_invocations", "system_complexity"}, false))
+	assert.Error(t, metrics.ValidateMetrics([]string{"unknown"}, false))
+
+	// the latency is only calculated with the cost model
+	assert.Error(t, metrics.ValidateMetrics([]string{"latency"}, false))
+	assert.NoError(t, metrics.ValidateMetrics([]string{"latency"}, true))
+}
+
+func TestCalculateRegisteredMetrics(t *testing.T) {
+	handler := metrics.New(log.NewNopLogger())
+	redesign := &files.FunctionalityRedesign{
+		OrchestratorID:   1,
+		SystemComplexity: 3,
+		Redesign: []*files.Invocation{
+			{ID: 0, ClusterID: 1},
+			{ID: 1, ClusterID: 2},
+		},
+	}
+
+	err := handler.CalculateRegisteredMetrics(&files.Decomposition{}, &files.Controller{}, redesign, []string{"system_complexity"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float32{"system_complexity": 3}, redesign.Metrics)
+
+	err = handler.CalculateRegisteredMetrics(&files.Decomposition{}, &files.Controller{}, redesign, []string{"unknown"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,242 @@
+package metrics
+
+import (
+	"automation/app/files"
+	"strconv"
+)
+
+// Snapshot is an immutable copy of the parts of a decomposition the redesign metrics depend on.
+// Evaluating a redesign against a snapshot neither reads nor changes the decomposition, so the
+// candidate redesigns of a controller can be evaluated concurrently. The controllers that access
+// each entity are indexed, so the complexity of an access only visits the controllers that share
+// the entity instead of every controller of the decomposition.
+type Snapshot struct {
+	controllers       map[string]*controllerSnapshot
+	entityControllers map[int][]*controllerSnapshot
+	entityClusters    map[int]int
+}
+
+type controllerSnapshot struct {
+	name           string
+	controllerType string
+	entities       map[int]int
+	clustersCount  int
+}
+
+func NewSnapshot(decomposition *files.Decomposition) *Snapshot {
+	snapshot := &Snapshot{
+		controllers:       map[string]*controllerSnapshot{},
+		entityControllers: map[int][]*controllerSnapshot{},
+		entityClusters:    map[int]int{},
+	}
+
+	for _, controller := range decomposition.Controllers {
+		entities := map[int]int{}
+		for entityName, mode := range controller.Entities {
+			entityID, _ := strconv.Atoi(entityName)
+			entities[entityID] = mode
+		}
+
+		controllerSnapshot := &controllerSnapshot{
+			name:           controller.Name,
+			controllerType: controller.Type,
+			entities:       entities,
+			clustersCount:  len(controller.EntitiesPerCluster),
+		}
+		snapshot.controllers[controller.Name] = controllerSnapshot
+
+		for entityID := range entities {
+			snapshot.entityControllers[entityID] = append(snapshot.entityControllers[entityID], controllerSnapshot)
+		}
+	}
+
+	for entityName, clusterName := range decomposition.EntityIDToClusterName {
+		entityID, _ := strconv.Atoi(entityName)
+		clusterID, _ := strconv.Atoi(clusterName)
+		snapshot.entityClusters[entityID] = clusterID
+	}
+
+	return snapshot
+}
+
+// RedesignMetrics are the metrics of a redesign evaluated against a snapshot. The invocation
+// metrics follow the order of the invocations of the redesign.
+type RedesignMetrics struct {
+	FunctionalityComplexity                            int
+	SystemComplexity                                   int
+	InconsistencyComplexity                            int
+	InvocationsCount                                   int
+	AccessesCount                                      int
+	ClustersBesidesOrchestratorWithMultipleInvocations int
+	EventsCount                                        int
+	CommunicationCoupling                              int
+	Invocations                                        []*InvocationMetrics
+}
+
+type InvocationMetrics struct {
+	ControllersThatReadInWrittenEntities int
+	ControllersThatWriteInReadEntities   int
+}
+
+// Apply copies the metrics to the redesign they were evaluated from
+func (m *RedesignMetrics) Apply(redesign *files.FunctionalityRedesign) {
+	m.applyComplexities(redesign)
+
+	redesign.InvocationsCount = m.InvocationsCount
+	redesign.AccessesCount = m.AccessesCount
+	redesign.ClustersBesidesOrchestratorWithMultipleInvocations = m.ClustersBesidesOrchestratorWithMultipleInvocations
+	redesign.EventsCount = m.EventsCount
+	redesign.CommunicationCoupling = m.CommunicationCoupling
+}
+
+func (m *RedesignMetrics) applyComplexities(redesign *files.FunctionalityRedesign) {
+	redesign.FunctionalityComplexity = m.FunctionalityComplexity
+	redesign.SystemComplexity = m.SystemComplexity
+	redesign.InconsistencyComplexity = m.InconsistencyComplexity
+
+	for idx, invocation := range redesign.Redesign {
+		if idx >= len(m.Invocations) {
+			break
+		}
+		invocation.ControllerstThatReadInWrittenEntities = m.Invocations[idx].ControllersThatReadInWrittenEntities
+		invocation.ControllersThatWriteInReadEntities = m.Invocations[idx].ControllersThatWriteInReadEntities
+	}
+}
+
+// EvaluateRedesign calculates the metrics of the redesign of a controller without changing the
+// redesign, its invocations or the decomposition the snapshot was taken from
+func (svc *DefaultHandler) EvaluateRedesign(snapshot *Snapshot, controllerName string, redesign *files.FunctionalityRedesign) *RedesignMetrics {
+	controllerType := Saga
+	if controller, found := snapshot.controllers[controllerName]; found {
+		controllerType = controller.controllerType
+	}
+
+	redesignMetrics := snapshot.redesignComplexities(controllerName, controllerType, redesign)
+
+	clusterInvocations := map[int]int{}
+	for _, invocation := range redesign.Redesign {
+		redesignMetrics.AccessesCount += len(invocation.ClusterAccesses)
+
+		if len(invocation.ClusterAccesses) > 0 {
+			redesignMetrics.InvocationsCount += 1
+		}
+
+		clusterInvocations[invocation.ClusterID] += 1
+	}
+
+	for clusterID, count := range clusterInvocations {
+		if count > 1 && clusterID != redesign.OrchestratorID {
+			redesignMetrics.ClustersBesidesOrchestratorWithMultipleInvocations += 1
+		}
+	}
+
+	redesignMetrics.EventsCount, redesignMetrics.CommunicationCoupling = communicationMetrics(redesign)
+
+	return redesignMetrics
+}
+
+func (s *Snapshot) redesignComplexities(controllerName string, controllerType string, redesign *files.FunctionalityRedesign) *RedesignMetrics {
+	redesignMetrics := &RedesignMetrics{Invocations: make([]*InvocationMetrics, len(redesign.Redesign))}
+	for idx := range redesignMetrics.Invocations {
+		redesignMetrics.Invocations[idx] = &InvocationMetrics{}
+	}
+
+	if controllerType == Query {
+		redesignMetrics.InconsistencyComplexity = s.queryMetrics(controllerName).CompositionInconsistencyComplexity
+		return redesignMetrics
+	}
+
+	for idx, invocation := range redesign.Redesign {
+		invocationMetrics := redesignMetrics.Invocations[idx]
+
+		for i := range invocation.ClusterAccesses {
+			entity := invocation.GetAccessEntityID(i)
+			mode := files.MapAccessTypeToMode(invocation.GetAccessType(i))
+
+			if mode >= WriteMode { // 2 -> W, 3 -> RW
+				if invocation.Type == Compensatable {
+					systemComplexity := s.systemComplexity(controllerName, entity)
+
+					invocationMetrics.ControllersThatReadInWrittenEntities += systemComplexity
+					redesignMetrics.SystemComplexity += systemComplexity
+
+					redesignMetrics.FunctionalityComplexity++
+				}
+			}
+
+			if mode != WriteMode { // 1 -> R
+				costOfRead := s.costOfRead(controllerName, entity)
+
+				invocationMetrics.ControllersThatWriteInReadEntities += costOfRead
+
+				redesignMetrics.FunctionalityComplexity += costOfRead
+			}
+		}
+	}
+
+	return redesignMetrics
+}
+
+// controllerComplexity sums, over the invocations of the redesign, the number of other controllers
+// that access the entities of the invocation in a different mode
+func (s *Snapshot) controllerComplexity(controllerName string, redesign *files.FunctionalityRedesign) float32 {
+	if controller, found := s.controllers[controllerName]; !found || controller.clustersCount <= 1 {
+		return 0
+	}
+
+	var complexity float32
+	for _, invocation := range redesign.Redesign {
+		if invocation.ClusterID == -1 || len(invocation.ClusterAccesses) == 0 {
+			continue
+		}
+
+		controllersTouchingSameEntities := map[string]bool{}
+		for i := range invocation.ClusterAccesses {
+			entity := invocation.GetAccessEntityID(i)
+			mode := files.MapAccessTypeToMode(invocation.GetAccessType(i))
+
+			for _, otherController := range s.entityControllers[entity] {
+				if otherController.name == controllerName || otherController.clustersCount <= 1 {
+					continue
+				}
+
+				if otherController.entities[entity] != mode {
+					controllersTouchingSameEntities[otherController.name] = true
+				}
+			}
+		}
+		complexity += float32(len(controllersTouchingSameEntities))
+	}
+
+	return complexity
+}
+
+func (s *Snapshot) systemComplexity(controllerName string, entity int) int {
+	var systemComplexity int
+
+	for _, otherController := range s.entityControllers[entity] {
+		if otherController.name == controllerName || otherController.entities[entity] == WriteMode {
+			continue
+		}
+
+		systemComplexity++
+	}
+
+	return systemComplexity
+}
+
+func (s *Snapshot) costOfRead(controllerName string, entity int) int {
+	var functionalityComplexity int
+
+	for _, otherController := range s.entityControllers[entity] {
+		if otherController.name == controllerName || otherController.clustersCount <= 1 {
+			continue
+		}
+
+		if otherController.entities[entity] >= WriteMode {
+			functionalityComplexity++
+		}
+	}
+
+	return functionalityComplexity
+}
@@ -0,0 +1,284 @@
+package optimizer
+
+import (
+	"automation/app/configuration"
+	"automation/app/files"
+	"automation/app/metrics"
+	"automation/app/redesign"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/go-kit/kit/log"
+)
+
+type OptimizerHandler interface {
+	Optimize(*files.Decomposition, configuration.OptimizationModel) (*OptimizationResult, error)
+	DecompositionCost(*files.Decomposition) int
+}
+
+type DefaultHandler struct {
+	logger          log.Logger
+	redesignHandler redesign.RedesignHandler
+}
+
+func New(logger log.Logger, redesignHandler redesign.RedesignHandler) OptimizerHandler {
+	return &DefaultHandler{
+		logger:          log.With(logger, "module", "optimizerHandler"),
+		redesignHandler: redesignHandler,
+	}
+}
+
+type OptimizationResult struct {
+	Decomposition  *files.Decomposition
+	InitialCost    int
+	FinalCost      int
+	Iterations     int
+	AcceptedMoves  int
+	ImprovingMoves int
+	Diff           *DecompositionDiff
+}
+
+// DecompositionDiff lists the entities that belong to another cluster in the optimized
+// decomposition and the clusters that were merged into others
+type DecompositionDiff struct {
+	MovedEntities   []*EntityMove `json:"movedEntities"`
+	RemovedClusters []string      `json:"removedClusters"`
+}
+
+type EntityMove struct {
+	EntityID    int    `json:"entityId"`
+	Entity      string `json:"entity,omitempty"`
+	FromCluster string `json:"fromCluster"`
+	ToCluster   string `json:"toCluster"`
+}
+
+// Optimize searches, by simulated annealing, for a decomposition with a lower cost. Each
+// neighbour of the current decomposition only redesigns again the functionalities whose clusters
+// changed, and the functionalities that share entities with them, since the complexities of the
+// others are the same. The best decomposition found is returned with its diff to the original.
+func (svc *DefaultHandler) Optimize(decomposition *files.Decomposition, model configuration.OptimizationModel) (*OptimizationResult, error) {
+	if model.MinClusters > 0 && len(decomposition.Clusters) < model.MinClusters {
+		err := fmt.Errorf(
+			"decomposition %s has %d clusters, less than the minimum of %d", decomposition.Name, len(decomposition.Clusters), model.MinClusters,
+		)
+		svc.logger.Log(err)
+		return nil, err
+	}
+
+	random := rand.New(rand.NewSource(model.Seed))
+
+	current := decomposition
+	currentCosts := svc.functionalityCosts(current, sagaFunctionalities(current))
+	currentCost := totalCost(currentCosts)
+
+	result := &OptimizationResult{
+		Decomposition: current,
+		InitialCost:   currentCost,
+		FinalCost:     currentCost,
+	}
+
+	temperature := model.InitialTemperature
+	for ; result.Iterations < model.Iterations; result.Iterations++ {
+		candidate := svc.neighbour(current, model, random)
+		if candidate == nil {
+			continue
+		}
+
+		candidateCosts := map[string]int{}
+		for name, cost := range currentCosts {
+			candidateCosts[name] = cost
+		}
+		for name, cost := range svc.functionalityCosts(candidate, affectedFunctionalities(current, candidate)) {
+			candidateCosts[name] = cost
+		}
+		candidateCost := totalCost(candidateCosts)
+
+		delta := float64(candidateCost - currentCost)
+		if delta <= 0 || (temperature > 0 && random.Float64() < math.Exp(-delta/temperature)) {
+			current, currentCosts, currentCost = candidate, candidateCosts, candidateCost
+			result.AcceptedMoves++
+
+			if currentCost < result.FinalCost {
+				result.Decomposition = current
+				result.FinalCost = currentCost
+				result.ImprovingMoves++
+			}
+		}
+
+		temperature *= model.CoolingRate
+	}
+
+	result.Diff = Diff(decomposition, result.Decomposition)
+	return result, nil
+}
+
+// DecompositionCost is the sum of the functionality and system complexities of the best saga
+// redesign of every functionality of the decomposition
+func (svc *DefaultHandler) DecompositionCost(decomposition *files.Decomposition) int {
+	return totalCost(svc.functionalityCosts(decomposition, sagaFunctionalities(decomposition)))
+}
+
+// neighbour moves a random entity to another cluster or merges two random clusters, returning
+// nil when the chosen move would break the constraints of the model
+func (svc *DefaultHandler) neighbour(decomposition *files.Decomposition, model configuration.OptimizationModel, random *rand.Rand) *files.Decomposition {
+	clusterNames := []string{}
+	for name := range decomposition.Clusters {
+		clusterNames = append(clusterNames, name)
+	}
+	sort.Strings(clusterNames)
+
+	if len(clusterNames) < 2 {
+		return nil
+	}
+
+	fromIdx := random.Intn(len(clusterNames))
+	toIdx := random.Intn(len(clusterNames) - 1)
+	if toIdx >= fromIdx {
+		toIdx++
+	}
+	fromClusterName, toClusterName := clusterNames[fromIdx], clusterNames[toIdx]
+	fromCluster, toCluster := decomposition.Clusters[fromClusterName], decomposition.Clusters[toClusterName]
+
+	if random.Float64() < model.MergeProbability {
+		if len(clusterNames) <= model.MinClusters || !fitsCluster(toCluster, len(fromCluster.Entities), model) {
+			return nil
+		}
+
+		merged, err := svc.redesignHandler.MergeClusters(decomposition, fromClusterName, toClusterName)
+		if err != nil {
+			return nil
+		}
+		return merged
+	}
+
+	// moving the last entity of a cluster would remove it, which is left to the merges
+	if len(fromCluster.Entities) < 2 || !fitsCluster(toCluster, 1, model) {
+		return nil
+	}
+
+	entityID := fromCluster.Entities[random.Intn(len(fromCluster.Entities))]
+	relocated, err := svc.redesignHandler.RelocateEntity(decomposition, entityID, toClusterName)
+	if err != nil {
+		return nil
+	}
+	return relocated
+}
+
+func fitsCluster(cluster *files.Cluster, entitiesCount int, model configuration.OptimizationModel) bool {
+	return model.MaxClusterSize == 0 || len(cluster.Entities)+entitiesCount <= model.MaxClusterSize
+}
+
+// functionalityCosts redesigns the given functionalities and returns the functionality and system
+// complexities of their best saga redesigns. The functionalities whose entities are in a single
+// cluster are local transactions, which cost nothing.
+func (svc *DefaultHandler) functionalityCosts(decomposition *files.Decomposition, names []string) map[string]int {
+	costs := map[string]int{}
+	for _, name := range names {
+		controller := decomposition.Controllers[name]
+		if len(controller.EntitiesPerCluster) <= 1 {
+			costs[name] = 0
+			continue
+		}
+
+		initialRedesign := controller.GetFunctionalityRedesign()
+		if initialRedesign == nil {
+			continue
+		}
+
+		sagaRedesigns, err := svc.redesignHandler.CreateSagaRedesigns(decomposition, controller, initialRedesign)
+		if err != nil || len(sagaRedesigns) == 0 {
+			continue
+		}
+		costs[name] = sagaRedesigns[0].FunctionalityComplexity + sagaRedesigns[0].SystemComplexity
+	}
+	return costs
+}
+
+func sagaFunctionalities(decomposition *files.Decomposition) []string {
+	names := []string{}
+	for name, controller := range decomposition.Controllers {
+		if controller.Type != metrics.Query {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// affectedFunctionalities returns the saga functionalities of the candidate that were rebuilt,
+// which are no longer the same as in the current decomposition, and the ones that share entities
+// with them, since their complexities depend on the clusters of the functionalities that access
+// the same entities
+func affectedFunctionalities(current *files.Decomposition, candidate *files.Decomposition) []string {
+	changedEntities := map[string]bool{}
+	for name, controller := range candidate.Controllers {
+		if current.Controllers[name] == controller {
+			continue
+		}
+		for entityName := range controller.Entities {
+			changedEntities[entityName] = true
+		}
+	}
+
+	names := []string{}
+	for _, name := range sagaFunctionalities(candidate) {
+		for entityName := range candidate.Controllers[name].Entities {
+			if changedEntities[entityName] {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+func totalCost(costs map[string]int) int {
+	var total int
+	for _, cost := range costs {
+		total += cost
+	}
+	return total
+}
+
+// Diff compares the clusters of the entities in both decompositions
+func Diff(original *files.Decomposition, optimized *files.Decomposition) *DecompositionDiff {
+	diff := &DecompositionDiff{
+		MovedEntities:   []*EntityMove{},
+		RemovedClusters: []string{},
+	}
+
+	for entityName, fromClusterName := range original.EntityIDToClusterName {
+		toClusterName, found := optimized.EntityIDToClusterName[entityName]
+		if !found || toClusterName == fromClusterName {
+			continue
+		}
+
+		entityID, _ := strconv.Atoi(entityName)
+		diff.MovedEntities = append(diff.MovedEntities, &EntityMove{
+			EntityID:    entityID,
+			FromCluster: fromClusterName,
+			ToCluster:   toClusterName,
+		})
+	}
+	sort.Slice(diff.MovedEntities, func(i, j int) bool {
+		return diff.MovedEntities[i].EntityID < diff.MovedEntities[j].EntityID
+	})
+
+	for name := range original.Clusters {
+		if _, found := optimized.Clusters[name]; !found {
+			diff.RemovedClusters = append(diff.RemovedClusters, name)
+		}
+	}
+	sort.Strings(diff.RemovedClusters)
+
+	return diff
+}
+
+func (d *DecompositionDiff) NameEntities(idToEntityMap map[string]string) {
+	for _, move := range d.MovedEntities {
+		move.Entity = idToEntityMap[strconv.Itoa(move.EntityID)]
+	}
+}
@@ -0,0 +1,110 @@
+package optimizer_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/common/log"
+	"automation/app/configuration"
+	"automation/app/files"
+	"automation/app/metrics"
+	"automation/app/optimizer"
+	"automation/app/redesign"
+	"automation/app/simulation"
+	"automation/app/training"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newDecomposition has two functionalities that write entities 1 and 2 together, which are split
+// between clusters 0 and 1, and cluster 2 with entity 3, which is only read by one of them
+func newDecomposition() *files.Decomposition {
+	first := &files.Controller{
+		Name:               "First",
+		Type:               metrics.Saga,
+		Entities:           map[string]int{"1": metrics.WriteMode, "2": metrics.WriteMode, "3": metrics.ReadMode},
+		EntitiesPerCluster: map[string][]int{"0": {1}, "1": {2}, "2": {3}},
+		FunctionalityRedesigns: []*files.FunctionalityRedesign{fixtures.Trace("Monolith",
+			fixtures.Invocation(2, []interface{}{"R", float64(3)}),
+			fixtures.Invocation(0, []interface{}{"W", float64(1)}),
+			fixtures.Invocation(1, []interface{}{"W", float64(2)}),
+		)},
+	}
+	second := &files.Controller{
+		Name:               "Second",
+		Type:               metrics.Saga,
+		Entities:           map[string]int{"1": metrics.ReadMode, "2": metrics.WriteMode},
+		EntitiesPerCluster: map[string][]int{"0": {1}, "1": {2}},
+		FunctionalityRedesigns: []*files.FunctionalityRedesign{fixtures.Trace("Monolith",
+			fixtures.Invocation(0, []interface{}{"R", float64(1)}),
+			fixtures.Invocation(1, []interface{}{"W", float64(2)}),
+		)},
+	}
+
+	return &files.Decomposition{
+		Name: "Decomposition",
+		Clusters: map[string]*files.Cluster{
+			"0": {Name: "0", Entities: []int{1}, CouplingDependencies: map[string][]int{}},
+			"1": {Name: "1", Entities: []int{2}, CouplingDependencies: map[string][]int{}},
+			"2": {Name: "2", Entities: []int{3}, CouplingDependencies: map[string][]int{}},
+		},
+		Controllers:           map[string]*files.Controller{"First": first, "Second": second},
+		EntityIDToClusterName: map[string]string{"1": "0", "2": "1", "3": "2"},
+	}
+}
+
+func newOptimizer() optimizer.OptimizerHandler {
+	logger := log.NewNopLogger()
+	execution := configuration.Execution{Configuration: &configuration.Configuration{MinimizeSumBothComplexities: true}}
+	redesignHandler := redesign.New(logger, metrics.New(logger), training.New(logger), simulation.New(logger), execution)
+	return optimizer.New(logger, redesignHandler)
+}
+
+func TestOptimizeReducesCostWithinConstraints(t *testing.T) {
+	optimizerHandler := newOptimizer()
+	decomposition := newDecomposition()
+
+	result, err := optimizerHandler.Optimize(decomposition, configuration.OptimizationModel{
+		Iterations:       50,
+		MergeProbability: 0.5,
+		MinClusters:      2,
+		MaxClusterSize:   2,
+		Seed:             1,
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, optimizerHandler.DecompositionCost(decomposition), result.InitialCost)
+	assert.Equal(t, optimizerHandler.DecompositionCost(result.Decomposition), result.FinalCost)
+	assert.Less(t, result.FinalCost, result.InitialCost)
+
+	assert.GreaterOrEqual(t, len(result.Decomposition.Clusters), 2)
+	for _, cluster := range result.Decomposition.Clusters {
+		assert.LessOrEqual(t, len(cluster.Entities), 2)
+	}
+
+	// entities 1 and 2 are written together, so the best decomposition keeps them in a cluster
+	assert.Equal(t, result.Decomposition.EntityIDToClusterName["1"], result.Decomposition.EntityIDToClusterName["2"])
+
+	// the original decomposition is not changed
+	assert.Equal(t, newDecomposition().EntityIDToClusterName, decomposition.EntityIDToClusterName)
+	assert.Len(t, decomposition.Clusters, 3)
+}
+
+func TestOptimizeFailsWithLessClustersThanMinimum(t *testing.T) {
+	_, err := newOptimizer().Optimize(newDecomposition(), configuration.OptimizationModel{Iterations: 1, MinClusters: 4})
+	assert.NotNil(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	original := newDecomposition()
+	optimized := newDecomposition()
+	optimized.EntityIDToClusterName["1"] = "1"
+	optimized.Clusters["1"].Entities = []int{1, 2}
+	delete(optimized.Clusters, "0")
+
+	diff := optimizer.Diff(original, optimized)
+	diff.NameEntities(map[string]string{"1": "Author"})
+
+	assert.Len(t, diff.MovedEntities, 1)
+	assert.Equal(t, &optimizer.EntityMove{EntityID: 1, Entity: "Author", FromCluster: "0", ToCluster: "1"}, diff.MovedEntities[0])
+	assert.Equal(t, []string{"0"}, diff.RemovedClusters)
+}
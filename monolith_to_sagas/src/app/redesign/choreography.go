@@ -0,0 +1,113 @@
+package redesign
+
+import (
+	"automation/app/files"
+	"fmt"
+)
+
+const (
+	Orchestration = "ORCHESTRATION"
+	Choreography  = "CHOREOGRAPHY"
+)
+
+// CreateChoreographyRedesign redesigns the controller as a choreographed saga and calculates its
+// complexities and communication metrics, so it can be compared with the orchestrated redesigns
+func (svc *DefaultHandler) CreateChoreographyRedesign(
+	decomposition *files.Decomposition, controller *files.Controller, initialRedesign *files.FunctionalityRedesign,
+) *files.FunctionalityRedesign {
+	redesign := svc.RefactorControllerAsChoreography(controller, initialRedesign)
+
+	svc.metricsHandler.EvaluateRedesign(svc.decompositionSnapshot(decomposition), controller.Name, redesign).Apply(redesign)
+
+	if svc.execution.Configuration.DetectParallelSteps {
+		redesign.DAG = svc.BuildSagaDAG(redesign)
+	}
+
+	if svc.execution.Configuration.ShouldCalculatePerformance() {
+		svc.metricsHandler.CalculateRedesignPerformance(redesign, false, svc.execution.Configuration.CostModel)
+	}
+
+	svc.metricsHandler.CalculateRegisteredMetrics(decomposition, controller, redesign, svc.execution.Configuration.MetricsToCalculate())
+
+	return redesign
+}
+
+// RefactorControllerAsChoreography creates a saga without a central orchestrator, where each
+// participant hands off directly to the participant of the next invocation
+func (svc *DefaultHandler) RefactorControllerAsChoreography(controller *files.Controller, initialRedesign *files.FunctionalityRedesign) *files.FunctionalityRedesign {
+	redesign := svc.choreographTrace(controller, initialRedesign)
+
+	if svc.execution.Configuration.ReorderInvocations {
+		reorderedTrace, reorderings := svc.ReorderInvocations(initialRedesign)
+		if len(reorderings) > 0 {
+			reorderedRedesign := svc.choreographTrace(controller, reorderedTrace)
+			if len(reorderedRedesign.Redesign) < len(redesign.Redesign) {
+				reorderedRedesign.Reorderings = reorderings
+				redesign = reorderedRedesign
+			}
+		}
+	}
+
+	return redesign
+}
+
+func (svc *DefaultHandler) choreographTrace(controller *files.Controller, initialRedesign *files.FunctionalityRedesign) *files.FunctionalityRedesign {
+	redesign := &files.FunctionalityRedesign{
+		Name:           controller.Name,
+		UsedForMetrics: true,
+		Redesign:       []*files.Invocation{},
+		OrchestratorID: -1,
+		Choreography:   true,
+	}
+
+	var invocationID int
+	var prevInvocation *files.Invocation
+	for _, initialInvocation := range initialRedesign.Redesign {
+		if initialInvocation.ClusterID == -1 {
+			continue
+		}
+
+		// consecutive invocations to the same participant are a single local transaction
+		if prevInvocation != nil && prevInvocation.ClusterID == initialInvocation.ClusterID {
+			for _, access := range initialInvocation.ClusterAccesses {
+				prevInvocation.ClusterAccesses = append(prevInvocation.ClusterAccesses, access)
+			}
+			svc.pruneInvocationAccesses(prevInvocation)
+			continue
+		}
+
+		invocation := &files.Invocation{
+			Name:              fmt.Sprintf("%d: %d", invocationID, initialInvocation.ClusterID),
+			ID:                invocationID,
+			ClusterID:         initialInvocation.ClusterID,
+			ClusterAccesses:   append([][]interface{}{}, initialInvocation.ClusterAccesses...),
+			RemoteInvocations: []int{},
+			Type:              "COMPENSATABLE",
+		}
+
+		redesign.Redesign = append(redesign.Redesign, invocation)
+		prevInvocation = invocation
+		invocationID++
+	}
+
+	redesign.InitialInvocationsCount = len(redesign.Redesign)
+	svc.mergeInvocationsUntilStable(controller, redesign)
+
+	return redesign
+}
+
+// preferredSagaStyle chooses the style with the lowest functionality complexity, using the
+// number of exchanged messages to break ties
+func preferredSagaStyle(orchestration *files.FunctionalityRedesign, choreography *files.FunctionalityRedesign) string {
+	if choreography.FunctionalityComplexity != orchestration.FunctionalityComplexity {
+		if choreography.FunctionalityComplexity < orchestration.FunctionalityComplexity {
+			return Choreography
+		}
+		return Orchestration
+	}
+
+	if choreography.EventsCount < orchestration.EventsCount {
+		return Choreography
+	}
+	return Orchestration
+}
@@ -0,0 +1,80 @@
+package redesign
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/files"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefactorControllerAsChoreography(t *testing.T) {
+	handler := newMergeHandler(ONLY_LAST_INVOCATION)
+
+	// A(1, W) B(1, W) C(2, W) D(2, R) E(3, W)
+	initialRedesign := &files.FunctionalityRedesign{
+		Name: "Controller",
+		Redesign: []*files.Invocation{
+			{ClusterID: -1},
+			fixtures.Invocation(1, []interface{}{"W", 1}),
+			fixtures.Invocation(1, []interface{}{"W", 2}),
+			fixtures.Invocation(2, []interface{}{"W", 3}),
+			fixtures.Invocation(2, []interface{}{"R", 4}),
+			fixtures.Invocation(3, []interface{}{"W", 5}),
+		},
+	}
+
+	redesign := handler.RefactorControllerAsChoreography(&files.Controller{Name: "Controller"}, initialRedesign)
+
+	assert.True(t, redesign.Choreography)
+	assert.Equal(t, -1, redesign.OrchestratorID)
+	assert.Equal(t, 3, redesign.InitialInvocationsCount)
+	if assert.Len(t, redesign.Redesign, 3) {
+		assert.Equal(t, []int{0, 1, 2}, []int{redesign.Redesign[0].ID, redesign.Redesign[1].ID, redesign.Redesign[2].ID})
+		assert.Equal(t, []int{1, 2, 3}, []int{redesign.Redesign[0].ClusterID, redesign.Redesign[1].ClusterID, redesign.Redesign[2].ClusterID})
+		assert.ElementsMatch(t, [][]interface{}{{"W", 1}, {"W", 2}}, redesign.Redesign[0].ClusterAccesses)
+		assert.ElementsMatch(t, [][]interface{}{{"W", 3}, {"R", 4}}, redesign.Redesign[1].ClusterAccesses)
+		assert.ElementsMatch(t, [][]interface{}{{"W", 5}}, redesign.Redesign[2].ClusterAccesses)
+	}
+
+	// the invocations of the initial redesign are not changed
+	assert.Len(t, initialRedesign.Redesign[1].ClusterAccesses, 1)
+}
+
+func TestPreferredSagaStyle(t *testing.T) {
+	cases := []struct {
+		name          string
+		orchestration *files.FunctionalityRedesign
+		choreography  *files.FunctionalityRedesign
+		expected      string
+	}{
+		{
+			"lower choreography complexity",
+			&files.FunctionalityRedesign{FunctionalityComplexity: 3, EventsCount: 2},
+			&files.FunctionalityRedesign{FunctionalityComplexity: 2, EventsCount: 4},
+			Choreography,
+		},
+		{
+			"lower orchestration complexity",
+			&files.FunctionalityRedesign{FunctionalityComplexity: 2, EventsCount: 6},
+			&files.FunctionalityRedesign{FunctionalityComplexity: 3, EventsCount: 2},
+			Orchestration,
+		},
+		{
+			"same complexity with fewer events",
+			&files.FunctionalityRedesign{FunctionalityComplexity: 2, EventsCount: 4},
+			&files.FunctionalityRedesign{FunctionalityComplexity: 2, EventsCount: 2},
+			Choreography,
+		},
+		{
+			"same complexity and events",
+			&files.FunctionalityRedesign{FunctionalityComplexity: 2, EventsCount: 2},
+			&files.FunctionalityRedesign{FunctionalityComplexity: 2, EventsCount: 2},
+			Orchestration,
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, preferredSagaStyle(c.orchestration, c.choreography), c.name)
+	}
+}
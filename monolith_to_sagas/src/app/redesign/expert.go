@@ -0,0 +1,299 @@
+package redesign
+
+import (
+	"automation/app/common/names"
+	"automation/app/files"
+	"automation/app/metrics"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ExpertSaga    = "EXPERT"
+	AutomaticSaga = "AUTOMATIC"
+	SameSaga      = "SAME"
+
+	similarityTolerance = 1e-9
+)
+
+type ExpertComparison struct {
+	Dendrogram      string
+	Expert          *files.Decomposition
+	Automatic       *files.Decomposition
+	Functionalities []*ExpertComparisonResult
+}
+
+// ExpertComparisonResult compares the best saga redesign of a functionality in the expert and in
+// the automatic decomposition. A functionality whose entities are in a single cluster of one of the
+// decompositions is a local transaction there, orchestrated by that cluster without complexity.
+type ExpertComparisonResult struct {
+	Functionality string
+	Expert        *SagaEstimation
+	Automatic     *SagaEstimation
+}
+
+type SagaEstimation struct {
+	ClustersCount                  int
+	OrchestratorID                 int
+	OrchestratorEntities           []int
+	InitialFunctionalityComplexity int
+	FinalFunctionalityComplexity   int
+	InitialSystemComplexity        int
+	FinalSystemComplexity          int
+}
+
+func (e *SagaEstimation) FunctionalityComplexityReduction() int {
+	return e.InitialFunctionalityComplexity - e.FinalFunctionalityComplexity
+}
+
+func (e *SagaEstimation) SystemComplexityReduction() int {
+	return e.InitialSystemComplexity - e.FinalSystemComplexity
+}
+
+func (e *SagaEstimation) FinalComplexity() int {
+	return e.FinalFunctionalityComplexity + e.FinalSystemComplexity
+}
+
+// EasierSaga tells which decomposition yields the saga with the lowest sum of the final
+// complexities
+func (r *ExpertComparisonResult) EasierSaga() string {
+	if r.Expert.FinalComplexity() < r.Automatic.FinalComplexity() {
+		return ExpertSaga
+	}
+	if r.Automatic.FinalComplexity() < r.Expert.FinalComplexity() {
+		return AutomaticSaga
+	}
+	return SameSaga
+}
+
+// CompareExpertDecomposition estimates the sagas of the functionalities of the expert
+// decomposition of the dendrogram and of the automatic decomposition closest to it
+func (svc *DefaultHandler) CompareExpertDecomposition(dendogram *files.Dendogram) (*ExpertComparison, error) {
+	var expert *files.Decomposition
+	automaticDecompositions := []*files.Decomposition{}
+	for _, decomposition := range dendogram.Decompositions {
+		if decomposition.Expert {
+			expert = decomposition
+		} else {
+			automaticDecompositions = append(automaticDecompositions, decomposition)
+		}
+	}
+
+	if expert == nil || len(automaticDecompositions) == 0 {
+		err := fmt.Errorf("dendrogram %s does not have both an expert and an automatic decomposition", dendogram.Name)
+		svc.logger.Log(err)
+		return nil, err
+	}
+
+	automatic := closestDecomposition(expert, automaticDecompositions)
+
+	comparison := &ExpertComparison{
+		Dendrogram:      dendogram.Name,
+		Expert:          expert,
+		Automatic:       automatic,
+		Functionalities: []*ExpertComparisonResult{},
+	}
+
+	controllerNames := []string{}
+	for name, controller := range expert.Controllers {
+		automaticController, found := automatic.Controllers[name]
+		if !found || controller.Type == metrics.Query {
+			continue
+		}
+
+		// only the functionalities that are a saga in at least one of the decompositions
+		if len(controller.EntitiesPerCluster) <= 1 && len(automaticController.EntitiesPerCluster) <= 1 {
+			continue
+		}
+		controllerNames = append(controllerNames, name)
+	}
+	sort.Strings(controllerNames)
+
+	for _, name := range controllerNames {
+		expertEstimation := svc.estimateSaga(expert, expert.Controllers[name])
+		automaticEstimation := svc.estimateSaga(automatic, automatic.Controllers[name])
+		if expertEstimation == nil || automaticEstimation == nil {
+			continue
+		}
+
+		comparison.Functionalities = append(comparison.Functionalities, &ExpertComparisonResult{
+			Functionality: name,
+			Expert:        expertEstimation,
+			Automatic:     automaticEstimation,
+		})
+	}
+
+	svc.dropDecompositionSnapshot(expert)
+	svc.dropDecompositionSnapshot(automatic)
+
+	return comparison, nil
+}
+
+// closestDecomposition returns the decomposition whose clusters group the entities most like the
+// expert one, by the adjusted Rand index of their assignments. Ties are broken by the number of
+// clusters nearest to the expert one and then by the lowest cut value.
+func closestDecomposition(expert *files.Decomposition, decompositions []*files.Decomposition) *files.Decomposition {
+	sorted := append([]*files.Decomposition{}, decompositions...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CutValue < sorted[j].CutValue
+	})
+
+	closest := sorted[0]
+	closestIndex := adjustedRandIndex(expert, closest)
+	for _, decomposition := range sorted[1:] {
+		index := adjustedRandIndex(expert, decomposition)
+		if index > closestIndex+similarityTolerance ||
+			(math.Abs(index-closestIndex) <= similarityTolerance && clustersDistance(decomposition, expert) < clustersDistance(closest, expert)) {
+			closest = decomposition
+			closestIndex = index
+		}
+	}
+	return closest
+}
+
+func clustersDistance(decomposition *files.Decomposition, expert *files.Decomposition) float64 {
+	return math.Abs(float64(len(decomposition.Clusters) - len(expert.Clusters)))
+}
+
+// adjustedRandIndex measures the agreement between the clusters the entities of both
+// decompositions belong to, corrected for chance. It is 1 when they group the entities in the same
+// way and around 0 when they agree as much as random assignments would. Only the entities of both
+// decompositions are compared.
+func adjustedRandIndex(a *files.Decomposition, b *files.Decomposition) float64 {
+	contingency := map[[2]string]int{}
+	aCounts := map[string]int{}
+	bCounts := map[string]int{}
+	var entitiesCount int
+	for entityID, aClusterName := range a.EntityIDToClusterName {
+		bClusterName, found := b.EntityIDToClusterName[entityID]
+		if !found {
+			continue
+		}
+
+		contingency[[2]string{aClusterName, bClusterName}]++
+		aCounts[aClusterName]++
+		bCounts[bClusterName]++
+		entitiesCount++
+	}
+
+	if entitiesCount < 2 {
+		return 0
+	}
+
+	var index float64
+	for _, count := range contingency {
+		index += pairs(count)
+	}
+
+	var aPairs float64
+	for _, count := range aCounts {
+		aPairs += pairs(count)
+	}
+
+	var bPairs float64
+	for _, count := range bCounts {
+		bPairs += pairs(count)
+	}
+
+	expectedIndex := aPairs * bPairs / pairs(entitiesCount)
+	maxIndex := (aPairs + bPairs) / 2
+	if maxIndex == expectedIndex {
+		// both put every entity in a single cluster, or each entity in its own cluster
+		return 1
+	}
+	return (index - expectedIndex) / (maxIndex - expectedIndex)
+}
+
+func pairs(count int) float64 {
+	return float64(count*(count-1)) / 2
+}
+
+// estimateSaga measures the monolith trace of the controller and its best saga redesign, without
+// changing the metrics kept in the trace
+func (svc *DefaultHandler) estimateSaga(decomposition *files.Decomposition, controller *files.Controller) *SagaEstimation {
+	initialRedesign := controller.GetFunctionalityRedesign()
+	if initialRedesign == nil {
+		return nil
+	}
+
+	estimation := &SagaEstimation{
+		ClustersCount:        len(controller.EntitiesPerCluster),
+		OrchestratorEntities: []int{},
+	}
+
+	if len(controller.EntitiesPerCluster) <= 1 {
+		for clusterName, entities := range controller.EntitiesPerCluster {
+			estimation.OrchestratorID, _ = strconv.Atoi(clusterName)
+			estimation.OrchestratorEntities = entities
+		}
+		return estimation
+	}
+
+	initialMetrics := svc.metricsHandler.EvaluateRedesign(svc.keepDecompositionSnapshot(decomposition), controller.Name, initialRedesign)
+	estimation.InitialFunctionalityComplexity = initialMetrics.FunctionalityComplexity
+	estimation.InitialSystemComplexity = initialMetrics.SystemComplexity
+
+	sagaRedesigns, err := svc.CreateSagaRedesigns(decomposition, controller, initialRedesign)
+	if err != nil || len(sagaRedesigns) == 0 {
+		return nil
+	}
+
+	bestRedesign := sagaRedesigns[0]
+	estimation.OrchestratorID = bestRedesign.OrchestratorID
+	estimation.OrchestratorEntities = controller.EntitiesPerCluster[strconv.Itoa(bestRedesign.OrchestratorID)]
+	estimation.FinalFunctionalityComplexity = bestRedesign.FunctionalityComplexity
+	estimation.FinalSystemComplexity = bestRedesign.SystemComplexity
+
+	return estimation
+}
+
+// addExpertComparisonToDataset adds a row for each functionality of the comparison and prints the
+// functionalities where the expert decomposition yields easier sagas
+func (svc *DefaultHandler) addExpertComparisonToDataset(
+	data [][]string, codebase *files.Codebase, comparison *ExpertComparison, idToEntityMap map[string]string,
+) [][]string {
+	easierFunctionalities := []string{}
+
+	for _, result := range comparison.Functionalities {
+		if result.EasierSaga() == ExpertSaga {
+			easierFunctionalities = append(easierFunctionalities, result.Functionality)
+		}
+
+		data = append(data, []string{
+			codebase.Name,
+			comparison.Dendrogram,
+			comparison.Expert.Name,
+			comparison.Automatic.Name,
+			fmt.Sprintf("%v", comparison.Automatic.CutValue),
+			result.Functionality,
+			strconv.Itoa(result.Expert.ClustersCount),
+			strconv.Itoa(result.Automatic.ClustersCount),
+			strconv.Itoa(result.Expert.OrchestratorID),
+			strings.Join(names.EntityNames(result.Expert.OrchestratorEntities, idToEntityMap), ", "),
+			strconv.Itoa(result.Automatic.OrchestratorID),
+			strings.Join(names.EntityNames(result.Automatic.OrchestratorEntities, idToEntityMap), ", "),
+			strconv.Itoa(result.Expert.FinalFunctionalityComplexity),
+			strconv.Itoa(result.Automatic.FinalFunctionalityComplexity),
+			strconv.Itoa(result.Expert.FunctionalityComplexityReduction()),
+			strconv.Itoa(result.Automatic.FunctionalityComplexityReduction()),
+			strconv.Itoa(result.Expert.FinalSystemComplexity),
+			strconv.Itoa(result.Automatic.FinalSystemComplexity),
+			strconv.Itoa(result.Expert.SystemComplexityReduction()),
+			strconv.Itoa(result.Automatic.SystemComplexityReduction()),
+			result.EasierSaga(),
+		})
+	}
+
+	fmt.Printf(
+		"\nExpert decomposition %v yields easier sagas than %v for %v of %v features\n",
+		comparison.Expert.Name, comparison.Automatic.Name, len(easierFunctionalities), len(comparison.Functionalities),
+	)
+	for _, name := range easierFunctionalities {
+		fmt.Printf("\t%v\n", name)
+	}
+
+	return data
+}
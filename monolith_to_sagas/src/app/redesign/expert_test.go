@@ -0,0 +1,107 @@
+package redesign
+
+import (
+	"automation/app/files"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assignedDecomposition is a decomposition given by the cluster of each of its entities
+type assignedDecomposition struct {
+	name                  string
+	cutValue              float32
+	entityIDToClusterName map[string]string
+}
+
+func (a assignedDecomposition) build() *files.Decomposition {
+	decomposition := &files.Decomposition{
+		Name:                  a.name,
+		CutValue:              a.cutValue,
+		Clusters:              map[string]*files.Cluster{},
+		EntityIDToClusterName: a.entityIDToClusterName,
+	}
+	for _, clusterName := range a.entityIDToClusterName {
+		decomposition.Clusters[clusterName] = &files.Cluster{Name: clusterName}
+	}
+	return decomposition
+}
+
+func TestAdjustedRandIndex(t *testing.T) {
+	expert := assignedDecomposition{"Expert", 0, map[string]string{"1": "0", "2": "0", "3": "1", "4": "1"}}
+
+	cases := []struct {
+		name     string
+		expert   assignedDecomposition
+		other    assignedDecomposition
+		expected float64
+	}{
+		// the names of the clusters do not matter, only how the entities are grouped
+		{"renamed clusters", expert, assignedDecomposition{"Renamed", 1, map[string]string{"1": "5", "2": "5", "3": "7", "4": "7"}}, 1},
+		{"crossed clusters", expert, assignedDecomposition{"Crossed", 1, map[string]string{"1": "0", "2": "1", "3": "0", "4": "1"}}, -0.5},
+		// one entity moves to the other cluster, so 4 pairs are together in both, where chance
+		// would give 2.8, out of a maximum of 6.5
+		{
+			"moved entity",
+			assignedDecomposition{"Larger", 0, map[string]string{"1": "0", "2": "0", "3": "0", "4": "1", "5": "1", "6": "1"}},
+			assignedDecomposition{"Moved", 1, map[string]string{"1": "0", "2": "0", "3": "1", "4": "1", "5": "1", "6": "1"}},
+			1.2 / 3.7,
+		},
+		// the entities missing in one of the decompositions are ignored
+		{"missing entities", expert, assignedDecomposition{"Partial", 1, map[string]string{"1": "0", "2": "0", "3": "1", "5": "1"}}, 1},
+		// both agree with the expert only as much as chance would
+		{"singleton clusters", expert, assignedDecomposition{"Singletons", 1, map[string]string{"1": "0", "2": "1", "3": "2", "4": "3"}}, 0},
+		{"single cluster", expert, assignedDecomposition{"Single", 2, map[string]string{"1": "0", "2": "0", "3": "0", "4": "0"}}, 0},
+	}
+
+	for _, c := range cases {
+		assert.InDelta(t, c.expected, adjustedRandIndex(c.expert.build(), c.other.build()), 0.0001, c.name)
+	}
+}
+
+func TestClosestDecomposition(t *testing.T) {
+	cases := []struct {
+		name           string
+		expert         assignedDecomposition
+		decompositions []assignedDecomposition
+		closest        string
+	}{
+		{
+			// the first has the same number of clusters as the expert, but groups the entities
+			// differently, while the second splits one of the clusters of the expert
+			"entity assignments",
+			assignedDecomposition{"Expert", 0, map[string]string{"1": "0", "2": "0", "3": "1", "4": "1", "5": "2", "6": "2"}},
+			[]assignedDecomposition{
+				{"SameCount", 1, map[string]string{"1": "0", "2": "1", "3": "2", "4": "0", "5": "1", "6": "2"}},
+				{"Split", 2, map[string]string{"1": "0", "2": "3", "3": "1", "4": "1", "5": "2", "6": "2"}},
+			},
+			"Split",
+		},
+		{
+			"ties broken by the clusters count",
+			assignedDecomposition{"Expert", 0, map[string]string{"1": "0", "2": "0", "3": "1", "4": "1"}},
+			[]assignedDecomposition{
+				{"Singletons", 1, map[string]string{"1": "0", "2": "1", "3": "2", "4": "3"}},
+				{"Single", 2, map[string]string{"1": "0", "2": "0", "3": "0", "4": "0"}},
+			},
+			"Single",
+		},
+		{
+			"ties broken by the cut value",
+			assignedDecomposition{"Expert", 0, map[string]string{"1": "0", "2": "0", "3": "1", "4": "1"}},
+			[]assignedDecomposition{
+				{"Crossed", 4, map[string]string{"1": "0", "2": "1", "3": "0", "4": "1"}},
+				{"OtherCrossed", 3, map[string]string{"1": "1", "2": "0", "3": "1", "4": "0"}},
+			},
+			"OtherCrossed",
+		},
+	}
+
+	for _, c := range cases {
+		decompositions := []*files.Decomposition{}
+		for _, decomposition := range c.decompositions {
+			decompositions = append(decompositions, decomposition.build())
+		}
+		assert.Equal(t, c.closest, closestDecomposition(c.expert.build(), decompositions).Name, c.name)
+	}
+}
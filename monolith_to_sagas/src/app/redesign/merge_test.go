@@ -0,0 +1,169 @@
+package redesign
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/configuration"
+	"automation/app/files"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMergeHandler(dataDependenceThreshold int) *DefaultHandler {
+	return &DefaultHandler{
+		execution: configuration.Execution{
+			Configuration: &configuration.Configuration{DataDependenceThreshold: dataDependenceThreshold},
+		},
+	}
+}
+
+func TestMergeForbiddingRule(t *testing.T) {
+	// A(1, W) X(2, R) Y(3, W) O(empty) Z(4, W) S(1, W)
+	windowTrace := []*files.Invocation{
+		fixtures.Invocation(1, []interface{}{"W", 1}),
+		fixtures.Invocation(2, []interface{}{"R", 2}),
+		fixtures.Invocation(3, []interface{}{"W", 3}),
+		fixtures.Invocation(5),
+		fixtures.Invocation(4, []interface{}{"W", 4}),
+		fixtures.Invocation(1, []interface{}{"W", 5}),
+	}
+
+	// A(1, W) X(2, R) O(empty) Z(4, W) S(1, W)
+	emptyBoundaryTrace := []*files.Invocation{
+		fixtures.Invocation(1, []interface{}{"W", 1}),
+		fixtures.Invocation(2, []interface{}{"R", 2}),
+		fixtures.Invocation(5),
+		fixtures.Invocation(4, []interface{}{"W", 4}),
+		fixtures.Invocation(1, []interface{}{"W", 5}),
+	}
+
+	// A(1, W) X(2, R) S(1, ...), or X(2, R) S(2, ...) without A
+	lastReadTrace := func(accessType string) []*files.Invocation {
+		return []*files.Invocation{
+			fixtures.Invocation(1, []interface{}{"W", 1}),
+			fixtures.Invocation(2, []interface{}{"R", 2}),
+			fixtures.Invocation(1, []interface{}{accessType, 3}),
+		}
+	}
+
+	// A(1, W) X(2, W) E(1, empty) Y(2, W)
+	emptyOrchestratorTrace := []*files.Invocation{
+		fixtures.Invocation(1, []interface{}{"W", 1}),
+		fixtures.Invocation(2, []interface{}{"W", 2}),
+		fixtures.Invocation(1),
+		fixtures.Invocation(2, []interface{}{"W", 3}),
+	}
+
+	cases := []struct {
+		name        string
+		invocations []*files.Invocation
+		threshold   int
+		destiny     int
+		original    int
+		rule        string
+	}{
+		{"window measured in positions", windowTrace, 3, 0, 5, ""},
+		{"read outside of only the last invocation", windowTrace, ONLY_LAST_INVOCATION, 0, 5, ""},
+		{"read inside all previous invocations", windowTrace, ALL_PREVIOUS_INVOCATIONS, 0, 5, READ_DEPENDENCY_RULE},
+		{"window only closes at an invocation with accesses", emptyBoundaryTrace, 2, 0, 4, READ_DEPENDENCY_RULE},
+		{"window closes at an invocation with accesses", emptyBoundaryTrace, 1, 0, 4, ""},
+		{"last invocation reads", lastReadTrace("W"), ONLY_LAST_INVOCATION, 0, 2, READ_DEPENDENCY_RULE},
+		{"only reads", lastReadTrace("R"), ALL_PREVIOUS_INVOCATIONS, 0, 2, ""},
+		{"adjacent invocations", lastReadTrace("W")[1:], ALL_PREVIOUS_INVOCATIONS, 0, 1, ""},
+		{"empty orchestrator invocation", emptyOrchestratorTrace, ALL_PREVIOUS_INVOCATIONS, 0, 2, EMPTY_ORCHESTRATOR_RULE},
+	}
+
+	for _, c := range cases {
+		rule := newMergeHandler(c.threshold).mergeForbiddingRule(c.invocations, c.destiny, c.original)
+		assert.Equal(t, c.rule, rule, c.name)
+	}
+}
+
+func TestMergeAllPossibleInvocationsAuditsDecisions(t *testing.T) {
+	cases := []struct {
+		name        string
+		invocations []*files.Invocation
+		threshold   int
+		decisions   []*files.MergeDecision
+		merges      int
+	}{
+		{
+			"merged",
+			[]*files.Invocation{
+				fixtures.Invocation(1, []interface{}{"W", 1}),
+				fixtures.Invocation(2, []interface{}{"W", 2}),
+				fixtures.Invocation(1, []interface{}{"W", 3}),
+			},
+			ONLY_LAST_INVOCATION,
+			[]*files.MergeDecision{
+				{Iteration: 1, SourceInvocation: 2, DestinationInvocation: 0, ClusterID: 1, Merged: true},
+			},
+			1,
+		},
+		{
+			"read dependency",
+			[]*files.Invocation{
+				fixtures.Invocation(1, []interface{}{"W", 1}),
+				fixtures.Invocation(2, []interface{}{"R", 2}),
+				fixtures.Invocation(1, []interface{}{"W", 3}),
+			},
+			ONLY_LAST_INVOCATION,
+			[]*files.MergeDecision{
+				{Iteration: 1, SourceInvocation: 2, DestinationInvocation: 0, ClusterID: 1, ForbiddingRule: READ_DEPENDENCY_RULE},
+			},
+			0,
+		},
+		{
+			"read dependency inside the window",
+			[]*files.Invocation{
+				fixtures.Invocation(1, []interface{}{"W", 1}),
+				fixtures.Invocation(2, []interface{}{"R", 2}),
+				fixtures.Invocation(3, []interface{}{"W", 3}),
+				fixtures.Invocation(1, []interface{}{"W", 4}),
+			},
+			ALL_PREVIOUS_INVOCATIONS,
+			[]*files.MergeDecision{
+				{Iteration: 1, SourceInvocation: 3, DestinationInvocation: 0, ClusterID: 1, ForbiddingRule: READ_DEPENDENCY_RULE},
+			},
+			0,
+		},
+		{
+			"empty orchestrator",
+			[]*files.Invocation{
+				fixtures.Invocation(1, []interface{}{"W", 1}),
+				fixtures.Invocation(2, []interface{}{"W", 2}),
+				fixtures.Invocation(1),
+				fixtures.Invocation(3, []interface{}{"W", 3}),
+			},
+			ALL_PREVIOUS_INVOCATIONS,
+			[]*files.MergeDecision{
+				{Iteration: 1, SourceInvocation: 2, DestinationInvocation: 0, ClusterID: 1, ForbiddingRule: EMPTY_ORCHESTRATOR_RULE},
+			},
+			0,
+		},
+	}
+
+	for _, c := range cases {
+		handler := newMergeHandler(c.threshold)
+		handler.execution.Configuration.AuditMerges = true
+
+		redesign := &files.FunctionalityRedesign{Redesign: c.invocations}
+		_, merges := handler.mergeAllPossibleInvocations(redesign)
+
+		assert.Equal(t, c.merges, merges, c.name)
+		assert.Equal(t, c.decisions, redesign.MergeDecisions, c.name)
+	}
+}
+
+func TestMergeAllPossibleInvocationsWithoutAudit(t *testing.T) {
+	redesign := &files.FunctionalityRedesign{Redesign: []*files.Invocation{
+		fixtures.Invocation(1, []interface{}{"W", 1}),
+		fixtures.Invocation(2, []interface{}{"W", 2}),
+		fixtures.Invocation(1, []interface{}{"W", 3}),
+	}}
+
+	_, merges := newMergeHandler(ONLY_LAST_INVOCATION).mergeAllPossibleInvocations(redesign)
+
+	assert.Equal(t, 1, merges)
+	assert.Empty(t, redesign.MergeDecisions)
+}
@@ -0,0 +1,81 @@
+package redesign
+
+import (
+	"automation/app/files"
+)
+
+// BuildSagaDAG groups consecutive participant invocations that have no data dependency between
+// them, as given by the dependency graph that also governs the merges, into stages that can be
+// executed in parallel. Each invocation depends on every invocation of the previous stage, so the
+// redesign becomes a fork/join DAG instead of a sequence.
+func (svc *DefaultHandler) BuildSagaDAG(redesign *files.FunctionalityRedesign) *files.SagaDAG {
+	dag := &files.SagaDAG{
+		Nodes:  []*files.SagaNode{},
+		Stages: [][]int{},
+	}
+
+	var stage []*files.Invocation
+	for _, invocation := range redesign.Redesign {
+		// invocations without accesses do not execute any work
+		if invocation.ClusterID == -1 || len(invocation.ClusterAccesses) == 0 {
+			continue
+		}
+
+		if len(stage) > 0 && svc.canRunInParallel(redesign, stage, invocation) {
+			stage = append(stage, invocation)
+			continue
+		}
+
+		svc.addStageToDAG(dag, stage)
+		stage = []*files.Invocation{invocation}
+	}
+	svc.addStageToDAG(dag, stage)
+
+	svc.metricsHandler.CalculateParallelismMetrics(dag)
+
+	return dag
+}
+
+func (svc *DefaultHandler) canRunInParallel(redesign *files.FunctionalityRedesign, stage []*files.Invocation, invocation *files.Invocation) bool {
+	if invocation.ClusterID == redesign.OrchestratorID {
+		return false
+	}
+
+	for _, stageInvocation := range stage {
+		if stageInvocation.ClusterID == redesign.OrchestratorID || stageInvocation.ClusterID == invocation.ClusterID {
+			return false
+		}
+
+		if invocation.DependsOn(stageInvocation) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (svc *DefaultHandler) addStageToDAG(dag *files.SagaDAG, stage []*files.Invocation) {
+	if len(stage) == 0 {
+		return
+	}
+
+	var dependencies []int
+	if len(dag.Stages) > 0 {
+		dependencies = dag.Stages[len(dag.Stages)-1]
+	}
+
+	stageIdx := len(dag.Stages)
+	stageInvocations := []int{}
+	for _, invocation := range stage {
+		dag.Nodes = append(dag.Nodes, &files.SagaNode{
+			InvocationID: invocation.ID,
+			ClusterID:    invocation.ClusterID,
+			Stage:        stageIdx,
+			Type:         invocation.Type,
+			DependsOn:    dependencies,
+		})
+		stageInvocations = append(stageInvocations, invocation.ID)
+	}
+
+	dag.Stages = append(dag.Stages, stageInvocations)
+}
@@ -0,0 +1,58 @@
+package redesign_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/configuration"
+	"automation/app/files"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSagaDAG(t *testing.T) {
+	handler := newRedesignHandler(&configuration.Configuration{})
+
+	// the orchestrator of the redesigns is cluster 0, which writes entity 1 first
+	orchestrator := func() *files.Invocation {
+		return fixtures.Invocation(0, []interface{}{"W", 1})
+	}
+
+	cases := []struct {
+		name     string
+		redesign *files.FunctionalityRedesign
+		stages   [][]int
+	}{
+		{
+			"independent participants",
+			fixtures.Trace("Controller",
+				orchestrator(),
+				fixtures.Invocation(1, []interface{}{"W", 2}),
+				fixtures.Invocation(2, []interface{}{"W", 3}),
+			),
+			[][]int{{0}, {1, 2}},
+		},
+		{
+			// the write may depend on the value read before, even of another entity
+			"read dependency",
+			fixtures.Trace("Controller",
+				orchestrator(),
+				fixtures.Invocation(1, []interface{}{"R", 2}),
+				fixtures.Invocation(2, []interface{}{"W", 3}),
+			),
+			[][]int{{0}, {1}, {2}},
+		},
+		{
+			"shared entity",
+			fixtures.Trace("Controller",
+				orchestrator(),
+				fixtures.Invocation(1, []interface{}{"W", 2}),
+				fixtures.Invocation(2, []interface{}{"R", 2}),
+			),
+			[][]int{{0}, {1}, {2}},
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.stages, handler.BuildSagaDAG(c.redesign).Stages, c.name)
+	}
+}
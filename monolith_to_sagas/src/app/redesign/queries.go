@@ -0,0 +1,77 @@
+package redesign
+
+import (
+	"automation/app/configuration"
+	"automation/app/files"
+	"automation/app/metrics"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	APIComposition = "API_COMPOSITION"
+	CQRSView       = "CQRS_VIEW"
+)
+
+// addQueriesToDataset proposes, for each query of the decomposition that reads entities of more
+// than one cluster, an API composition with its aggregator cluster and a replicated view, and
+// adds the inconsistency complexity of both to the dataset
+func (svc *DefaultHandler) addQueriesToDataset(
+	data [][]string, codebase *files.Codebase, decomposition *files.Decomposition, idToEntityMap map[string]string, codebaseConfig configuration.CodebaseConfiguration,
+) [][]string {
+	snapshot := svc.decompositionSnapshot(decomposition)
+
+	controllerNames := []string{}
+	for name, controller := range decomposition.Controllers {
+		if codebaseConfig.ShouldRefactorQuery(name, controller.Type, len(controller.EntitiesPerCluster)) {
+			controllerNames = append(controllerNames, name)
+		}
+	}
+	sort.Strings(controllerNames)
+
+	for _, name := range controllerNames {
+		queryMetrics := svc.metricsHandler.EvaluateQuery(snapshot, name)
+
+		clusterIDs := []int{}
+		for clusterID := range queryMetrics.ClustersEntitiesRead {
+			clusterIDs = append(clusterIDs, clusterID)
+		}
+		sort.Ints(clusterIDs)
+
+		clusters := []string{}
+		entityNames := []string{}
+		for _, clusterID := range clusterIDs {
+			clusters = append(clusters, strconv.Itoa(clusterID))
+			for _, entityID := range queryMetrics.ClustersEntitiesRead[clusterID] {
+				entityNames = append(entityNames, idToEntityMap[strconv.Itoa(entityID)])
+			}
+		}
+
+		data = append(data, []string{
+			codebase.Name,
+			decomposition.DendogramName,
+			decomposition.Name,
+			name,
+			strings.Join(clusters, ", "),
+			strings.Join(entityNames, ", "),
+			strconv.Itoa(queryMetrics.AggregatorID),
+			strconv.Itoa(queryMetrics.CompositionInvocationsCount),
+			strconv.Itoa(queryMetrics.CompositionInconsistencyComplexity),
+			strings.Join(queryMetrics.ViewUpdatingFunctionalities, ", "),
+			strconv.Itoa(queryMetrics.ViewInconsistencyComplexity),
+			preferredQueryStyle(queryMetrics),
+		})
+	}
+
+	return data
+}
+
+// preferredQueryStyle chooses the style with the lowest inconsistency complexity, keeping the API
+// composition on ties since it does not need to maintain a replicated read model
+func preferredQueryStyle(queryMetrics *metrics.QueryMetrics) string {
+	if queryMetrics.ViewInconsistencyComplexity < queryMetrics.CompositionInconsistencyComplexity {
+		return CQRSView
+	}
+	return APIComposition
+}
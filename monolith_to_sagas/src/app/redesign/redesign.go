@@ -4,6 +4,7 @@ import (
 	"automation/app/configuration"
 	"automation/app/files"
 	"automation/app/metrics"
+	"automation/app/simulation"
 	"automation/app/training"
 	"fmt"
 	"sort"
@@ -24,28 +25,82 @@ const (
 	ALL_PREVIOUS_INVOCATIONS = -1
 )
 
+// Rules that forbid merging an invocation into the previous invocation of the same cluster
+const (
+	EMPTY_ORCHESTRATOR_RULE = "empty-orchestrator"
+	READ_DEPENDENCY_RULE    = "read-dependency"
+)
+
 type RedesignHandler interface {
 	EstimateCodebaseOrchestrators(*files.Codebase, map[string]string, configuration.CodebaseConfiguration, *configuration.Results) *configuration.Datasets
 	CreateSagaRedesigns(*files.Decomposition, *files.Controller, *files.FunctionalityRedesign) ([]*files.FunctionalityRedesign, error)
 	RefactorController(*files.Controller, *files.FunctionalityRedesign, *files.Cluster) *files.FunctionalityRedesign
+	CreateChoreographyRedesign(*files.Decomposition, *files.Controller, *files.FunctionalityRedesign) *files.FunctionalityRedesign
+	RefactorControllerAsChoreography(*files.Controller, *files.FunctionalityRedesign) *files.FunctionalityRedesign
+	BuildSagaDAG(*files.FunctionalityRedesign) *files.SagaDAG
+	RelocateEntity(*files.Decomposition, int, string) (*files.Decomposition, error)
+	EvaluateRelocation(*files.Decomposition, int, string) (*RelocationReport, error)
+	MergeClusters(*files.Decomposition, string, string) (*files.Decomposition, error)
+	CompareExpertDecomposition(*files.Dendogram) (*ExpertComparison, error)
+	ReorderInvocations(*files.FunctionalityRedesign) (*files.FunctionalityRedesign, []*files.Reordering)
 }
 
 type DefaultHandler struct {
-	logger          log.Logger
-	metricsHandler  metrics.MetricsHandler
-	trainingHandler training.TrainingHandler
-	execution       configuration.Execution
+	logger            log.Logger
+	metricsHandler    metrics.MetricsHandler
+	trainingHandler   training.TrainingHandler
+	simulationHandler simulation.SimulationHandler
+	execution         configuration.Execution
+
+	// the metrics snapshot of each decomposition being estimated, shared by the controllers of the
+	// decomposition and dropped once the decomposition is estimated
+	snapshots map[*files.Decomposition]*metrics.Snapshot
 }
 
 func New(
-	logger log.Logger, metricsHandler metrics.MetricsHandler, trainingHandler training.TrainingHandler, execution configuration.Execution,
+	logger log.Logger, metricsHandler metrics.MetricsHandler, trainingHandler training.TrainingHandler,
+	simulationHandler simulation.SimulationHandler, execution configuration.Execution,
 ) RedesignHandler {
 	return &DefaultHandler{
-		logger:          log.With(logger, "module", "redesignHandler"),
-		metricsHandler:  metricsHandler,
-		trainingHandler: trainingHandler,
-		execution:       execution,
+		logger:            log.With(logger, "module", "redesignHandler"),
+		metricsHandler:    metricsHandler,
+		trainingHandler:   trainingHandler,
+		simulationHandler: simulationHandler,
+		execution:         execution,
+		snapshots:         map[*files.Decomposition]*metrics.Snapshot{},
+	}
+}
+
+// decompositionSnapshot returns the snapshot kept for the decomposition being estimated, or a new
+// one for the decompositions that are only evaluated once, such as relocated ones
+func (svc *DefaultHandler) decompositionSnapshot(decomposition *files.Decomposition) *metrics.Snapshot {
+	mapMutex.RLock()
+	snapshot, found := svc.snapshots[decomposition]
+	mapMutex.RUnlock()
+
+	if !found {
+		snapshot = metrics.NewSnapshot(decomposition)
+	}
+	return snapshot
+}
+
+func (svc *DefaultHandler) keepDecompositionSnapshot(decomposition *files.Decomposition) *metrics.Snapshot {
+	mapMutex.Lock()
+	defer mapMutex.Unlock()
+
+	snapshot, found := svc.snapshots[decomposition]
+	if !found {
+		snapshot = metrics.NewSnapshot(decomposition)
+		svc.snapshots[decomposition] = snapshot
 	}
+	return snapshot
+}
+
+func (svc *DefaultHandler) dropDecompositionSnapshot(decomposition *files.Decomposition) {
+	mapMutex.Lock()
+	defer mapMutex.Unlock()
+
+	delete(svc.snapshots, decomposition)
 }
 
 func (svc *DefaultHandler) extractValidControllers(decomposition *files.Decomposition, codebaseConfig configuration.CodebaseConfiguration) map[string]*files.Controller {
@@ -87,86 +142,152 @@ func (svc *DefaultHandler) EstimateCodebaseOrchestrators(
 	var refactored bool
 
 	for _, dendogram := range codebase.Dendrograms {
-		decomposition := dendogram.GetDecomposition(
-			codebaseConfig.CutValue, codebaseConfig.UseExpertDecompositions,
-		)
-		if decomposition == nil {
+		decompositions := svc.decompositionsToEstimate(dendogram, codebaseConfig)
+		if len(decompositions) == 0 {
 			svc.logger.Log("Failed to get decomposition from dendogram")
 			continue
 		}
 
-		// Add to each cluster, the list of controllers that use it
-		validControllers := svc.extractValidControllers(decomposition, codebaseConfig)
-
-		for _, controller := range validControllers {
-			refactored = true
-			wg.Add(1)
-			go func(controller *files.Controller) {
-				defer wg.Done()
-				start := time.Now()
-
-				initialRedesign := controller.GetFunctionalityRedesign()
-				svc.metricsHandler.CalculateDecompositionMetrics(decomposition, controller, initialRedesign)
-				controllerTrainingFeatures := svc.trainingHandler.CalculateControllerTrainingFeatures(initialRedesign)
-
-				sagaRedesigns, _ := svc.CreateSagaRedesigns(decomposition, controller, initialRedesign)
-
-				// check if the distance of the best and second best is high enough
-				// if not, remove from dataset
-				if svc.execution.Configuration.ExcludeLowDistanceRedesigns {
-					bestRedesign := sagaRedesigns[0]
-					secondBestRedesign := sagaRedesigns[1]
-					worstRedesign := sagaRedesigns[len(sagaRedesigns)-1]
-					distance := float32(secondBestRedesign.FunctionalityComplexity-bestRedesign.FunctionalityComplexity) / float32(worstRedesign.FunctionalityComplexity-bestRedesign.FunctionalityComplexity)
-					if distance < svc.execution.Configuration.AcceptableComplexityDistanceThreshold {
-						fmt.Printf("\nWill not add functionality %s to dataset\n", controller.Name)
-						return
-					}
+		for _, decomposition := range decompositions {
+			if svc.estimateDecompositionOrchestrators(codebase, decomposition, idToEntityMap, codebaseConfig, results, datasets) {
+				refactored = true
+			}
+		}
+
+		if svc.execution.Configuration.SweepDecompositions {
+			datasets.SweepDataset = svc.addSweepToDataset(datasets.SweepDataset, codebase, dendogram, datasets.Functionalities)
+		}
+
+		if svc.execution.Configuration.CompareExpertDecompositions {
+			if comparison, err := svc.CompareExpertDecomposition(dendogram); err == nil {
+				datasets.ExpertDataset = svc.addExpertComparisonToDataset(datasets.ExpertDataset, codebase, comparison, idToEntityMap)
+			}
+		}
+	}
+
+	if refactored {
+		results.CodebaseExecutionTimes = append(results.CodebaseExecutionTimes, time.Since(codebaseStart))
+	}
+
+	return datasets
+}
+
+// estimateDecompositionOrchestrators redesigns the controllers of the decomposition that should be
+// refactored, adding them to the datasets, and returns if any controller was refactored
+func (svc *DefaultHandler) estimateDecompositionOrchestrators(
+	codebase *files.Codebase, decomposition *files.Decomposition, idToEntityMap map[string]string, codebaseConfig configuration.CodebaseConfiguration,
+	results *configuration.Results, datasets *configuration.Datasets,
+) bool {
+	var refactored bool
+
+	// Add to each cluster, the list of controllers that use it
+	validControllers := svc.extractValidControllers(decomposition, codebaseConfig)
+	snapshot := svc.keepDecompositionSnapshot(decomposition)
+
+	for _, controller := range validControllers {
+		refactored = true
+		wg.Add(1)
+		go func(controller *files.Controller) {
+			defer wg.Done()
+			start := time.Now()
+
+			initialRedesign := controller.GetFunctionalityRedesign()
+			svc.metricsHandler.CalculateDecompositionMetrics(snapshot, decomposition, controller, initialRedesign)
+			if svc.execution.Configuration.ShouldCalculatePerformance() {
+				svc.metricsHandler.CalculateRedesignPerformance(initialRedesign, false, svc.execution.Configuration.CostModel)
+				controller.Performance = initialRedesign.Latency
+			}
+			svc.metricsHandler.CalculateRegisteredMetrics(decomposition, controller, initialRedesign, svc.execution.Configuration.MetricsToCalculate())
+			controllerTrainingFeatures := svc.trainingHandler.CalculateControllerTrainingFeatures(initialRedesign)
+
+			sagaRedesigns, _ := svc.CreateSagaRedesigns(decomposition, controller, initialRedesign)
+
+			var choreographyRedesign *files.FunctionalityRedesign
+			if svc.execution.Configuration.CompareChoreographies {
+				choreographyRedesign = svc.CreateChoreographyRedesign(decomposition, controller, initialRedesign)
+			}
+
+			// check if the distance of the best and second best is high enough
+			// if not, remove from dataset
+			if svc.execution.Configuration.ExcludeLowDistanceRedesigns {
+				bestRedesign := sagaRedesigns[0]
+				secondBestRedesign := sagaRedesigns[1]
+				worstRedesign := sagaRedesigns[len(sagaRedesigns)-1]
+				distance := float32(secondBestRedesign.FunctionalityComplexity-bestRedesign.FunctionalityComplexity) / float32(worstRedesign.FunctionalityComplexity-bestRedesign.FunctionalityComplexity)
+				if distance < svc.execution.Configuration.AcceptableComplexityDistanceThreshold {
+					fmt.Printf("\nWill not add functionality %s to dataset\n", controller.Name)
+					return
 				}
+			}
 
-				for idx, redesign := range sagaRedesigns {
-					if idx == 0 {
-						datasets.MetricsDataset = svc.trainingHandler.AddDataToTrainingDataset(datasets.MetricsDataset, codebase, controller, controllerTrainingFeatures, redesign, idToEntityMap)
-					}
+			mapMutex.Lock()
+			datasets.Functionalities = append(datasets.Functionalities, &configuration.FunctionalityResult{
+				Codebase:        codebase.Name,
+				Decomposition:   decomposition,
+				Controller:      controller,
+				InitialRedesign: initialRedesign,
+				SagaRedesigns:   sagaRedesigns,
+			})
+			mapMutex.Unlock()
 
-					datasets.ComplexitiesDataset = svc.addResultToDataset(
-						datasets.ComplexitiesDataset,
-						codebase,
-						controller,
-						initialRedesign,
-						redesign,
-						redesign.OrchestratorID,
-						idToEntityMap,
-						controllerTrainingFeatures,
-					)
-
-					if svc.execution.Configuration.PrintTraces && ((svc.execution.Configuration.PrintSpecificFunctionality == "" && idx == 0) || (controller.Name == svc.execution.Configuration.PrintSpecificFunctionality)) {
-						fmt.Printf("\n\n---------- %v ----------\n\n", controller.Name)
-						fmt.Printf("Initial redesign\n\n")
-						svc.printRedesignTrace(initialRedesign.Redesign, idToEntityMap)
-
-						fmt.Printf("\n\nSAGA\n")
-						svc.printRedesignTrace(redesign.Redesign, idToEntityMap)
-
-						fmt.Printf("\nFunctionality Complexity: %v\n", redesign.FunctionalityComplexity)
-					}
+			for idx, redesign := range sagaRedesigns {
+				if idx == 0 {
+					rowsCount := len(datasets.MetricsDataset)
+					datasets.MetricsDataset = svc.trainingHandler.AddDataToTrainingDataset(datasets.MetricsDataset, codebase, controller, controllerTrainingFeatures, redesign, idToEntityMap, svc.execution.Configuration.GenerateDependencyGraphs)
 
-					if svc.execution.Configuration.OnlyExportBestRedesign {
-						break
+					// when sweeping, the same functionality is estimated in every decomposition
+					if svc.execution.Configuration.SweepDecompositions {
+						for rowIdx := rowsCount; rowIdx < len(datasets.MetricsDataset); rowIdx++ {
+							datasets.MetricsDataset[rowIdx] = append(datasets.MetricsDataset[rowIdx], decomposition.Name)
+						}
 					}
 				}
 
-				results.FunctionalityExecutionTimes = append(results.FunctionalityExecutionTimes, time.Since(start))
-			}(controller)
-		}
-		wg.Wait()
+				datasets.ComplexitiesDataset = svc.addResultToDataset(
+					datasets.ComplexitiesDataset,
+					codebase,
+					decomposition,
+					controller,
+					initialRedesign,
+					redesign,
+					choreographyRedesign,
+					redesign.OrchestratorID,
+					idToEntityMap,
+					controllerTrainingFeatures,
+				)
+
+				if svc.execution.Configuration.PrintTraces && ((svc.execution.Configuration.PrintSpecificFunctionality == "" && idx == 0) || (controller.Name == svc.execution.Configuration.PrintSpecificFunctionality)) {
+					fmt.Printf("\n\n---------- %v ----------\n\n", controller.Name)
+					fmt.Printf("Initial redesign\n\n")
+					svc.printRedesignTrace(initialRedesign.Redesign, idToEntityMap)
+
+					fmt.Printf("\n\nSAGA\n")
+					svc.printRedesignTrace(redesign.Redesign, idToEntityMap)
+
+					fmt.Printf("\nFunctionality Complexity: %v\n", redesign.FunctionalityComplexity)
+				}
+
+				if svc.execution.Configuration.OnlyExportBestRedesign {
+					break
+				}
+			}
+
+			results.FunctionalityExecutionTimes = append(results.FunctionalityExecutionTimes, time.Since(start))
+		}(controller)
 	}
+	wg.Wait()
 
-	if refactored {
-		results.CodebaseExecutionTimes = append(results.CodebaseExecutionTimes, time.Since(codebaseStart))
+	if svc.execution.Configuration.GenerateCouplingCSV {
+		datasets.CouplingDataset = svc.addCouplingToDataset(datasets.CouplingDataset, codebase, decomposition, datasets.Functionalities)
 	}
 
-	return datasets
+	if svc.execution.Configuration.RedesignQueries {
+		datasets.QueriesDataset = svc.addQueriesToDataset(datasets.QueriesDataset, codebase, decomposition, idToEntityMap, codebaseConfig)
+	}
+
+	svc.dropDecompositionSnapshot(decomposition)
+
+	return refactored
 }
 
 func (svc *DefaultHandler) printRedesignTrace(invocations []*files.Invocation, idToEntityMap map[string]string) {
@@ -179,8 +300,9 @@ func (svc *DefaultHandler) printRedesignTrace(invocations []*files.Invocation, i
 }
 
 func (svc *DefaultHandler) addResultToDataset(
-	data [][]string, codebase *files.Codebase, controller *files.Controller, initialRedesign *files.FunctionalityRedesign,
-	bestRedesign *files.FunctionalityRedesign, orchestratorID int, idToEntityMap map[string]string, initialMetrics map[int]*training.ClusterMetrics,
+	data [][]string, codebase *files.Codebase, decomposition *files.Decomposition, controller *files.Controller, initialRedesign *files.FunctionalityRedesign,
+	bestRedesign *files.FunctionalityRedesign, choreographyRedesign *files.FunctionalityRedesign, orchestratorID int, idToEntityMap map[string]string,
+	initialMetrics map[int]*training.ClusterMetrics,
 ) [][]string {
 	clusterName := strconv.Itoa(orchestratorID)
 	entityNames := []string{}
@@ -198,7 +320,7 @@ func (svc *DefaultHandler) addResultToDataset(
 
 	orchestratorMetrics := initialMetrics[orchestratorID]
 
-	data = append(data, []string{
+	row := []string{
 		codebase.Name,
 		controller.Name,
 		strconv.Itoa(orchestratorID),
@@ -228,29 +350,178 @@ func (svc *DefaultHandler) addResultToDataset(
 		fmt.Sprintf("%f", orchestratorMetrics.InvocationOperationFactor),
 		fmt.Sprintf("%f", orchestratorMetrics.SystemComplexityContributionPercentage),
 		fmt.Sprintf("%f", orchestratorMetrics.FunctionalityComplexityContributionPercentage),
-	})
+	}
 
-	return data
+	if choreographyRedesign != nil {
+		row = append(row,
+			strconv.Itoa(bestRedesign.EventsCount),
+			strconv.Itoa(bestRedesign.CommunicationCoupling),
+			strconv.Itoa(choreographyRedesign.SystemComplexity),
+			strconv.Itoa(choreographyRedesign.FunctionalityComplexity),
+			strconv.Itoa(choreographyRedesign.InvocationsCount),
+			strconv.Itoa(choreographyRedesign.EventsCount),
+			strconv.Itoa(choreographyRedesign.CommunicationCoupling),
+			preferredSagaStyle(bestRedesign, choreographyRedesign),
+		)
+	}
+
+	if svc.execution.Configuration.DetectParallelSteps {
+		row = append(row,
+			strconv.Itoa(bestRedesign.DAG.CriticalPathLength),
+			strconv.Itoa(bestRedesign.DAG.MaxFanOut),
+		)
+	}
+
+	if svc.execution.Configuration.ReorderInvocations {
+		row = append(row,
+			strconv.Itoa(len(bestRedesign.Reorderings)),
+		)
+	}
+
+	if svc.execution.Configuration.CalculatePerformance {
+		row = append(row,
+			fmt.Sprintf("%f", initialRedesign.Latency),
+			fmt.Sprintf("%f", bestRedesign.Latency),
+			fmt.Sprintf("%f", initialRedesign.Latency-bestRedesign.Latency),
+			strconv.Itoa(initialRedesign.MessagesCount),
+			strconv.Itoa(bestRedesign.MessagesCount),
+			strconv.Itoa(initialRedesign.MessagesSize),
+			strconv.Itoa(bestRedesign.MessagesSize),
+		)
+	}
+
+	if svc.execution.Configuration.SimulateFailures {
+		row = append(row,
+			fmt.Sprintf("%f", bestRedesign.Failures.AbortProbability),
+			fmt.Sprintf("%f", bestRedesign.Failures.Compensations),
+			fmt.Sprintf("%f", bestRedesign.Failures.RolledBackSteps),
+			fmt.Sprintf("%f", bestRedesign.Failures.Retries),
+			fmt.Sprintf("%f", bestRedesign.Failures.FailureCost),
+			strconv.Itoa(bestRedesign.Failures.FailureCostRank),
+		)
+	}
+
+	for _, name := range svc.execution.Configuration.Metrics {
+		row = append(row,
+			fmt.Sprintf("%f", initialRedesign.Metrics[name]),
+			fmt.Sprintf("%f", bestRedesign.Metrics[name]),
+		)
+	}
+
+	if svc.execution.Configuration.GenerateDependencyGraphs {
+		row = append(row,
+			strconv.Itoa(orchestratorMetrics.DependencyDepth),
+			fmt.Sprintf("%f", orchestratorMetrics.DependencyDepthFactor),
+		)
+	}
+
+	if svc.execution.Configuration.SweepDecompositions {
+		row = append(row, decomposition.Name)
+	}
+
+	return append(data, row)
 }
 
-func (svc *DefaultHandler) CreateSagaRedesigns(decomposition *files.Decomposition, controller *files.Controller, initialRedesign *files.FunctionalityRedesign) ([]*files.FunctionalityRedesign, error) {
-	sagaRedesigns := []*files.FunctionalityRedesign{}
+// addCouplingToDataset compares the coupling of the decomposition calculated with the monolith
+// traces of every functionality against the one calculated with the best redesign of the
+// functionalities that were redesigned, per cluster and for the whole decomposition
+func (svc *DefaultHandler) addCouplingToDataset(
+	data [][]string, codebase *files.Codebase, decomposition *files.Decomposition, functionalities []*configuration.FunctionalityResult,
+) [][]string {
+	bestRedesigns := map[string]*files.FunctionalityRedesign{}
+	for _, functionality := range functionalities {
+		if functionality.Decomposition == decomposition && functionality.GetBestRedesign() != nil {
+			bestRedesigns[functionality.Controller.Name] = functionality.GetBestRedesign()
+		}
+	}
 
-	for clusterName := range controller.EntitiesPerCluster {
-		cluster := decomposition.Clusters[clusterName]
+	initialTraces := []*files.FunctionalityRedesign{}
+	redesignedTraces := []*files.FunctionalityRedesign{}
+	for _, controller := range decomposition.Controllers {
+		initialRedesign := controller.GetFunctionalityRedesign()
+		if initialRedesign == nil {
+			continue
+		}
+		initialTraces = append(initialTraces, initialRedesign)
+
+		if bestRedesign, found := bestRedesigns[controller.Name]; found {
+			redesignedTraces = append(redesignedTraces, bestRedesign)
+		} else {
+			redesignedTraces = append(redesignedTraces, initialRedesign)
+		}
+	}
+
+	initialCoupling := svc.metricsHandler.CalculateTracesCoupling(decomposition, initialTraces)
+	redesignedCoupling := svc.metricsHandler.CalculateTracesCoupling(decomposition, redesignedTraces)
+
+	clusterNames := []string{}
+	for clusterName := range decomposition.Clusters {
+		clusterNames = append(clusterNames, clusterName)
+	}
+	sort.Slice(clusterNames, func(i, j int) bool {
+		first, _ := strconv.Atoi(clusterNames[i])
+		second, _ := strconv.Atoi(clusterNames[j])
+		return first < second
+	})
 
-		redesign := svc.RefactorController(controller, initialRedesign, cluster)
+	addRow := func(clusterName string, initial float32, redesigned float32) {
+		data = append(data, []string{
+			codebase.Name,
+			decomposition.DendogramName,
+			decomposition.Name,
+			clusterName,
+			fmt.Sprintf("%f", initial),
+			fmt.Sprintf("%f", redesigned),
+			fmt.Sprintf("%f", redesigned-initial),
+		})
+	}
 
-		svc.metricsHandler.CalculateDecompositionMetrics(decomposition, controller, redesign)
+	for _, clusterName := range clusterNames {
+		addRow(clusterName, initialCoupling.ClustersCoupling[clusterName], redesignedCoupling.ClustersCoupling[clusterName])
+	}
+	addRow("All", initialCoupling.Coupling, redesignedCoupling.Coupling)
 
-		orchestratorID, _ := strconv.Atoi(clusterName)
-		redesign.OrchestratorID = orchestratorID
+	return data
+}
 
-		sagaRedesigns = append(sagaRedesigns, redesign)
+func (svc *DefaultHandler) CreateSagaRedesigns(decomposition *files.Decomposition, controller *files.Controller, initialRedesign *files.FunctionalityRedesign) ([]*files.FunctionalityRedesign, error) {
+	clusterNames := []string{}
+	for clusterName := range controller.EntitiesPerCluster {
+		clusterNames = append(clusterNames, clusterName)
+	}
+	sort.Strings(clusterNames)
+
+	// every candidate orchestrator is evaluated against the same snapshot of the decomposition,
+	// so the candidates can be evaluated in parallel without seeing each other changes
+	snapshot := svc.decompositionSnapshot(decomposition)
+	sagaRedesigns := make([]*files.FunctionalityRedesign, len(clusterNames))
+
+	var candidatesWg sync.WaitGroup
+	for idx, clusterName := range clusterNames {
+		candidatesWg.Add(1)
+		go func(idx int, clusterName string) {
+			defer candidatesWg.Done()
+			sagaRedesigns[idx] = svc.createSagaRedesign(snapshot, decomposition, decomposition.Clusters[clusterName], controller, initialRedesign)
+		}(idx, clusterName)
+	}
+	candidatesWg.Wait()
+
+	if svc.execution.Configuration.SimulateFailures {
+		rankByFailureCost(sagaRedesigns)
 	}
 
 	// order the redesigns by ascending complexity
 	sort.Slice(sagaRedesigns, func(i, j int) bool {
+		for _, name := range svc.execution.Configuration.RankingMetrics {
+			if sagaRedesigns[i].Metrics[name] != sagaRedesigns[j].Metrics[name] {
+				return sagaRedesigns[i].Metrics[name] < sagaRedesigns[j].Metrics[name]
+			}
+		}
+
+		if svc.execution.Configuration.MinimizeLatency && sagaRedesigns[i].Latency != sagaRedesigns[j].Latency {
+			return sagaRedesigns[i].Latency < sagaRedesigns[j].Latency
+		}
+
 		if svc.execution.Configuration.MinimizeSumBothComplexities {
 			return sagaRedesigns[i].FunctionalityComplexity+sagaRedesigns[i].SystemComplexity < sagaRedesigns[j].FunctionalityComplexity+sagaRedesigns[j].SystemComplexity
 		}
@@ -265,7 +536,73 @@ func (svc *DefaultHandler) CreateSagaRedesigns(decomposition *files.Decompositio
 	return sagaRedesigns, nil
 }
 
+func (svc *DefaultHandler) createSagaRedesign(
+	snapshot *metrics.Snapshot, decomposition *files.Decomposition, cluster *files.Cluster, controller *files.Controller, initialRedesign *files.FunctionalityRedesign,
+) *files.FunctionalityRedesign {
+	redesign := svc.RefactorController(controller, initialRedesign, cluster)
+
+	// the complexities are evaluated before the orchestrator is set, as they always were, so the
+	// exported clusters with multiple invocations keep their values
+	svc.metricsHandler.EvaluateRedesign(snapshot, controller.Name, redesign).Apply(redesign)
+
+	orchestratorID, _ := strconv.Atoi(cluster.Name)
+	redesign.OrchestratorID = orchestratorID
+
+	svc.metricsHandler.CalculateCommunicationMetrics(redesign)
+
+	if svc.execution.Configuration.DetectParallelSteps {
+		redesign.DAG = svc.BuildSagaDAG(redesign)
+	}
+
+	if svc.execution.Configuration.ShouldCalculatePerformance() {
+		svc.metricsHandler.CalculateRedesignPerformance(redesign, true, svc.execution.Configuration.CostModel)
+	}
+
+	if svc.execution.Configuration.SimulateFailures {
+		redesign.Failures = svc.simulationHandler.SimulateFailures(redesign, svc.execution.Configuration.FailureModel)
+	}
+
+	svc.metricsHandler.CalculateRegisteredMetrics(decomposition, controller, redesign, svc.execution.Configuration.MetricsToCalculate())
+
+	return redesign
+}
+
+// rankByFailureCost ranks the redesigns from the most to the least resilient, so the dataset shows
+// if the orchestrator with the lowest complexity is also the one that wastes less work on failures
+func rankByFailureCost(sagaRedesigns []*files.FunctionalityRedesign) {
+	ranked := make([]*files.FunctionalityRedesign, len(sagaRedesigns))
+	copy(ranked, sagaRedesigns)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Failures.FailureCost < ranked[j].Failures.FailureCost
+	})
+
+	for idx, redesign := range ranked {
+		redesign.Failures.FailureCostRank = idx + 1
+	}
+}
+
 func (svc *DefaultHandler) RefactorController(controller *files.Controller, initialRedesign *files.FunctionalityRedesign, orchestrator *files.Cluster) *files.FunctionalityRedesign {
+	orchestratorID, _ := strconv.Atoi(orchestrator.Name)
+	redesign := svc.refactorTrace(controller, initialRedesign, orchestratorID)
+
+	// the merges depend on the order of the invocations, so the reordered trace is only kept if
+	// it ends with less invocations. The merge decisions audited for the discarded trace are
+	// dropped with it, so the audit only explains the redesign returned.
+	if svc.execution.Configuration.ReorderInvocations {
+		reorderedTrace, reorderings := svc.ReorderInvocations(initialRedesign)
+		if len(reorderings) > 0 {
+			reorderedRedesign := svc.refactorTrace(controller, reorderedTrace, orchestratorID)
+			if len(reorderedRedesign.Redesign) < len(redesign.Redesign) {
+				reorderedRedesign.Reorderings = reorderings
+				redesign = reorderedRedesign
+			}
+		}
+	}
+
+	return redesign
+}
+
+func (svc *DefaultHandler) refactorTrace(controller *files.Controller, initialRedesign *files.FunctionalityRedesign, orchestratorID int) *files.FunctionalityRedesign {
 	redesign := &files.FunctionalityRedesign{
 		Name:                    controller.Name,
 		UsedForMetrics:          true,
@@ -277,9 +614,14 @@ func (svc *DefaultHandler) RefactorController(controller *files.Controller, init
 	}
 
 	// Initialize Invocation, set dependencies and orchestrator
-	orchestratorID, _ := strconv.Atoi(orchestrator.Name)
 	redesign = svc.addOrchestratorPivotInvocations(orchestratorID, initialRedesign, redesign)
 
+	svc.mergeInvocationsUntilStable(controller, redesign)
+
+	return redesign
+}
+
+func (svc *DefaultHandler) mergeInvocationsUntilStable(controller *files.Controller, redesign *files.FunctionalityRedesign) {
 	// while any merge is done, iterate all the invocations
 	var mergedInvocations int
 	iterate := true
@@ -293,8 +635,6 @@ func (svc *DefaultHandler) RefactorController(controller *files.Controller, init
 			iterate = false
 		}
 	}
-
-	return redesign
 }
 
 func (svc *DefaultHandler) addOrchestratorPivotInvocations(
@@ -323,12 +663,16 @@ func (svc *DefaultHandler) addOrchestratorPivotInvocations(
 			invocationID++
 		}
 
-		// add actual invocation
+		// add actual invocation, copying the accesses so merging the invocations of one redesign
+		// does not change the initial redesign or the other redesigns created from it
+		clusterAccesses := make([][]interface{}, len(initialInvocation.ClusterAccesses))
+		copy(clusterAccesses, initialInvocation.ClusterAccesses)
+
 		invocation := &files.Invocation{
 			Name:              fmt.Sprintf("%d: %d", invocationID, initialInvocation.ClusterID),
 			ID:                invocationID,
 			ClusterID:         initialInvocation.ClusterID,
-			ClusterAccesses:   initialInvocation.ClusterAccesses,
+			ClusterAccesses:   clusterAccesses,
 			RemoteInvocations: []int{},
 			Type:              "COMPENSATABLE",
 		}
@@ -360,7 +704,19 @@ func (svc *DefaultHandler) mergeAllPossibleInvocations(redesign *files.Functiona
 		} else {
 			destinyInvocationIdx := prevInvocations[len(prevInvocations)-1]
 
-			if svc.isMergeForbidden(invocations, destinyInvocationIdx, originalInvocationIdx) {
+			forbiddingRule := svc.mergeForbiddingRule(invocations, destinyInvocationIdx, originalInvocationIdx)
+			if svc.execution.Configuration.AuditMerges {
+				redesign.MergeDecisions = append(redesign.MergeDecisions, &files.MergeDecision{
+					Iteration:             redesign.RecursiveIterations + 1,
+					SourceInvocation:      originalInvocationIdx,
+					DestinationInvocation: destinyInvocationIdx,
+					ClusterID:             originalInvocation.ClusterID,
+					Merged:                forbiddingRule == "",
+					ForbiddingRule:        forbiddingRule,
+				})
+			}
+
+			if forbiddingRule != "" {
 				addToPreviousInvocations = true
 			} else {
 				invocations, prevClusterInvocations, deleted = svc.mergeInvocations(invocations, prevClusterInvocations, destinyInvocationIdx, originalInvocationIdx)
@@ -390,9 +746,11 @@ func (svc *DefaultHandler) mergeAllPossibleInvocations(redesign *files.Functiona
 	return invocations, mergeCount
 }
 
-func (svc *DefaultHandler) isMergeForbidden(
+// mergeForbiddingRule returns the rule that forbids merging the original invocation into the
+// destiny invocation, or an empty string if they can be merged
+func (svc *DefaultHandler) mergeForbiddingRule(
 	invocations []*files.Invocation, destinyInvocationIdx int, originalInvocationIdx int,
-) bool {
+) string {
 	var isLastInvocation bool
 	if originalInvocationIdx == len(invocations)-1 {
 		isLastInvocation = true
@@ -401,41 +759,41 @@ func (svc *DefaultHandler) isMergeForbidden(
 	originalInvocation := invocations[originalInvocationIdx]
 
 	if len(originalInvocation.ClusterAccesses) == 0 && !isLastInvocation && destinyInvocationIdx != originalInvocationIdx-1 {
-		return true
+		return EMPTY_ORCHESTRATOR_RULE
 	}
 
 	// if the invocation is just R, it can be merged
 	if !originalInvocation.ContainsLock() || destinyInvocationIdx == originalInvocationIdx-1 {
-		return false
+		return ""
 	}
 
-	var mergeForbidden bool
-	for idx := originalInvocationIdx - 1; idx >= 0; idx-- {
-		pivotInvocation := invocations[idx]
-		if pivotInvocation.ClusterID == originalInvocation.ClusterID {
-			break
+	// the invocation writes, so it cannot be moved before the invocations between both whose read
+	// values it may depend on. The last invocation with accesses is always considered and the
+	// previous ones only inside the window of the data dependence threshold, which is measured in
+	// positions of the redesign and only closes at an invocation with accesses
+	readDependencies := map[int]bool{}
+	for _, edge := range files.InvocationDependencies(invocations, originalInvocationIdx) {
+		if edge.IsReadDependency() {
+			readDependencies[edge.From] = true
 		}
+	}
 
-		if len(pivotInvocation.ClusterAccesses) == 0 {
+	threshold := svc.execution.Configuration.DataDependenceThreshold
+	for idx := originalInvocationIdx - 1; idx > destinyInvocationIdx; idx-- {
+		if len(invocations[idx].ClusterAccesses) == 0 {
 			continue
 		}
 
-		if svc.execution.Configuration.DataDependenceThreshold == ONLY_LAST_INVOCATION {
-			mergeForbidden = pivotInvocation.ContainsRead()
-			break
-		} else {
-			mergeForbidden = pivotInvocation.ContainsRead()
-			if mergeForbidden {
-				break
-			}
+		if readDependencies[idx] {
+			return READ_DEPENDENCY_RULE
+		}
 
-			if (svc.execution.Configuration.DataDependenceThreshold != ALL_PREVIOUS_INVOCATIONS) && (originalInvocationIdx-idx == svc.execution.Configuration.DataDependenceThreshold) {
-				break
-			}
+		if threshold == ONLY_LAST_INVOCATION || (threshold != ALL_PREVIOUS_INVOCATIONS && originalInvocationIdx-idx == threshold) {
+			break
 		}
 	}
 
-	return mergeForbidden
+	return ""
 }
 
 func (svc *DefaultHandler) mergeInvocations(
@@ -2,23 +2,28 @@ package redesign_test
 
 import (
 	"automation/app/common/log"
+	"automation/app/configuration"
 	"automation/app/files"
 	"automation/app/metrics"
 	"automation/app/redesign"
+	"automation/app/simulation"
+	"automation/app/training"
 	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func newRedesignHandler() redesign.RedesignHandler {
-	logger := log.NewLogger()
+func newRedesignHandler(config *configuration.Configuration) redesign.RedesignHandler {
+	logger := log.NewNopLogger()
 	metricsHandler := metrics.New(logger)
-	return redesign.New(logger, metricsHandler)
+	trainingHandler := training.New(logger)
+	simulationHandler := simulation.New(logger)
+	return redesign.New(logger, metricsHandler, trainingHandler, simulationHandler, configuration.Execution{Configuration: config})
 }
 
 func TestRedesignUsingRules(t *testing.T) {
-	handler := newRedesignHandler()
+	handler := newRedesignHandler(&configuration.Configuration{})
 
 	controller := &files.Controller{
 		Name:                   "Controller",
@@ -115,11 +120,14 @@ func TestRedesignUsingRules(t *testing.T) {
 		Entities:             []int{1, 5},
 	}
 
-	result := handler.RedesignControllerUsingRules(controller, initialRedesign, orchestrator)
+	result := handler.RefactorController(controller, initialRedesign, orchestrator)
 
 	expectation := &files.FunctionalityRedesign{
-		Name:           "currentRedesign",
-		UsedForMetrics: true,
+		Name:                    "Controller",
+		UsedForMetrics:          true,
+		RecursiveIterations:     2,
+		MergedInvocationsCount:  2,
+		InitialInvocationsCount: 7,
 		Redesign: []*files.Invocation{
 			{
 				Name:      "0: 0",
@@ -131,7 +139,7 @@ func TestRedesignUsingRules(t *testing.T) {
 					},
 				},
 				RemoteInvocations: []int{},
-				Type:              "RETRIABLE",
+				Type:              "COMPENSATABLE",
 			},
 			{
 				Name:      "1: 1",
@@ -186,3 +194,56 @@ func TestRedesignUsingRules(t *testing.T) {
 
 	assert.Equal(t, expectation, result)
 }
+
+func TestCreateSagaRedesignsEvaluatesBeforeSettingTheOrchestrator(t *testing.T) {
+	handler := newRedesignHandler(&configuration.Configuration{})
+
+	controller := &files.Controller{
+		Name:               "Controller",
+		Type:               metrics.Saga,
+		Entities:           map[string]int{"1": metrics.WriteMode, "2": metrics.ReadMode, "3": metrics.WriteMode, "4": metrics.WriteMode},
+		EntitiesPerCluster: map[string][]int{"0": {1, 3}, "1": {2}, "2": {4}},
+	}
+	decomposition := &files.Decomposition{
+		Clusters: map[string]*files.Cluster{
+			"0": {Name: "0", Entities: []int{1, 3}},
+			"1": {Name: "1", Entities: []int{2}},
+			"2": {Name: "2", Entities: []int{4}},
+		},
+		Controllers:           map[string]*files.Controller{controller.Name: controller},
+		EntityIDToClusterName: map[string]string{"1": "0", "2": "1", "3": "0", "4": "2"},
+	}
+
+	// A(0, W) B(1, R) C(0, W) D(2, W), where C cannot be merged into A as it may depend on the read
+	// of B
+	initialRedesign := &files.FunctionalityRedesign{
+		Name: controller.Name,
+		Redesign: []*files.Invocation{
+			{ClusterID: -1},
+			{ID: 0, ClusterID: 0, ClusterAccesses: [][]interface{}{{"W", 1}}},
+			{ID: 1, ClusterID: 1, ClusterAccesses: [][]interface{}{{"R", 2}}},
+			{ID: 2, ClusterID: 0, ClusterAccesses: [][]interface{}{{"W", 3}}},
+			{ID: 3, ClusterID: 2, ClusterAccesses: [][]interface{}{{"W", 4}}},
+		},
+	}
+
+	sagaRedesigns, err := handler.CreateSagaRedesigns(decomposition, controller, initialRedesign)
+	assert.NoError(t, err)
+
+	orchestrated := map[int]*files.FunctionalityRedesign{}
+	for _, sagaRedesign := range sagaRedesigns {
+		orchestrated[sagaRedesign.OrchestratorID] = sagaRedesign
+	}
+
+	// the clusters with multiple invocations are counted before the orchestrator is set, so the
+	// empty invocations of the orchestrator are counted unless it is cluster 0
+	assert.Equal(t, 0, orchestrated[0].ClustersBesidesOrchestratorWithMultipleInvocations)
+	assert.Equal(t, 1, orchestrated[1].ClustersBesidesOrchestratorWithMultipleInvocations)
+	assert.Equal(t, 1, orchestrated[2].ClustersBesidesOrchestratorWithMultipleInvocations)
+
+	// while the messages are counted with the orchestrator set, a command and a reply for each
+	// remote invocation with accesses
+	for _, sagaRedesign := range sagaRedesigns {
+		assert.Equal(t, 4, sagaRedesign.EventsCount)
+	}
+}
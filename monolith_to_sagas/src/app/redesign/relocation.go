@@ -0,0 +1,322 @@
+package redesign
+
+import (
+	"automation/app/files"
+	"automation/app/metrics"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+type RelocationReport struct {
+	Decomposition   string
+	EntityID        int
+	FromCluster     string
+	ToCluster       string
+	Functionalities []*RelocationResult
+}
+
+// RelocationResult compares the best redesign of a functionality before and after the entity is
+// moved to the other cluster
+type RelocationResult struct {
+	Functionality                    string
+	InitialOrchestratorID            int
+	RelocatedOrchestratorID          int
+	InitialSystemComplexity          int
+	RelocatedSystemComplexity        int
+	InitialFunctionalityComplexity   int
+	RelocatedFunctionalityComplexity int
+}
+
+// RelocateEntity returns a copy of the decomposition where the entity belongs to the given
+// cluster. The monolith traces of the functionalities that access the entity are rebuilt, so
+// their invocations follow the new clusters, while the decomposition itself is not changed.
+func (svc *DefaultHandler) RelocateEntity(decomposition *files.Decomposition, entityID int, clusterName string) (*files.Decomposition, error) {
+	fromClusterName, found := decomposition.EntityIDToClusterName[strconv.Itoa(entityID)]
+	if !found {
+		err := fmt.Errorf("entity %d is not part of decomposition %s", entityID, decomposition.Name)
+		svc.logger.Log(err)
+		return nil, err
+	}
+
+	if _, found := decomposition.Clusters[clusterName]; !found {
+		err := fmt.Errorf("cluster %s is not part of decomposition %s", clusterName, decomposition.Name)
+		svc.logger.Log(err)
+		return nil, err
+	}
+
+	if fromClusterName == clusterName {
+		err := fmt.Errorf("entity %d already belongs to cluster %s", entityID, clusterName)
+		svc.logger.Log(err)
+		return nil, err
+	}
+
+	return svc.moveEntities(decomposition, []int{entityID}, clusterName, false), nil
+}
+
+// MergeClusters returns a copy of the decomposition where the entities of the first cluster are
+// moved to the second one, which is kept with its name while the first one is removed
+func (svc *DefaultHandler) MergeClusters(decomposition *files.Decomposition, clusterName string, intoClusterName string) (*files.Decomposition, error) {
+	for _, name := range []string{clusterName, intoClusterName} {
+		if _, found := decomposition.Clusters[name]; !found {
+			err := fmt.Errorf("cluster %s is not part of decomposition %s", name, decomposition.Name)
+			svc.logger.Log(err)
+			return nil, err
+		}
+	}
+
+	if clusterName == intoClusterName {
+		err := fmt.Errorf("cluster %s cannot be merged with itself", clusterName)
+		svc.logger.Log(err)
+		return nil, err
+	}
+
+	return svc.moveEntities(decomposition, decomposition.Clusters[clusterName].Entities, intoClusterName, true), nil
+}
+
+// moveEntities copies the decomposition with the entities moved to the cluster, sharing the
+// controllers that are not changed, and removes the clusters left empty when asked. Every cluster
+// is copied, since the controllers of the clusters point to the copied controllers and the
+// coupling dependencies are calculated again from the monolith traces of the copy.
+func (svc *DefaultHandler) moveEntities(decomposition *files.Decomposition, entityIDs []int, clusterName string, removeEmptyClusters bool) *files.Decomposition {
+	moved := map[int]bool{}
+	for _, entityID := range entityIDs {
+		moved[entityID] = true
+	}
+
+	relocated := *decomposition
+	relocated.EntityIDToClusterName = map[string]string{}
+	for id, name := range decomposition.EntityIDToClusterName {
+		relocated.EntityIDToClusterName[id] = name
+	}
+	for entityID := range moved {
+		relocated.EntityIDToClusterName[strconv.Itoa(entityID)] = clusterName
+	}
+
+	relocated.Clusters = map[string]*files.Cluster{}
+	for name, cluster := range decomposition.Clusters {
+		relocatedCluster := *cluster
+		relocatedCluster.Entities = []int{}
+		for _, id := range cluster.Entities {
+			if !moved[id] {
+				relocatedCluster.Entities = append(relocatedCluster.Entities, id)
+			}
+		}
+		if name == clusterName {
+			relocatedCluster.Entities = append(relocatedCluster.Entities, entityIDs...)
+			sort.Ints(relocatedCluster.Entities)
+		}
+
+		if removeEmptyClusters && len(relocatedCluster.Entities) == 0 {
+			continue
+		}
+		relocated.Clusters[name] = &relocatedCluster
+	}
+
+	relocated.Controllers = map[string]*files.Controller{}
+	for name, controller := range decomposition.Controllers {
+		if !touchesEntities(controller, moved) {
+			relocated.Controllers[name] = controller
+			continue
+		}
+
+		relocatedController := *controller
+		relocatedController.EntitiesPerCluster = map[string][]int{}
+		for controllerEntityName := range controller.Entities {
+			id, _ := strconv.Atoi(controllerEntityName)
+			entityClusterName, found := relocated.EntityIDToClusterName[controllerEntityName]
+			if !found {
+				continue
+			}
+			relocatedController.EntitiesPerCluster[entityClusterName] = append(relocatedController.EntitiesPerCluster[entityClusterName], id)
+		}
+		for _, entities := range relocatedController.EntitiesPerCluster {
+			sort.Ints(entities)
+		}
+
+		relocatedController.FunctionalityRedesigns = []*files.FunctionalityRedesign{}
+		if initialRedesign := controller.GetFunctionalityRedesign(); initialRedesign != nil {
+			relocatedController.FunctionalityRedesigns = append(
+				relocatedController.FunctionalityRedesigns, rebuildTrace(initialRedesign, relocated.EntityIDToClusterName),
+			)
+		}
+		relocated.Controllers[name] = &relocatedController
+	}
+
+	clusterControllers := map[string]bool{}
+	for _, cluster := range decomposition.Clusters {
+		for name := range cluster.Controllers {
+			clusterControllers[name] = true
+		}
+	}
+
+	traces := []*files.FunctionalityRedesign{}
+	for _, controller := range relocated.Controllers {
+		if trace := controller.GetFunctionalityRedesign(); trace != nil {
+			traces = append(traces, trace)
+		}
+	}
+	couplingMetrics := svc.metricsHandler.CalculateTracesCoupling(&relocated, traces)
+
+	for name, cluster := range relocated.Clusters {
+		cluster.Controllers = nil
+		for controllerName := range clusterControllers {
+			controller, found := relocated.Controllers[controllerName]
+			if !found {
+				continue
+			}
+			if _, found := controller.EntitiesPerCluster[name]; found {
+				cluster.AddController(controller)
+			}
+		}
+
+		cluster.CouplingDependencies = couplingMetrics.Dependencies[name]
+		cluster.Coupling = couplingMetrics.ClustersCoupling[name]
+	}
+	relocated.Coupling = couplingMetrics.Coupling
+
+	return &relocated
+}
+
+func touchesEntities(controller *files.Controller, entityIDs map[int]bool) bool {
+	for entityID := range entityIDs {
+		if _, found := controller.Entities[strconv.Itoa(entityID)]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuildTrace splits the invocations of the monolith trace by the clusters their entities
+// belong to, joining the consecutive accesses to the same cluster in a single invocation
+func rebuildTrace(trace *files.FunctionalityRedesign, entityIDToClusterName map[string]string) *files.FunctionalityRedesign {
+	rebuilt := &files.FunctionalityRedesign{
+		Name:           trace.Name,
+		UsedForMetrics: true,
+		Redesign:       []*files.Invocation{},
+	}
+
+	var prevInvocation *files.Invocation
+	for _, invocation := range trace.Redesign {
+		if invocation.ClusterID == -1 {
+			rebuilt.Redesign = append(rebuilt.Redesign, invocation)
+			continue
+		}
+
+		for idx, access := range invocation.ClusterAccesses {
+			clusterID := invocation.ClusterID
+			if clusterName, found := entityIDToClusterName[strconv.Itoa(invocation.GetAccessEntityID(idx))]; found {
+				clusterID, _ = strconv.Atoi(clusterName)
+			}
+
+			if prevInvocation == nil || prevInvocation.ClusterID != clusterID {
+				prevInvocation = &files.Invocation{
+					Name:            fmt.Sprintf("%d: %d", len(rebuilt.Redesign), clusterID),
+					ID:              len(rebuilt.Redesign),
+					ClusterID:       clusterID,
+					ClusterAccesses: [][]interface{}{},
+					Type:            invocation.Type,
+				}
+				rebuilt.Redesign = append(rebuilt.Redesign, prevInvocation)
+			}
+			prevInvocation.ClusterAccesses = append(prevInvocation.ClusterAccesses, access)
+		}
+	}
+
+	return rebuilt
+}
+
+// EvaluateRelocation redesigns again only the saga functionalities that access the entity, with
+// the entity in its current cluster and in the given one, and compares their best redesigns
+func (svc *DefaultHandler) EvaluateRelocation(decomposition *files.Decomposition, entityID int, clusterName string) (*RelocationReport, error) {
+	relocated, err := svc.RelocateEntity(decomposition, entityID, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RelocationReport{
+		Decomposition:   decomposition.Name,
+		EntityID:        entityID,
+		FromCluster:     decomposition.EntityIDToClusterName[strconv.Itoa(entityID)],
+		ToCluster:       clusterName,
+		Functionalities: []*RelocationResult{},
+	}
+
+	names := []string{}
+	for name, controller := range decomposition.Controllers {
+		if _, touchesEntity := controller.Entities[strconv.Itoa(entityID)]; touchesEntity && controller.Type != metrics.Query {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		initialBest := svc.bestSagaRedesign(decomposition, decomposition.Controllers[name])
+		relocatedBest := svc.bestSagaRedesign(relocated, relocated.Controllers[name])
+		if initialBest == nil || relocatedBest == nil {
+			continue
+		}
+
+		report.Functionalities = append(report.Functionalities, &RelocationResult{
+			Functionality:                    name,
+			InitialOrchestratorID:            initialBest.OrchestratorID,
+			RelocatedOrchestratorID:          relocatedBest.OrchestratorID,
+			InitialSystemComplexity:          initialBest.SystemComplexity,
+			RelocatedSystemComplexity:        relocatedBest.SystemComplexity,
+			InitialFunctionalityComplexity:   initialBest.FunctionalityComplexity,
+			RelocatedFunctionalityComplexity: relocatedBest.FunctionalityComplexity,
+		})
+	}
+
+	return report, nil
+}
+
+func (svc *DefaultHandler) bestSagaRedesign(decomposition *files.Decomposition, controller *files.Controller) *files.FunctionalityRedesign {
+	initialRedesign := controller.GetFunctionalityRedesign()
+	if initialRedesign == nil {
+		return nil
+	}
+
+	sagaRedesigns, err := svc.CreateSagaRedesigns(decomposition, controller, initialRedesign)
+	if err != nil || len(sagaRedesigns) == 0 {
+		return nil
+	}
+	return sagaRedesigns[0]
+}
+
+func (r *RelocationReport) Dataset(idToEntityMap map[string]string) [][]string {
+	data := [][]string{{
+		"Decomposition",
+		"Entity",
+		"From Cluster",
+		"To Cluster",
+		"Feature",
+		"Initial Orchestrator",
+		"Relocated Orchestrator",
+		"Initial System Complexity",
+		"Relocated System Complexity",
+		"System Complexity Difference",
+		"Initial Functionality Complexity",
+		"Relocated Functionality Complexity",
+		"Functionality Complexity Difference",
+	}}
+
+	for _, result := range r.Functionalities {
+		data = append(data, []string{
+			r.Decomposition,
+			idToEntityMap[strconv.Itoa(r.EntityID)],
+			r.FromCluster,
+			r.ToCluster,
+			result.Functionality,
+			strconv.Itoa(result.InitialOrchestratorID),
+			strconv.Itoa(result.RelocatedOrchestratorID),
+			strconv.Itoa(result.InitialSystemComplexity),
+			strconv.Itoa(result.RelocatedSystemComplexity),
+			strconv.Itoa(result.RelocatedSystemComplexity - result.InitialSystemComplexity),
+			strconv.Itoa(result.InitialFunctionalityComplexity),
+			strconv.Itoa(result.RelocatedFunctionalityComplexity),
+			strconv.Itoa(result.RelocatedFunctionalityComplexity - result.InitialFunctionalityComplexity),
+		})
+	}
+	return data
+}
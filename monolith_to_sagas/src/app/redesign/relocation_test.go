@@ -0,0 +1,95 @@
+package redesign_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/configuration"
+	"automation/app/files"
+	"automation/app/metrics"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeClustersWritesAConsistentDecomposition(t *testing.T) {
+	handler := newRedesignHandler(&configuration.Configuration{})
+
+	writer := &files.Controller{
+		Name:               "Writer",
+		Type:               metrics.Saga,
+		Entities:           map[string]int{"1": metrics.WriteMode, "2": metrics.WriteMode, "3": metrics.ReadMode},
+		EntitiesPerCluster: map[string][]int{"0": {1}, "1": {2}, "2": {3}},
+		FunctionalityRedesigns: []*files.FunctionalityRedesign{fixtures.Trace("Monolith",
+			fixtures.Invocation(0, []interface{}{"W", float64(1)}),
+			fixtures.Invocation(1, []interface{}{"W", float64(2)}),
+			fixtures.Invocation(2, []interface{}{"R", float64(3)}),
+		)},
+	}
+	reader := &files.Controller{
+		Name:               "Reader",
+		Type:               metrics.Saga,
+		Entities:           map[string]int{"3": metrics.ReadMode},
+		EntitiesPerCluster: map[string][]int{"2": {3}},
+		FunctionalityRedesigns: []*files.FunctionalityRedesign{fixtures.Trace("Monolith",
+			fixtures.Invocation(2, []interface{}{"R", float64(3)}),
+		)},
+	}
+
+	decomposition := &files.Decomposition{
+		Name: "Decomposition",
+		Clusters: map[string]*files.Cluster{
+			"0": {Name: "0", Entities: []int{1}, CouplingDependencies: map[string][]int{"1": {2}}},
+			"1": {Name: "1", Entities: []int{2}, CouplingDependencies: map[string][]int{"2": {3}}},
+			"2": {Name: "2", Entities: []int{3}},
+		},
+		Controllers:           map[string]*files.Controller{"Writer": writer, "Reader": reader},
+		EntityIDToClusterName: map[string]string{"1": "0", "2": "1", "3": "2"},
+	}
+	for _, controller := range decomposition.Controllers {
+		for clusterName := range controller.EntitiesPerCluster {
+			decomposition.Clusters[clusterName].AddController(controller)
+		}
+	}
+
+	merged, err := handler.MergeClusters(decomposition, "1", "2")
+	assert.NoError(t, err)
+
+	folder, err := ioutil.TempDir("", "relocation")
+	assert.NoError(t, err)
+	defer os.RemoveAll(folder)
+
+	path := filepath.Join(folder, "codebase.json")
+	byteValue, err := json.MarshalIndent(&files.Codebase{
+		Name:        "codebase",
+		Dendrograms: []*files.Dendogram{{Name: "dendrogram", Decompositions: []*files.Decomposition{merged}}},
+	}, "", "  ")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(path, byteValue, 0644))
+
+	byteValue, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	var codebase files.Codebase
+	assert.NoError(t, json.Unmarshal(byteValue, &codebase))
+	written := codebase.Dendrograms[0].Decompositions[0]
+
+	assert.Len(t, written.Clusters, 2)
+	assert.Equal(t, []int{2, 3}, written.Clusters["2"].Entities)
+
+	// the controllers of the clusters follow the merged clusters
+	assert.Len(t, written.Clusters["0"].Controllers, 1)
+	assert.Equal(t, map[string][]int{"0": {1}, "2": {2, 3}}, written.Clusters["0"].Controllers["Writer"].EntitiesPerCluster)
+	assert.Len(t, written.Clusters["2"].Controllers, 2)
+	assert.Equal(t, map[string][]int{"0": {1}, "2": {2, 3}}, written.Clusters["2"].Controllers["Writer"].EntitiesPerCluster)
+
+	// the dependencies on the removed cluster are calculated again from the monolith traces
+	assert.Equal(t, map[string][]int{"2": {2}}, written.Clusters["0"].CouplingDependencies)
+	assert.Empty(t, written.Clusters["2"].CouplingDependencies)
+
+	// the original decomposition is not changed
+	assert.Len(t, decomposition.Clusters, 3)
+	assert.Equal(t, map[string][]int{"1": {2}}, decomposition.Clusters["0"].CouplingDependencies)
+	assert.Equal(t, map[string][]int{"0": {1}, "1": {2}, "2": {3}}, decomposition.Clusters["0"].Controllers["Writer"].EntitiesPerCluster)
+}
@@ -0,0 +1,62 @@
+package redesign
+
+import (
+	"automation/app/files"
+)
+
+// ReorderInvocations moves each invocation of the monolith trace next to the closest previous
+// invocation of the same cluster, so both can be merged, when it does not depend on any invocation
+// in between. It returns a new trace, without the controller invocation, and the reorderings applied.
+func (svc *DefaultHandler) ReorderInvocations(trace *files.FunctionalityRedesign) (*files.FunctionalityRedesign, []*files.Reordering) {
+	invocations := []*files.Invocation{}
+	for _, invocation := range trace.Redesign {
+		if invocation.ClusterID != -1 {
+			invocations = append(invocations, invocation)
+		}
+	}
+
+	reorderings := []*files.Reordering{}
+	for idx := 1; idx < len(invocations); idx++ {
+		invocation := invocations[idx]
+
+		targetIdx := -1
+		for prevIdx := idx - 1; prevIdx >= 0; prevIdx-- {
+			if invocations[prevIdx].ClusterID == invocation.ClusterID {
+				targetIdx = prevIdx + 1
+				break
+			}
+		}
+
+		if targetIdx == -1 || targetIdx == idx || dependsOnAny(invocations, idx, targetIdx) {
+			continue
+		}
+
+		copy(invocations[targetIdx+1:idx+1], invocations[targetIdx:idx])
+		invocations[targetIdx] = invocation
+
+		reorderings = append(reorderings, &files.Reordering{
+			InvocationID: invocation.ID,
+			ClusterID:    invocation.ClusterID,
+			FromPosition: idx,
+			ToPosition:   targetIdx,
+		})
+	}
+
+	reordered := &files.FunctionalityRedesign{
+		Name:           trace.Name,
+		UsedForMetrics: trace.UsedForMetrics,
+		Redesign:       invocations,
+	}
+	return reordered, reorderings
+}
+
+// dependsOnAny checks if the dependency graph has an edge into the invocation from any invocation
+// from the target position on, which it would be moved before
+func dependsOnAny(invocations []*files.Invocation, idx int, targetIdx int) bool {
+	for _, edge := range files.InvocationDependencies(invocations, idx) {
+		if edge.From >= targetIdx {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package redesign_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/configuration"
+	"automation/app/files"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func clusterOrder(trace *files.FunctionalityRedesign) []int {
+	clusterIDs := []int{}
+	for _, invocation := range trace.Redesign {
+		clusterIDs = append(clusterIDs, invocation.ClusterID)
+	}
+	return clusterIDs
+}
+
+func TestReorderInvocations(t *testing.T) {
+	handler := newRedesignHandler(&configuration.Configuration{})
+
+	cases := []struct {
+		name        string
+		trace       *files.FunctionalityRedesign
+		order       []int
+		reorderings []*files.Reordering
+	}{
+		{
+			"independent invocations",
+			fixtures.Trace("Controller",
+				fixtures.Invocation(1, []interface{}{"W", 1}),
+				fixtures.Invocation(2, []interface{}{"W", 2}),
+				fixtures.Invocation(1, []interface{}{"W", 3}),
+			),
+			[]int{1, 1, 2},
+			[]*files.Reordering{{InvocationID: 2, ClusterID: 1, FromPosition: 2, ToPosition: 1}},
+		},
+		{
+			// the write may depend on the value read in between
+			"read dependency",
+			fixtures.Trace("Controller",
+				fixtures.Invocation(1, []interface{}{"W", 1}),
+				fixtures.Invocation(2, []interface{}{"R", 1}),
+				fixtures.Invocation(1, []interface{}{"W", 3}),
+			),
+			[]int{1, 2, 1},
+			[]*files.Reordering{},
+		},
+		{
+			// the read depends on the value written in between
+			"shared entity",
+			fixtures.Trace("Controller",
+				fixtures.Invocation(1, []interface{}{"R", 1}),
+				fixtures.Invocation(2, []interface{}{"W", 1}),
+				fixtures.Invocation(1, []interface{}{"R", 1}),
+			),
+			[]int{1, 2, 1},
+			[]*files.Reordering{},
+		},
+	}
+
+	for _, c := range cases {
+		initialOrder := clusterOrder(c.trace)
+
+		reordered, reorderings := handler.ReorderInvocations(c.trace)
+
+		assert.Equal(t, c.order, clusterOrder(reordered), c.name)
+		assert.ElementsMatch(t, c.reorderings, reorderings, c.name)
+
+		// the trace is kept as it was
+		assert.Equal(t, initialOrder, clusterOrder(c.trace), c.name)
+	}
+}
@@ -0,0 +1,119 @@
+package redesign
+
+import (
+	"automation/app/configuration"
+	"automation/app/files"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// decompositionsToEstimate returns every decomposition of the dendrogram when sweeping, or the one
+// of the cut value of the codebase otherwise
+func (svc *DefaultHandler) decompositionsToEstimate(dendogram *files.Dendogram, codebaseConfig configuration.CodebaseConfiguration) []*files.Decomposition {
+	if svc.execution.Configuration.SweepDecompositions {
+		return dendogram.Decompositions
+	}
+
+	decomposition := dendogram.GetDecomposition(codebaseConfig.CutValue, codebaseConfig.UseExpertDecompositions)
+	if decomposition == nil {
+		return []*files.Decomposition{}
+	}
+
+	// a dendrogram with a single decomposition returns it whatever the cut value asked for
+	if !decomposition.Expert && decomposition.CutValue != codebaseConfig.CutValue {
+		svc.logger.Log(
+			"Dendrogram %s has no decomposition with cut value %v, using decomposition %s with cut value %v",
+			dendogram.Name, codebaseConfig.CutValue, decomposition.Name, decomposition.CutValue,
+		)
+	}
+
+	return []*files.Decomposition{decomposition}
+}
+
+type decompositionTotals struct {
+	decomposition           *files.Decomposition
+	featuresCount           int
+	functionalityComplexity int
+	systemComplexity        int
+}
+
+func (t *decompositionTotals) total() int {
+	return t.functionalityComplexity + t.systemComplexity
+}
+
+// addSweepToDataset adds the complexities of the best saga redesigns of the functionalities of
+// each decomposition of the dendrogram, summed, and marks the automatic decomposition with the
+// lowest total as the best cut
+func (svc *DefaultHandler) addSweepToDataset(
+	data [][]string, codebase *files.Codebase, dendogram *files.Dendogram, functionalities []*configuration.FunctionalityResult,
+) [][]string {
+	totals := map[*files.Decomposition]*decompositionTotals{}
+	for _, decomposition := range dendogram.Decompositions {
+		totals[decomposition] = &decompositionTotals{decomposition: decomposition}
+	}
+
+	for _, functionality := range functionalities {
+		decompositionTotals, found := totals[functionality.Decomposition]
+		bestRedesign := functionality.GetBestRedesign()
+		if !found || bestRedesign == nil {
+			continue
+		}
+
+		decompositionTotals.featuresCount++
+		decompositionTotals.functionalityComplexity += bestRedesign.FunctionalityComplexity
+		decompositionTotals.systemComplexity += bestRedesign.SystemComplexity
+	}
+
+	sortedTotals := []*decompositionTotals{}
+	for _, decompositionTotals := range totals {
+		sortedTotals = append(sortedTotals, decompositionTotals)
+	}
+	sort.Slice(sortedTotals, func(i, j int) bool {
+		if sortedTotals[i].decomposition.CutValue != sortedTotals[j].decomposition.CutValue {
+			return sortedTotals[i].decomposition.CutValue < sortedTotals[j].decomposition.CutValue
+		}
+		return sortedTotals[i].decomposition.Name < sortedTotals[j].decomposition.Name
+	})
+
+	var bestCut *decompositionTotals
+	for _, decompositionTotals := range sortedTotals {
+		if decompositionTotals.decomposition.Expert {
+			continue
+		}
+		if bestCut == nil || decompositionTotals.total() < bestCut.total() {
+			bestCut = decompositionTotals
+		}
+	}
+
+	if bestCut != nil {
+		fmt.Printf(
+			"\nBest cut of dendrogram %v: %v, with total complexity %v\n",
+			dendogram.Name, bestCut.decomposition.CutValue, bestCut.total(),
+		)
+	}
+
+	for _, decompositionTotals := range sortedTotals {
+		var averageComplexity float32
+		if decompositionTotals.featuresCount > 0 {
+			averageComplexity = float32(decompositionTotals.total()) / float32(decompositionTotals.featuresCount)
+		}
+
+		data = append(data, []string{
+			codebase.Name,
+			dendogram.Name,
+			decompositionTotals.decomposition.Name,
+			fmt.Sprintf("%v", decompositionTotals.decomposition.CutValue),
+			strconv.FormatBool(decompositionTotals.decomposition.Expert),
+			strconv.Itoa(len(decompositionTotals.decomposition.Clusters)),
+			strconv.Itoa(decompositionTotals.featuresCount),
+			strconv.Itoa(decompositionTotals.functionalityComplexity),
+			strconv.Itoa(decompositionTotals.systemComplexity),
+			strconv.Itoa(decompositionTotals.total()),
+			fmt.Sprintf("%f", averageComplexity),
+			strconv.FormatBool(decompositionTotals == bestCut),
+		})
+	}
+
+	return data
+}
@@ -0,0 +1,103 @@
+package redesign
+
+import (
+	"automation/app/configuration"
+	"automation/app/files"
+	"automation/app/training"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddSweepToDataset(t *testing.T) {
+	handler := newMergeHandler(ONLY_LAST_INVOCATION)
+
+	coarse := &files.Decomposition{Name: "Coarse", CutValue: 1, Clusters: map[string]*files.Cluster{"0": {}}}
+	fine := &files.Decomposition{Name: "Fine", CutValue: 3, Clusters: map[string]*files.Cluster{"0": {}, "1": {}, "2": {}}}
+	medium := &files.Decomposition{Name: "Medium", CutValue: 2, Clusters: map[string]*files.Cluster{"0": {}, "1": {}}}
+	expert := &files.Decomposition{Name: "Expert", Expert: true, Clusters: map[string]*files.Cluster{"0": {}, "1": {}}}
+
+	// the complexities of the best redesign of a functionality in a decomposition
+	type redesignComplexities struct {
+		decomposition           *files.Decomposition
+		functionality           string
+		functionalityComplexity int
+		systemComplexity        int
+	}
+
+	cases := []struct {
+		name           string
+		decompositions []*files.Decomposition
+		redesigns      []redesignComplexities
+		data           [][]string
+	}{
+		{
+			// the expert decomposition has the lowest total, but only automatic ones can be the best cut
+			"only automatic decompositions are the best cut",
+			[]*files.Decomposition{fine, expert, coarse, medium},
+			[]redesignComplexities{
+				{coarse, "First", 4, 3},
+				{coarse, "Second", 2, 1},
+				{medium, "First", 2, 1},
+				{medium, "Second", 1, 1},
+				{fine, "First", 5, 5},
+				{expert, "First", 1, 0},
+				{expert, "Second", 0, 0},
+				{&files.Decomposition{Name: "Other"}, "First", 0, 0},
+			},
+			[][]string{
+				{"Codebase", "Dendrogram", "Expert", "0", "true", "2", "2", "1", "0", "1", "0.500000", "false"},
+				{"Codebase", "Dendrogram", "Coarse", "1", "false", "1", "2", "6", "4", "10", "5.000000", "false"},
+				{"Codebase", "Dendrogram", "Medium", "2", "false", "2", "2", "3", "2", "5", "2.500000", "true"},
+				{"Codebase", "Dendrogram", "Fine", "3", "false", "3", "1", "5", "5", "10", "10.000000", "false"},
+			},
+		},
+		{
+			"lower cut on a tie",
+			[]*files.Decomposition{fine, coarse},
+			[]redesignComplexities{
+				{coarse, "First", 2, 2},
+				{fine, "First", 3, 1},
+			},
+			[][]string{
+				{"Codebase", "Dendrogram", "Coarse", "1", "false", "1", "1", "2", "2", "4", "4.000000", "true"},
+				{"Codebase", "Dendrogram", "Fine", "3", "false", "3", "1", "3", "1", "4", "4.000000", "false"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		// a functionality without redesigns is left out of the totals
+		functionalities := []*configuration.FunctionalityResult{{Decomposition: medium, Controller: &files.Controller{Name: "Third"}}}
+		for _, redesign := range c.redesigns {
+			functionalities = append(functionalities, &configuration.FunctionalityResult{
+				Decomposition: redesign.decomposition,
+				Controller:    &files.Controller{Name: redesign.functionality},
+				SagaRedesigns: []*files.FunctionalityRedesign{{
+					FunctionalityComplexity: redesign.functionalityComplexity,
+					SystemComplexity:        redesign.systemComplexity,
+				}},
+			})
+		}
+
+		dendogram := &files.Dendogram{Name: "Dendrogram", Decompositions: c.decompositions}
+		data := handler.addSweepToDataset([][]string{}, &files.Codebase{Name: "Codebase"}, dendogram, functionalities)
+		assert.Equal(t, c.data, data, c.name)
+	}
+}
+
+func TestAddResultToDatasetNamesTheDecompositionWhenSweeping(t *testing.T) {
+	handler := newMergeHandler(ONLY_LAST_INVOCATION)
+	handler.execution.Configuration.SweepDecompositions = true
+
+	controller := &files.Controller{Name: "Controller", EntitiesPerCluster: map[string][]int{"0": {1}}}
+	redesign := &files.FunctionalityRedesign{Name: "Controller"}
+
+	data := handler.addResultToDataset(
+		[][]string{}, &files.Codebase{Name: "Codebase"}, &files.Decomposition{Name: "Medium"}, controller,
+		redesign, redesign, nil, 0, map[string]string{"1": "Entity"}, map[int]*training.ClusterMetrics{0: {}},
+	)
+
+	assert.Len(t, data, 1)
+	assert.Equal(t, "Medium", data[0][len(data[0])-1])
+}
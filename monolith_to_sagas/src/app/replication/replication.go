@@ -0,0 +1,336 @@
+package replication
+
+import (
+	"automation/app/files"
+	"automation/app/metrics"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+)
+
+type ReplicationHandler interface {
+	SuggestReplicas(*files.Decomposition, float32) *ReplicationReport
+	ReplicateReads(*files.FunctionalityRedesign, int, int) (*files.FunctionalityRedesign, []int)
+}
+
+type DefaultHandler struct {
+	logger         log.Logger
+	metricsHandler metrics.MetricsHandler
+}
+
+func New(logger log.Logger, metricsHandler metrics.MetricsHandler) ReplicationHandler {
+	return &DefaultHandler{
+		logger:         log.With(logger, "module", "replicationHandler"),
+		metricsHandler: metricsHandler,
+	}
+}
+
+type ReplicationReport struct {
+	Decomposition string
+	Candidates    []*ReplicaCandidate
+}
+
+// ReplicaCandidate is an entity that would be replicated, read-only, into the clusters that read
+// it remotely. The benefit is the remote invocations and functionality complexity saved by the
+// saga redesigns of the functionalities that read it, and the consistency cost the updates sent to the replicas each time one of the
+// functionalities that write it is executed.
+type ReplicaCandidate struct {
+	EntityID                          int
+	OwnerClusterID                    int
+	ReplicaClusterIDs                 []int
+	RemoteReaders                     []string
+	Writers                           []string
+	WriteRatio                        float32
+	InvocationsCount                  int
+	ReplicatedInvocations             int
+	FunctionalityComplexity           int
+	ReplicatedFunctionalityComplexity int
+	ConsistencyCost                   int
+	Rank                              int
+}
+
+func (c *ReplicaCandidate) SavedInvocations() int {
+	return c.InvocationsCount - c.ReplicatedInvocations
+}
+
+func (c *ReplicaCandidate) FunctionalityComplexityReduction() int {
+	return c.FunctionalityComplexity - c.ReplicatedFunctionalityComplexity
+}
+
+// SuggestReplicas finds the entities read by functionalities that span other clusters and
+// written by at most the given share of the functionalities that access them. For each one the
+// traces of the remote readers are rebuilt as if the entity was replicated, and the candidates
+// are ranked by the remote invocations saved, the functionality complexity reduced and the
+// consistency cost.
+func (svc *DefaultHandler) SuggestReplicas(decomposition *files.Decomposition, maxWriteRatio float32) *ReplicationReport {
+	report := &ReplicationReport{
+		Decomposition: decomposition.Name,
+		Candidates:    []*ReplicaCandidate{},
+	}
+
+	snapshot := metrics.NewSnapshot(decomposition)
+
+	readers := map[int][]*files.Controller{}
+	writers := map[int][]string{}
+	for _, controller := range decomposition.Controllers {
+		for entityName, mode := range controller.Entities {
+			entityID, _ := strconv.Atoi(entityName)
+			if mode >= metrics.WriteMode {
+				writers[entityID] = append(writers[entityID], controller.Name)
+			} else if len(controller.EntitiesPerCluster) > 1 {
+				readers[entityID] = append(readers[entityID], controller)
+			}
+		}
+	}
+
+	for entityID, entityReaders := range readers {
+		cluster := decomposition.GetEntityCluster(entityID)
+		if cluster == nil {
+			continue
+		}
+		ownerClusterID, _ := strconv.Atoi(cluster.Name)
+
+		writeRatio := float32(len(writers[entityID])) / float32(len(entityReaders)+len(writers[entityID]))
+		if writeRatio > maxWriteRatio {
+			continue
+		}
+
+		candidate := &ReplicaCandidate{
+			EntityID:          entityID,
+			OwnerClusterID:    ownerClusterID,
+			ReplicaClusterIDs: []int{},
+			RemoteReaders:     []string{},
+			Writers:           append([]string{}, writers[entityID]...),
+			WriteRatio:        writeRatio,
+		}
+		sort.Strings(candidate.Writers)
+
+		replicaClusters := map[int]bool{}
+		for _, reader := range entityReaders {
+			trace := reader.GetFunctionalityRedesign()
+			if trace == nil {
+				continue
+			}
+
+			replicatedTrace, clusters := svc.ReplicateReads(trace, entityID, ownerClusterID)
+			if len(clusters) == 0 {
+				continue
+			}
+
+			candidate.RemoteReaders = append(candidate.RemoteReaders, reader.Name)
+			for _, clusterID := range clusters {
+				replicaClusters[clusterID] = true
+			}
+
+			candidate.InvocationsCount += invocationsCount(trace)
+			candidate.ReplicatedInvocations += invocationsCount(replicatedTrace)
+			candidate.FunctionalityComplexity += svc.metricsHandler.EvaluateRedesign(snapshot, reader.Name, trace).FunctionalityComplexity
+			candidate.ReplicatedFunctionalityComplexity += svc.metricsHandler.EvaluateRedesign(snapshot, reader.Name, replicatedTrace).FunctionalityComplexity
+		}
+
+		if len(candidate.RemoteReaders) == 0 {
+			continue
+		}
+		sort.Strings(candidate.RemoteReaders)
+
+		for clusterID := range replicaClusters {
+			candidate.ReplicaClusterIDs = append(candidate.ReplicaClusterIDs, clusterID)
+		}
+		sort.Ints(candidate.ReplicaClusterIDs)
+		candidate.ConsistencyCost = len(candidate.Writers) * len(candidate.ReplicaClusterIDs)
+
+		report.Candidates = append(report.Candidates, candidate)
+	}
+
+	sort.Slice(report.Candidates, func(i, j int) bool {
+		first, second := report.Candidates[i], report.Candidates[j]
+		if first.SavedInvocations() != second.SavedInvocations() {
+			return first.SavedInvocations() > second.SavedInvocations()
+		}
+		if first.FunctionalityComplexityReduction() != second.FunctionalityComplexityReduction() {
+			return first.FunctionalityComplexityReduction() > second.FunctionalityComplexityReduction()
+		}
+		if first.ConsistencyCost != second.ConsistencyCost {
+			return first.ConsistencyCost < second.ConsistencyCost
+		}
+		return first.EntityID < second.EntityID
+	})
+
+	for idx, candidate := range report.Candidates {
+		candidate.Rank = idx + 1
+	}
+
+	return report
+}
+
+// ReplicateReads rebuilds the trace as if the entity was replicated: its reads in the owner
+// cluster are done in the cluster of the previous invocation, or of the next one when there is
+// no previous, and the invocations left empty are removed and the consecutive invocations of the
+// same cluster joined. It returns the new trace and the clusters that read the replica.
+func (svc *DefaultHandler) ReplicateReads(trace *files.FunctionalityRedesign, entityID int, ownerClusterID int) (*files.FunctionalityRedesign, []int) {
+	invocations := []*files.Invocation{}
+	for _, invocation := range trace.Redesign {
+		if invocation.ClusterID == -1 || len(invocation.ClusterAccesses) == 0 {
+			continue
+		}
+		invocations = append(invocations, &files.Invocation{
+			Name:            invocation.Name,
+			ID:              invocation.ID,
+			ClusterID:       invocation.ClusterID,
+			ClusterAccesses: append([][]interface{}{}, invocation.ClusterAccesses...),
+			Type:            invocation.Type,
+		})
+	}
+
+	replicaClusters := []int{}
+	for idx := 0; idx < len(invocations); idx++ {
+		invocation := invocations[idx]
+		if invocation.ClusterID != ownerClusterID {
+			continue
+		}
+
+		replicaClusterID := replicaCluster(invocations, idx, ownerClusterID)
+		if replicaClusterID == -1 {
+			continue
+		}
+
+		accesses := [][]interface{}{}
+		replicatedAccesses := [][]interface{}{}
+		for accessIdx, access := range invocation.ClusterAccesses {
+			if invocation.GetAccessEntityID(accessIdx) == entityID && invocation.GetAccessType(accessIdx) == "R" {
+				replicatedAccesses = append(replicatedAccesses, access)
+			} else {
+				accesses = append(accesses, access)
+			}
+		}
+		if len(replicatedAccesses) == 0 {
+			continue
+		}
+
+		invocation.ClusterAccesses = accesses
+		replicaClusters = appendCluster(replicaClusters, replicaClusterID)
+
+		if idx > 0 && invocations[idx-1].ClusterID == replicaClusterID {
+			invocations[idx-1].ClusterAccesses = append(invocations[idx-1].ClusterAccesses, replicatedAccesses...)
+			continue
+		}
+
+		// read the replica in a new invocation right before the one of the owner cluster
+		replicaInvocation := &files.Invocation{
+			ID:              invocation.ID,
+			ClusterID:       replicaClusterID,
+			ClusterAccesses: replicatedAccesses,
+			Type:            invocation.Type,
+		}
+		invocations = append(invocations[:idx], append([]*files.Invocation{replicaInvocation}, invocations[idx:]...)...)
+		idx++
+	}
+
+	replicated := &files.FunctionalityRedesign{
+		Name:     trace.Name,
+		Redesign: []*files.Invocation{},
+	}
+
+	var prevInvocation *files.Invocation
+	for _, invocation := range invocations {
+		if len(invocation.ClusterAccesses) == 0 {
+			continue
+		}
+
+		if prevInvocation != nil && prevInvocation.ClusterID == invocation.ClusterID {
+			prevInvocation.ClusterAccesses = append(prevInvocation.ClusterAccesses, invocation.ClusterAccesses...)
+			continue
+		}
+
+		invocation.ID = len(replicated.Redesign)
+		invocation.Name = fmt.Sprintf("%d: %d", invocation.ID, invocation.ClusterID)
+		replicated.Redesign = append(replicated.Redesign, invocation)
+		prevInvocation = invocation
+	}
+
+	return replicated, replicaClusters
+}
+
+// replicaCluster returns the cluster of the nearest invocation, preferring the previous ones,
+// that is not the owner of the entity
+func replicaCluster(invocations []*files.Invocation, idx int, ownerClusterID int) int {
+	for prevIdx := idx - 1; prevIdx >= 0; prevIdx-- {
+		if invocations[prevIdx].ClusterID != ownerClusterID {
+			return invocations[prevIdx].ClusterID
+		}
+	}
+	for nextIdx := idx + 1; nextIdx < len(invocations); nextIdx++ {
+		if invocations[nextIdx].ClusterID != ownerClusterID {
+			return invocations[nextIdx].ClusterID
+		}
+	}
+	return -1
+}
+
+func appendCluster(clusters []int, clusterID int) []int {
+	for _, id := range clusters {
+		if id == clusterID {
+			return clusters
+		}
+	}
+	return append(clusters, clusterID)
+}
+
+func invocationsCount(trace *files.FunctionalityRedesign) int {
+	var count int
+	for _, invocation := range trace.Redesign {
+		if invocation.ClusterID != -1 && len(invocation.ClusterAccesses) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *ReplicationReport) Dataset(idToEntityMap map[string]string) [][]string {
+	data := [][]string{{
+		"Decomposition",
+		"Rank",
+		"Entity",
+		"Owner Cluster",
+		"Replica Clusters",
+		"Remote Readers",
+		"Writers",
+		"Write Ratio",
+		"Invocations Count",
+		"Replicated Invocations Count",
+		"Saved Invocations",
+		"Functionality Complexity",
+		"Replicated Functionality Complexity",
+		"Functionality Complexity Reduction",
+		"Consistency Cost",
+	}}
+
+	for _, candidate := range r.Candidates {
+		replicaClusters := []string{}
+		for _, clusterID := range candidate.ReplicaClusterIDs {
+			replicaClusters = append(replicaClusters, strconv.Itoa(clusterID))
+		}
+
+		data = append(data, []string{
+			r.Decomposition,
+			strconv.Itoa(candidate.Rank),
+			idToEntityMap[strconv.Itoa(candidate.EntityID)],
+			strconv.Itoa(candidate.OwnerClusterID),
+			strings.Join(replicaClusters, ", "),
+			strings.Join(candidate.RemoteReaders, ", "),
+			strings.Join(candidate.Writers, ", "),
+			fmt.Sprintf("%f", candidate.WriteRatio),
+			strconv.Itoa(candidate.InvocationsCount),
+			strconv.Itoa(candidate.ReplicatedInvocations),
+			strconv.Itoa(candidate.SavedInvocations()),
+			strconv.Itoa(candidate.FunctionalityComplexity),
+			strconv.Itoa(candidate.ReplicatedFunctionalityComplexity),
+			strconv.Itoa(candidate.FunctionalityComplexityReduction()),
+			strconv.Itoa(candidate.ConsistencyCost),
+		})
+	}
+	return data
+}
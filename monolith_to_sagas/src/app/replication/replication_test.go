@@ -0,0 +1,114 @@
+package replication_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/common/log"
+	"automation/app/files"
+	"automation/app/metrics"
+	"automation/app/replication"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicateReads(t *testing.T) {
+	handler := replication.New(log.NewNopLogger(), metrics.New(log.NewNopLogger()))
+	trace := fixtures.Trace("Reader",
+		fixtures.Invocation(0, []interface{}{"W", float64(1)}),
+		fixtures.Invocation(1, []interface{}{"R", float64(5)}),
+		fixtures.Invocation(0, []interface{}{"W", float64(2)}),
+	)
+
+	replicated, clusters := handler.ReplicateReads(trace, 5, 1)
+
+	assert.Equal(t, []int{0}, clusters)
+	assert.Len(t, replicated.Redesign, 1)
+	assert.Equal(t, 0, replicated.Redesign[0].ClusterID)
+	assert.Equal(t, [][]interface{}{{"W", float64(1)}, {"R", float64(5)}, {"W", float64(2)}}, replicated.Redesign[0].ClusterAccesses)
+
+	// the monolith trace is kept as it was
+	assert.Len(t, trace.Redesign, 4)
+	assert.Equal(t, [][]interface{}{{"W", float64(1)}}, trace.Redesign[1].ClusterAccesses)
+}
+
+func TestSuggestReplicas(t *testing.T) {
+	handler := replication.New(log.NewNopLogger(), metrics.New(log.NewNopLogger()))
+
+	// the reader writes entities 1 and 2 of cluster 0 and reads entity 5 of cluster 1 in between,
+	// while the writer reads entity 3 of cluster 2 and writes entity 5
+	decomposition := &files.Decomposition{
+		Name: "N3",
+		Clusters: map[string]*files.Cluster{
+			"0": {Name: "0", Entities: []int{1, 2}},
+			"1": {Name: "1", Entities: []int{5}},
+			"2": {Name: "2", Entities: []int{3}},
+		},
+		Controllers: map[string]*files.Controller{
+			"Reader": {
+				Name:               "Reader",
+				Type:               metrics.Saga,
+				Entities:           map[string]int{"1": metrics.WriteMode, "2": metrics.WriteMode, "5": metrics.ReadMode},
+				EntitiesPerCluster: map[string][]int{"0": {1, 2}, "1": {5}},
+				FunctionalityRedesigns: []*files.FunctionalityRedesign{fixtures.Trace("Reader",
+					fixtures.Invocation(0, []interface{}{"W", float64(1)}),
+					fixtures.Invocation(1, []interface{}{"R", float64(5)}),
+					fixtures.Invocation(0, []interface{}{"W", float64(2)}),
+				)},
+			},
+			"Writer": {
+				Name:               "Writer",
+				Type:               metrics.Saga,
+				Entities:           map[string]int{"3": metrics.ReadMode, "5": metrics.WriteMode},
+				EntitiesPerCluster: map[string][]int{"1": {5}, "2": {3}},
+				FunctionalityRedesigns: []*files.FunctionalityRedesign{fixtures.Trace("Writer",
+					fixtures.Invocation(2, []interface{}{"R", float64(3)}),
+					fixtures.Invocation(1, []interface{}{"W", float64(5)}),
+				)},
+			},
+		},
+		EntityIDToClusterName: map[string]string{"1": "0", "2": "0", "3": "2", "5": "1"},
+	}
+
+	// the replica of entity 5 is still read while the writer may be updating it, and entity 3,
+	// without writers, is read in the cluster of the next invocation
+	replicatedWrittenEntity := &replication.ReplicaCandidate{
+		EntityID:                          5,
+		OwnerClusterID:                    1,
+		ReplicaClusterIDs:                 []int{0},
+		RemoteReaders:                     []string{"Reader"},
+		Writers:                           []string{"Writer"},
+		WriteRatio:                        0.5,
+		InvocationsCount:                  3,
+		ReplicatedInvocations:             1,
+		FunctionalityComplexity:           1,
+		ReplicatedFunctionalityComplexity: 1,
+		ConsistencyCost:                   1,
+		Rank:                              1,
+	}
+	replicatedReadEntity := func(rank int) *replication.ReplicaCandidate {
+		return &replication.ReplicaCandidate{
+			EntityID:              3,
+			OwnerClusterID:        2,
+			ReplicaClusterIDs:     []int{1},
+			RemoteReaders:         []string{"Writer"},
+			Writers:               []string{},
+			InvocationsCount:      2,
+			ReplicatedInvocations: 1,
+			Rank:                  rank,
+		}
+	}
+
+	cases := []struct {
+		name          string
+		maxWriteRatio float32
+		candidates    []*replication.ReplicaCandidate
+	}{
+		{"written by half of the functionalities", 0.5, []*replication.ReplicaCandidate{replicatedWrittenEntity, replicatedReadEntity(2)}},
+		{"written by more than a quarter of the functionalities", 0.25, []*replication.ReplicaCandidate{replicatedReadEntity(1)}},
+	}
+
+	for _, c := range cases {
+		report := handler.SuggestReplicas(decomposition, c.maxWriteRatio)
+		assert.Equal(t, c.candidates, report.Candidates, c.name)
+	}
+}
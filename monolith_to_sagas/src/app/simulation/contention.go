@@ -0,0 +1,494 @@
+package simulation
+
+import (
+	"automation/app/configuration"
+	"automation/app/files"
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+const (
+	arrivalEvent = iota
+	stepStartEvent
+	stepEndEvent
+	lockTimeoutEvent
+)
+
+// ContentionReport is the outcome of running all the chosen redesigns of a decomposition at
+// the same time. Times are in milliseconds and throughputs in sagas per second.
+type ContentionReport struct {
+	Decomposition   string                     `json:"decomposition"`
+	Duration        float32                    `json:"duration"`
+	Functionalities []*FunctionalityContention `json:"functionalities"`
+	Entities        []*EntityContention        `json:"entities"`
+
+	// Pearson correlation between the system complexity of the redesigns and the average time
+	// their sagas waited for semantic locks
+	SystemComplexityWaitCorrelation float32 `json:"system_complexity_wait_correlation"`
+}
+
+type FunctionalityContention struct {
+	Name                    string  `json:"name"`
+	SystemComplexity        int     `json:"system_complexity"`
+	FunctionalityComplexity int     `json:"functionality_complexity"`
+	ArrivalRate             float32 `json:"arrival_rate"`
+	Arrivals                int     `json:"arrivals"`
+	Completed               int     `json:"completed"`
+	Aborted                 int     `json:"aborted"`
+	LockWaits               int     `json:"lock_waits"`
+	TotalWaitTime           float32 `json:"total_wait_time"`
+	AverageWaitTime         float32 `json:"average_wait_time"`
+	Throughput              float32 `json:"throughput"`
+}
+
+type EntityContention struct {
+	EntityID         int     `json:"entity_id"`
+	LockAcquisitions int     `json:"lock_acquisitions"`
+	LockWaits        int     `json:"lock_waits"`
+	TotalWaitTime    float32 `json:"total_wait_time"`
+	AverageWaitTime  float32 `json:"average_wait_time"`
+	Aborts           int     `json:"aborts"`
+}
+
+// contentionStep is a step of a saga, with the entities it accesses and the ones it locks
+type contentionStep struct {
+	Duration         float64
+	AccessedEntities []int
+	WrittenEntities  []int
+}
+
+type contentionFunctionality struct {
+	Steps       []*contentionStep
+	ArrivalRate float64
+	Result      *FunctionalityContention
+}
+
+// sagaInstance is a running execution of a functionality
+type sagaInstance struct {
+	Functionality *contentionFunctionality
+	StepIdx       int
+	HeldLocks     []int
+	WaitingFor    int
+	WaitStart     float64
+	WaitSequence  int
+	Finished      bool
+}
+
+type contentionEvent struct {
+	Time          float64
+	Sequence      int
+	Type          int
+	Instance      *sagaInstance
+	Functionality *contentionFunctionality
+	WaitSequence  int
+}
+
+// eventQueue orders the events by time and, for simultaneous events, by creation order, so the
+// simulation is deterministic for a given seed
+type eventQueue []*contentionEvent
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].Time == q[j].Time {
+		return q[i].Sequence < q[j].Sequence
+	}
+	return q[i].Time < q[j].Time
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*contentionEvent)) }
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	event := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return event
+}
+
+// contentionSimulation keeps the state of the discrete-event simulation: the pending events, the
+// owner of each semantic lock and the sagas waiting for it
+type contentionSimulation struct {
+	Model      configuration.ContentionModel
+	Random     *rand.Rand
+	Now        float64
+	Events     *eventQueue
+	Sequence   int
+	LockOwners map[int]*sagaInstance
+	LockQueues map[int][]*sagaInstance
+	Entities   map[int]*EntityContention
+}
+
+// SimulateContention runs the best redesign of every functionality of the decomposition at the
+// same time, with sagas arriving as Poisson processes. A step that writes an entity takes its
+// semantic lock, which is only released when the saga finishes, and any step that accesses an
+// entity locked by another saga waits for it. Sagas waiting longer than the lock timeout abort,
+// which also releases their locks. Without a lock timeout, the saga whose wait closes a cycle of
+// waits aborts instead, so the deadlock is broken.
+func (svc *DefaultHandler) SimulateContention(
+	decomposition *files.Decomposition, functionalities []*configuration.FunctionalityResult,
+	contentionModel configuration.ContentionModel, costModel configuration.CostModel,
+) *ContentionReport {
+	report := &ContentionReport{
+		Decomposition:   decomposition.Name,
+		Duration:        contentionModel.Duration,
+		Functionalities: []*FunctionalityContention{},
+		Entities:        []*EntityContention{},
+	}
+
+	simulation := &contentionSimulation{
+		Model:      contentionModel,
+		Random:     rand.New(rand.NewSource(contentionModel.Seed)),
+		Events:     &eventQueue{},
+		LockOwners: map[int]*sagaInstance{},
+		LockQueues: map[int][]*sagaInstance{},
+		Entities:   map[int]*EntityContention{},
+	}
+
+	sortedFunctionalities := make([]*configuration.FunctionalityResult, len(functionalities))
+	copy(sortedFunctionalities, functionalities)
+	sort.SliceStable(sortedFunctionalities, func(i, j int) bool {
+		return sortedFunctionalities[i].Controller.Name < sortedFunctionalities[j].Controller.Name
+	})
+
+	for _, functionality := range sortedFunctionalities {
+		redesign := functionality.GetBestRedesign()
+		if functionality.Decomposition != decomposition || redesign == nil {
+			continue
+		}
+
+		arrivalRate := contentionModel.ArrivalRate
+		if rate, found := contentionModel.ArrivalRates[functionality.Controller.Name]; found {
+			arrivalRate = rate
+		}
+
+		result := &FunctionalityContention{
+			Name:                    functionality.Controller.Name,
+			SystemComplexity:        redesign.SystemComplexity,
+			FunctionalityComplexity: redesign.FunctionalityComplexity,
+			ArrivalRate:             arrivalRate,
+		}
+		report.Functionalities = append(report.Functionalities, result)
+
+		if arrivalRate <= 0 {
+			continue
+		}
+
+		contentionFunctionality := &contentionFunctionality{
+			Steps:       buildContentionSteps(redesign, costModel),
+			ArrivalRate: float64(arrivalRate) / 1000,
+			Result:      result,
+		}
+		simulation.scheduleArrival(contentionFunctionality)
+	}
+
+	for simulation.Events.Len() > 0 {
+		event := heap.Pop(simulation.Events).(*contentionEvent)
+		if event.Time > float64(contentionModel.Duration) {
+			break
+		}
+		simulation.Now = event.Time
+
+		switch event.Type {
+		case arrivalEvent:
+			event.Functionality.Result.Arrivals++
+			simulation.schedule(simulation.Now, stepStartEvent, &sagaInstance{Functionality: event.Functionality, WaitingFor: -1}, 0)
+			simulation.scheduleArrival(event.Functionality)
+		case stepStartEvent:
+			simulation.startStep(event.Instance)
+		case stepEndEvent:
+			simulation.endStep(event.Instance)
+		case lockTimeoutEvent:
+			if !event.Instance.Finished && event.Instance.WaitingFor != -1 && event.Instance.WaitSequence == event.WaitSequence {
+				simulation.abort(event.Instance)
+			}
+		}
+	}
+
+	var complexities, waitTimes []float64
+	for _, result := range report.Functionalities {
+		if result.LockWaits > 0 {
+			result.AverageWaitTime = result.TotalWaitTime / float32(result.LockWaits)
+		}
+		if contentionModel.Duration > 0 {
+			result.Throughput = float32(result.Completed) / contentionModel.Duration * 1000
+		}
+
+		// the functionalities that never arrive did not compete for the locks
+		if result.ArrivalRate <= 0 {
+			continue
+		}
+		complexities = append(complexities, float64(result.SystemComplexity))
+		waitTimes = append(waitTimes, float64(result.AverageWaitTime))
+	}
+	report.SystemComplexityWaitCorrelation = float32(pearsonCorrelation(complexities, waitTimes))
+
+	for _, entity := range simulation.Entities {
+		if entity.LockWaits > 0 {
+			entity.AverageWaitTime = entity.TotalWaitTime / float32(entity.LockWaits)
+		}
+		report.Entities = append(report.Entities, entity)
+	}
+	sort.Slice(report.Entities, func(i, j int) bool {
+		return report.Entities[i].EntityID < report.Entities[j].EntityID
+	})
+
+	return report
+}
+
+func buildContentionSteps(redesign *files.FunctionalityRedesign, costModel configuration.CostModel) []*contentionStep {
+	steps := []*contentionStep{}
+	for _, invocation := range redesign.Redesign {
+		if invocation.ClusterID == -1 || len(invocation.ClusterAccesses) == 0 {
+			continue
+		}
+
+		step := &contentionStep{
+			Duration: float64(len(invocation.ClusterAccesses)) * float64(costModel.LocalAccessCost),
+		}
+		if redesign.Choreography || invocation.ClusterID != redesign.OrchestratorID {
+			step.Duration += float64(costModel.RemoteInvocationLatency)
+		}
+
+		for accessIdx := range invocation.ClusterAccesses {
+			entityID := invocation.GetAccessEntityID(accessIdx)
+			step.AccessedEntities = append(step.AccessedEntities, entityID)
+			if invocation.GetAccessType(accessIdx) != "R" {
+				step.WrittenEntities = append(step.WrittenEntities, entityID)
+			}
+		}
+
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func (s *contentionSimulation) schedule(time float64, eventType int, instance *sagaInstance, waitSequence int) {
+	s.Sequence++
+	heap.Push(s.Events, &contentionEvent{
+		Time:         time,
+		Sequence:     s.Sequence,
+		Type:         eventType,
+		Instance:     instance,
+		WaitSequence: waitSequence,
+	})
+}
+
+func (s *contentionSimulation) scheduleArrival(functionality *contentionFunctionality) {
+	s.Sequence++
+	heap.Push(s.Events, &contentionEvent{
+		Time:          s.Now + s.Random.ExpFloat64()/functionality.ArrivalRate,
+		Sequence:      s.Sequence,
+		Type:          arrivalEvent,
+		Functionality: functionality,
+	})
+}
+
+func (s *contentionSimulation) getEntity(entityID int) *EntityContention {
+	entity, found := s.Entities[entityID]
+	if !found {
+		entity = &EntityContention{EntityID: entityID}
+		s.Entities[entityID] = entity
+	}
+	return entity
+}
+
+func (s *contentionSimulation) startStep(instance *sagaInstance) {
+	if instance.Finished {
+		return
+	}
+
+	if instance.StepIdx == len(instance.Functionality.Steps) {
+		s.finish(instance)
+		instance.Functionality.Result.Completed++
+		return
+	}
+
+	step := instance.Functionality.Steps[instance.StepIdx]
+	for _, entityID := range step.AccessedEntities {
+		owner, locked := s.LockOwners[entityID]
+		if !locked || owner == instance {
+			continue
+		}
+
+		// wait in line for the lock, unless the saga was already waiting for it
+		if instance.WaitingFor != entityID {
+			s.stopWaiting(instance)
+			instance.WaitingFor = entityID
+			instance.WaitStart = s.Now
+			instance.WaitSequence++
+			s.LockQueues[entityID] = append(s.LockQueues[entityID], instance)
+			if s.Model.LockTimeout > 0 {
+				s.schedule(s.Now+float64(s.Model.LockTimeout), lockTimeoutEvent, instance, instance.WaitSequence)
+			} else if s.closesWaitCycle(instance) {
+				s.abort(instance)
+			}
+		}
+		return
+	}
+
+	s.stopWaiting(instance)
+
+	for _, entityID := range step.WrittenEntities {
+		if s.LockOwners[entityID] == instance {
+			continue
+		}
+		s.LockOwners[entityID] = instance
+		instance.HeldLocks = append(instance.HeldLocks, entityID)
+		s.getEntity(entityID).LockAcquisitions++
+	}
+
+	s.schedule(s.Now+step.Duration, stepEndEvent, instance, 0)
+}
+
+// stopWaiting accounts for the time the saga waited for a lock and leaves the queue of the lock
+func (s *contentionSimulation) stopWaiting(instance *sagaInstance) {
+	if instance.WaitingFor == -1 {
+		return
+	}
+
+	waitTime := float32(s.Now - instance.WaitStart)
+	entity := s.getEntity(instance.WaitingFor)
+	entity.LockWaits++
+	entity.TotalWaitTime += waitTime
+	instance.Functionality.Result.LockWaits++
+	instance.Functionality.Result.TotalWaitTime += waitTime
+
+	queue := s.LockQueues[instance.WaitingFor]
+	for idx, waiting := range queue {
+		if waiting == instance {
+			s.LockQueues[instance.WaitingFor] = append(queue[:idx], queue[idx+1:]...)
+			break
+		}
+	}
+	instance.WaitingFor = -1
+}
+
+// closesWaitCycle checks if the saga waits, through the owners of the locks, for a lock it holds
+func (s *contentionSimulation) closesWaitCycle(instance *sagaInstance) bool {
+	visited := map[*sagaInstance]bool{}
+	waiting := instance
+	for waiting.WaitingFor != -1 {
+		owner, locked := s.LockOwners[waiting.WaitingFor]
+		if !locked || visited[owner] {
+			return false
+		}
+		if owner == instance {
+			return true
+		}
+		visited[owner] = true
+		waiting = owner
+	}
+	return false
+}
+
+func (s *contentionSimulation) endStep(instance *sagaInstance) {
+	instance.StepIdx++
+	s.startStep(instance)
+}
+
+func (s *contentionSimulation) abort(instance *sagaInstance) {
+	s.getEntity(instance.WaitingFor).Aborts++
+	s.stopWaiting(instance)
+	instance.Functionality.Result.Aborted++
+	s.finish(instance)
+}
+
+// finish releases the semantic locks of the saga and wakes up the sagas waiting for them
+func (s *contentionSimulation) finish(instance *sagaInstance) {
+	instance.Finished = true
+	for _, entityID := range instance.HeldLocks {
+		delete(s.LockOwners, entityID)
+		for _, waiting := range s.LockQueues[entityID] {
+			s.schedule(s.Now, stepStartEvent, waiting, 0)
+		}
+	}
+	instance.HeldLocks = nil
+}
+
+func pearsonCorrelation(xs []float64, ys []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+
+	var sumX, sumY float64
+	for idx := range xs {
+		sumX += xs[idx]
+		sumY += ys[idx]
+	}
+	meanX := sumX / float64(len(xs))
+	meanY := sumY / float64(len(ys))
+
+	var covariance, varianceX, varianceY float64
+	for idx := range xs {
+		covariance += (xs[idx] - meanX) * (ys[idx] - meanY)
+		varianceX += (xs[idx] - meanX) * (xs[idx] - meanX)
+		varianceY += (ys[idx] - meanY) * (ys[idx] - meanY)
+	}
+
+	if varianceX == 0 || varianceY == 0 {
+		return 0
+	}
+	return covariance / math.Sqrt(varianceX*varianceY)
+}
+
+// FunctionalitiesDataset returns the contention of each functionality in the CSV format
+func (r *ContentionReport) FunctionalitiesDataset() [][]string {
+	data := [][]string{{
+		"Decomposition",
+		"Functionality",
+		"System Complexity",
+		"Functionality Complexity",
+		"Arrivals",
+		"Completed",
+		"Aborted",
+		"Lock Waits",
+		"Average Lock Wait Time",
+		"Throughput",
+	}}
+
+	for _, result := range r.Functionalities {
+		data = append(data, []string{
+			r.Decomposition,
+			result.Name,
+			strconv.Itoa(result.SystemComplexity),
+			strconv.Itoa(result.FunctionalityComplexity),
+			strconv.Itoa(result.Arrivals),
+			strconv.Itoa(result.Completed),
+			strconv.Itoa(result.Aborted),
+			strconv.Itoa(result.LockWaits),
+			fmt.Sprintf("%f", result.AverageWaitTime),
+			fmt.Sprintf("%f", result.Throughput),
+		})
+	}
+	return data
+}
+
+// EntitiesDataset returns the contention of each entity in the CSV format
+func (r *ContentionReport) EntitiesDataset(idToEntityMap map[string]string) [][]string {
+	data := [][]string{{
+		"Decomposition",
+		"Entity",
+		"Lock Acquisitions",
+		"Lock Waits",
+		"Average Lock Wait Time",
+		"Aborts",
+	}}
+
+	for _, entity := range r.Entities {
+		data = append(data, []string{
+			r.Decomposition,
+			idToEntityMap[strconv.Itoa(entity.EntityID)],
+			strconv.Itoa(entity.LockAcquisitions),
+			strconv.Itoa(entity.LockWaits),
+			fmt.Sprintf("%f", entity.AverageWaitTime),
+			strconv.Itoa(entity.Aborts),
+		})
+	}
+	return data
+}
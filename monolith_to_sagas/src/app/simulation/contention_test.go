@@ -0,0 +1,135 @@
+package simulation_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/common/log"
+	"automation/app/configuration"
+	"automation/app/files"
+	"automation/app/simulation"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulateContention(t *testing.T) {
+	handler := simulation.New(log.NewNopLogger())
+	decomposition := &files.Decomposition{Name: "N3"}
+	costModel := configuration.CostModel{RemoteInvocationLatency: 10, LocalAccessCost: 1}
+
+	newFunctionality := func(name string, systemComplexity int, invocations ...*files.Invocation) *configuration.FunctionalityResult {
+		redesign := fixtures.Trace(name, invocations...)
+		redesign.SystemComplexity = systemComplexity
+		return &configuration.FunctionalityResult{
+			Decomposition: decomposition,
+			Controller:    &files.Controller{Name: name},
+			SagaRedesigns: []*files.FunctionalityRedesign{redesign},
+		}
+	}
+
+	// the writer keeps the semantic lock on entity 2 until it finishes, which the reader waits for
+	contended := []*configuration.FunctionalityResult{
+		newFunctionality("Writer", 4,
+			fixtures.Invocation(0, []interface{}{"W", float64(1)}),
+			fixtures.Invocation(1, []interface{}{"W", float64(2)}),
+		),
+		newFunctionality("Reader", 2,
+			fixtures.Invocation(1, []interface{}{"R", float64(2)}),
+		),
+		newFunctionality("Unrelated", 0,
+			fixtures.Invocation(2, []interface{}{"R", float64(3)}),
+		),
+	}
+
+	// each functionality locks the entity the other one accesses next
+	deadlocking := []*configuration.FunctionalityResult{
+		newFunctionality("Forward", 0,
+			fixtures.Invocation(0, []interface{}{"W", float64(1)}),
+			fixtures.Invocation(1, []interface{}{"W", float64(2)}),
+		),
+		newFunctionality("Backward", 0,
+			fixtures.Invocation(0, []interface{}{"W", float64(2)}),
+			fixtures.Invocation(1, []interface{}{"W", float64(1)}),
+		),
+	}
+
+	cases := []struct {
+		name            string
+		functionalities []*configuration.FunctionalityResult
+		contentionModel configuration.ContentionModel
+		check           func(*testing.T, *simulation.ContentionReport)
+	}{
+		{
+			"waits for the semantic locks",
+			contended,
+			configuration.ContentionModel{ArrivalRate: 20, LockTimeout: 15, Duration: 60000, Seed: 1},
+			func(t *testing.T, report *simulation.ContentionReport) {
+				assert.Len(t, report.Functionalities, 3)
+				reader := report.Functionalities[0]
+				unrelated := report.Functionalities[1]
+				writer := report.Functionalities[2]
+				assert.Equal(t, "Reader", reader.Name)
+				assert.Equal(t, "Writer", writer.Name)
+
+				assert.True(t, reader.LockWaits > 0)
+				assert.True(t, reader.AverageWaitTime > 0)
+				assert.Equal(t, 0, unrelated.LockWaits)
+				assert.True(t, writer.Completed > 0)
+				assert.True(t, report.SystemComplexityWaitCorrelation > 0)
+
+				assert.Len(t, report.Entities, 2)
+				assert.Equal(t, 1, report.Entities[0].EntityID)
+				assert.True(t, report.Entities[1].LockAcquisitions >= writer.Completed)
+			},
+		},
+		{
+			// the writer holds the lock for 11ms, longer than the sagas are allowed to wait
+			"aborts on the lock timeout",
+			contended,
+			configuration.ContentionModel{ArrivalRates: map[string]float32{"Writer": 200, "Reader": 200}, LockTimeout: 1, Duration: 10000, Seed: 7},
+			func(t *testing.T, report *simulation.ContentionReport) {
+				reader := report.Functionalities[0]
+				assert.True(t, reader.Aborted > 0)
+				assert.Equal(t, 0, report.Functionalities[1].Arrivals)
+
+				var aborts int
+				for _, entity := range report.Entities {
+					aborts += entity.Aborts
+				}
+				assert.Equal(t, reader.Aborted+report.Functionalities[2].Aborted, aborts)
+			},
+		},
+		{
+			// the writers wait for each other and the unrelated sagas never wait, while the reader,
+			// which would also never wait, does not arrive and is left out of the correlation
+			"correlates only the functionalities that arrive",
+			contended,
+			configuration.ContentionModel{ArrivalRates: map[string]float32{"Writer": 20, "Reader": 0, "Unrelated": 20}, LockTimeout: 15, Duration: 60000, Seed: 1},
+			func(t *testing.T, report *simulation.ContentionReport) {
+				assert.Equal(t, float32(0), report.Functionalities[0].ArrivalRate)
+				assert.True(t, report.Functionalities[2].AverageWaitTime > 0)
+				assert.InDelta(t, 1, report.SystemComplexityWaitCorrelation, 0.0001)
+			},
+		},
+		{
+			// without a lock timeout the sagas keep completing after the first deadlock
+			"breaks the deadlocks without a lock timeout",
+			deadlocking,
+			configuration.ContentionModel{ArrivalRate: 100, Duration: 10000, Seed: 3},
+			func(t *testing.T, report *simulation.ContentionReport) {
+				backward := report.Functionalities[0]
+				forward := report.Functionalities[1]
+				assert.True(t, backward.Aborted+forward.Aborted > 0)
+				assert.True(t, backward.Completed > 1)
+				assert.True(t, forward.Completed > 1)
+			},
+		},
+	}
+
+	for _, c := range cases {
+		report := handler.SimulateContention(decomposition, c.functionalities, c.contentionModel, costModel)
+		c.check(t, report)
+
+		// the simulation is seeded, so it is reproducible
+		assert.Equal(t, report, handler.SimulateContention(decomposition, c.functionalities, c.contentionModel, costModel), c.name)
+	}
+}
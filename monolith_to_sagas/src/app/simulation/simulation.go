@@ -0,0 +1,161 @@
+package simulation
+
+import (
+	"automation/app/configuration"
+	"automation/app/files"
+	"math/rand"
+
+	"github.com/go-kit/kit/log"
+)
+
+type SimulationHandler interface {
+	SimulateFailures(*files.FunctionalityRedesign, configuration.FailureModel) *files.FailureSimulation
+	SimulateContention(*files.Decomposition, []*configuration.FunctionalityResult, configuration.ContentionModel, configuration.CostModel) *ContentionReport
+}
+
+type DefaultHandler struct {
+	logger log.Logger
+}
+
+func New(logger log.Logger) SimulationHandler {
+	return &DefaultHandler{
+		logger: log.With(logger, "module", "simulationHandler"),
+	}
+}
+
+// step is an invocation of the redesign executed by the simulator, where the work is the number
+// of entity accesses of the local transaction and the compensation work the number of writes
+// it has to undo
+type step struct {
+	Type             string
+	Remote           bool
+	Work             int
+	CompensationWork int
+}
+
+// execution accumulates the outcome of a single simulated execution of the saga
+type execution struct {
+	Aborted         bool
+	Stalled         bool
+	Compensations   int
+	RolledBackSteps int
+	Retries         int
+	WastedWork      int
+}
+
+// SimulateFailures executes the saga redesign as many times as the failure model asks, injecting
+// failures in the local transaction of each step. A failure up to the pivot aborts the saga, so
+// the completed steps are rolled back and the compensatable ones are compensated in reverse
+// order, while a failure after the pivot is retried until it succeeds or the retries run out.
+// The failure cost is the expected work wasted per execution, counting the failed attempts, the
+// rolled back steps and the compensations. The simulator is seeded, so every candidate of a
+// functionality is evaluated against the same sequence of failures.
+func (svc *DefaultHandler) SimulateFailures(redesign *files.FunctionalityRedesign, failureModel configuration.FailureModel) *files.FailureSimulation {
+	steps := svc.buildSteps(redesign)
+	random := rand.New(rand.NewSource(failureModel.Seed))
+
+	simulation := &files.FailureSimulation{
+		Runs: failureModel.Runs,
+	}
+	if failureModel.Runs <= 0 {
+		return simulation
+	}
+
+	var compensations, rolledBackSteps, retries, wastedWork int
+	for run := 0; run < failureModel.Runs; run++ {
+		result := svc.simulateExecution(steps, failureModel, random)
+
+		if result.Aborted {
+			simulation.AbortedRuns++
+		}
+		if result.Stalled {
+			simulation.StalledRuns++
+		}
+		compensations += result.Compensations
+		rolledBackSteps += result.RolledBackSteps
+		retries += result.Retries
+		wastedWork += result.WastedWork
+	}
+
+	runs := float32(failureModel.Runs)
+	simulation.AbortProbability = float32(simulation.AbortedRuns) / runs
+	simulation.Compensations = float32(compensations) / runs
+	simulation.RolledBackSteps = float32(rolledBackSteps) / runs
+	simulation.Retries = float32(retries) / runs
+	simulation.FailureCost = float32(wastedWork) / runs
+
+	return simulation
+}
+
+func (svc *DefaultHandler) buildSteps(redesign *files.FunctionalityRedesign) []*step {
+	steps := []*step{}
+	for idx, invocation := range redesign.Redesign {
+		if invocation.ClusterID == -1 || len(invocation.ClusterAccesses) == 0 {
+			continue
+		}
+
+		var compensationWork int
+		for accessIdx := range invocation.ClusterAccesses {
+			if invocation.GetAccessType(accessIdx) != "R" {
+				compensationWork++
+			}
+		}
+
+		steps = append(steps, &step{
+			Type:             redesign.GetStepType(idx),
+			Remote:           redesign.Choreography || invocation.ClusterID != redesign.OrchestratorID,
+			Work:             len(invocation.ClusterAccesses),
+			CompensationWork: compensationWork,
+		})
+	}
+	return steps
+}
+
+func (svc *DefaultHandler) simulateExecution(steps []*step, failureModel configuration.FailureModel, random *rand.Rand) *execution {
+	result := &execution{}
+	completedSteps := []*step{}
+	var pivotCompleted bool
+
+	for _, currentStep := range steps {
+		failureProbability := failureModel.LocalStepFailureProbability
+		if currentStep.Remote {
+			failureProbability = failureModel.RemoteStepFailureProbability
+		}
+
+		failed := random.Float32() < failureProbability
+		if failed && !pivotCompleted {
+			result.Aborted = true
+			result.WastedWork += currentStep.Work
+
+			for idx := len(completedSteps) - 1; idx >= 0; idx-- {
+				completedStep := completedSteps[idx]
+				result.RolledBackSteps++
+				result.WastedWork += completedStep.Work
+
+				if completedStep.Type == "COMPENSATABLE" {
+					result.Compensations++
+					result.WastedWork += completedStep.CompensationWork
+				}
+			}
+			return result
+		}
+
+		for retries := 0; failed; retries++ {
+			result.WastedWork += currentStep.Work
+			if retries == failureModel.MaxRetries {
+				result.Stalled = true
+				return result
+			}
+
+			result.Retries++
+			failed = random.Float32() < failureProbability
+		}
+
+		completedSteps = append(completedSteps, currentStep)
+		if currentStep.Type == "PIVOT" {
+			pivotCompleted = true
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,78 @@
+package simulation_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/common/log"
+	"automation/app/configuration"
+	"automation/app/files"
+	"automation/app/simulation"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulateFailures(t *testing.T) {
+	handler := simulation.New(log.NewNopLogger())
+
+	// the transfer writes an account of the orchestrator, then runs the remote steps, where cluster
+	// 2 is the pivot, and reads the account again
+	transfer := fixtures.Trace("AccountController.transfer",
+		fixtures.Invocation(0, []interface{}{"W", float64(1)}),
+		fixtures.Invocation(1, []interface{}{"R", float64(2)}, []interface{}{"W", float64(3)}),
+		fixtures.Invocation(2, []interface{}{"W", float64(4)}),
+		fixtures.Invocation(0, []interface{}{"R", float64(1)}),
+	)
+
+	// the transfer without the first local step
+	remoteFirstTransfer := fixtures.Trace("AccountController.transfer",
+		fixtures.Invocation(1, []interface{}{"R", float64(2)}, []interface{}{"W", float64(3)}),
+		fixtures.Invocation(2, []interface{}{"W", float64(4)}),
+		fixtures.Invocation(0, []interface{}{"R", float64(1)}),
+	)
+
+	cases := []struct {
+		name         string
+		redesign     *files.FunctionalityRedesign
+		failureModel configuration.FailureModel
+		simulation   *files.FailureSimulation
+	}{
+		{
+			"without failures",
+			transfer,
+			configuration.FailureModel{Runs: 100, MaxRetries: 3, Seed: 1},
+			&files.FailureSimulation{Runs: 100},
+		},
+		{
+			// the remote steps always fail, so the saga aborts on the first one, the local step is
+			// rolled back and, since it writes, compensated
+			"compensates the completed steps",
+			transfer,
+			configuration.FailureModel{RemoteStepFailureProbability: 1, Runs: 10, Seed: 1},
+			&files.FailureSimulation{Runs: 10, AbortedRuns: 10, AbortProbability: 1, Compensations: 1, RolledBackSteps: 1, FailureCost: 4},
+		},
+		{
+			// only the local steps fail, and the last one is after the pivot, so it is retried until
+			// the retries run out instead of aborting the saga
+			"retries after the pivot",
+			remoteFirstTransfer,
+			configuration.FailureModel{LocalStepFailureProbability: 1, MaxRetries: 2, Runs: 10, Seed: 1},
+			&files.FailureSimulation{Runs: 10, StalledRuns: 10, Retries: 2, FailureCost: 3},
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.simulation, handler.SimulateFailures(c.redesign, c.failureModel), c.name)
+	}
+
+	// the simulator is seeded, so the same failures happen again
+	failureModel := configuration.FailureModel{
+		RemoteStepFailureProbability: 0.2,
+		LocalStepFailureProbability:  0.1,
+		MaxRetries:                   3,
+		Runs:                         500,
+		Seed:                         42,
+	}
+	first := handler.SimulateFailures(transfer, failureModel)
+	assert.Equal(t, first, handler.SimulateFailures(transfer, failureModel))
+	assert.True(t, first.AbortedRuns > 0)
+}
@@ -0,0 +1,212 @@
+package testplans
+
+import (
+	"automation/app/common/names"
+	"automation/app/files"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	BeforeCommit = "BEFORE_COMMIT"
+	AfterCommit  = "AFTER_COMMIT"
+
+	RolledBack = "ROLLED_BACK"
+	Completed  = "COMPLETED"
+
+	Unchanged = "UNCHANGED"
+	Updated   = "UPDATED"
+)
+
+type TestPlansHandler interface {
+	GenerateTestPlan(*files.Controller, *files.FunctionalityRedesign, map[string]string) *TestPlan
+}
+
+type DefaultHandler struct {
+	logger log.Logger
+}
+
+func New(logger log.Logger) TestPlansHandler {
+	return &DefaultHandler{
+		logger: log.With(logger, "module", "testPlansHandler"),
+	}
+}
+
+type TestPlan struct {
+	Functionality  string      `json:"functionality"`
+	OrchestratorID int         `json:"orchestrator_id"`
+	Choreography   bool        `json:"choreography,omitempty"`
+	Steps          []*Step     `json:"steps"`
+	Scenarios      []*Scenario `json:"scenarios"`
+}
+
+type Step struct {
+	Name      string    `json:"name"`
+	Index     int       `json:"index"`
+	ClusterID int       `json:"cluster_id"`
+	Type      string    `json:"type"`
+	Accesses  []*Access `json:"accesses"`
+}
+
+type Access struct {
+	Entity string `json:"entity"`
+	Mode   string `json:"mode"`
+}
+
+// Scenario is a failure injected in one of the steps, either before its local transaction
+// commits or after, when the changes are persisted but the saga does not learn the outcome.
+// The expected execution lists the steps and compensations in the order they must run.
+type Scenario struct {
+	ID                    string         `json:"id"`
+	FailingStep           string         `json:"failing_step"`
+	FailurePoint          string         `json:"failure_point"`
+	ExpectedOutcome       string         `json:"expected_outcome"`
+	ExpectedCompensations []string       `json:"expected_compensations"`
+	ExpectedRetries       []string       `json:"expected_retries"`
+	ExpectedExecution     []string       `json:"expected_execution"`
+	FinalState            []*EntityState `json:"final_state"`
+}
+
+type EntityState struct {
+	Entity string `json:"entity"`
+	Mode   string `json:"mode"`
+	State  string `json:"state"`
+}
+
+// GenerateTestPlan enumerates every failure point of the saga redesign. A failure up to the
+// pivot, or in the pivot before it commits, rolls the saga back: the committed compensatable
+// steps are compensated in reverse order and every entity ends unchanged. Once the pivot
+// commits the saga can only go forward, so the failing step is retried, which requires it to be
+// idempotent when the failure happens after the commit, and the written entities end updated.
+func (svc *DefaultHandler) GenerateTestPlan(controller *files.Controller, redesign *files.FunctionalityRedesign, idToEntityMap map[string]string) *TestPlan {
+	plan := &TestPlan{
+		Functionality:  controller.Name,
+		OrchestratorID: redesign.OrchestratorID,
+		Choreography:   redesign.Choreography,
+		Steps:          []*Step{},
+		Scenarios:      []*Scenario{},
+	}
+
+	for idx, invocation := range redesign.Redesign {
+		if invocation.ClusterID == -1 || len(invocation.ClusterAccesses) == 0 {
+			continue
+		}
+
+		step := &Step{
+			Name:      fmt.Sprintf("Step%d", len(plan.Steps)),
+			Index:     len(plan.Steps),
+			ClusterID: invocation.ClusterID,
+			Type:      redesign.GetStepType(idx),
+			Accesses:  []*Access{},
+		}
+
+		for accessIdx := range invocation.ClusterAccesses {
+			step.Accesses = append(step.Accesses, &Access{
+				Entity: names.EntityName(invocation.GetAccessEntityID(accessIdx), idToEntityMap),
+				Mode:   invocation.GetAccessType(accessIdx),
+			})
+		}
+
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	for _, step := range plan.Steps {
+		for _, failurePoint := range []string{BeforeCommit, AfterCommit} {
+			plan.Scenarios = append(plan.Scenarios, svc.buildScenario(plan.Steps, step, failurePoint))
+		}
+	}
+
+	return plan
+}
+
+func (svc *DefaultHandler) buildScenario(steps []*Step, failingStep *Step, failurePoint string) *Scenario {
+	scenario := &Scenario{
+		ID:                    fmt.Sprintf("%s-%s", strings.ToLower(failingStep.Name), strings.ToLower(strings.Replace(failurePoint, "_", "-", -1))),
+		FailingStep:           failingStep.Name,
+		FailurePoint:          failurePoint,
+		ExpectedCompensations: []string{},
+		ExpectedRetries:       []string{},
+		ExpectedExecution:     []string{},
+	}
+
+	var pivotCommitted bool
+	for _, step := range steps[:failingStep.Index] {
+		if step.Type == "PIVOT" {
+			pivotCommitted = true
+		}
+	}
+
+	committed := map[string]bool{}
+	compensated := map[string]bool{}
+
+	if pivotCommitted || (failingStep.Type == "PIVOT" && failurePoint == AfterCommit) {
+		// forward recovery, the failing step is retried and the remaining steps are executed
+		scenario.ExpectedOutcome = Completed
+		scenario.ExpectedRetries = append(scenario.ExpectedRetries, failingStep.Name)
+		for _, step := range steps {
+			scenario.ExpectedExecution = append(scenario.ExpectedExecution, step.Name)
+			if step == failingStep {
+				scenario.ExpectedExecution = append(scenario.ExpectedExecution, step.Name)
+			}
+			committed[step.Name] = true
+		}
+	} else {
+		// backward recovery, the committed steps are compensated in reverse order
+		scenario.ExpectedOutcome = RolledBack
+		executedSteps := steps[:failingStep.Index+1]
+		for _, step := range executedSteps {
+			scenario.ExpectedExecution = append(scenario.ExpectedExecution, step.Name)
+			if step != failingStep || failurePoint == AfterCommit {
+				committed[step.Name] = true
+			}
+		}
+
+		for idx := len(executedSteps) - 1; idx >= 0; idx-- {
+			step := executedSteps[idx]
+			if committed[step.Name] && containsWrites(step) {
+				compensation := "Compensate" + step.Name
+				scenario.ExpectedCompensations = append(scenario.ExpectedCompensations, compensation)
+				scenario.ExpectedExecution = append(scenario.ExpectedExecution, compensation)
+				compensated[step.Name] = true
+			}
+		}
+	}
+
+	scenario.FinalState = finalState(steps, committed, compensated)
+	return scenario
+}
+
+// finalState returns the state of each entity touched by the saga, which is updated when a
+// committed step that was not compensated writes it
+func finalState(steps []*Step, committed map[string]bool, compensated map[string]bool) []*EntityState {
+	states := []*EntityState{}
+	statesByEntity := map[string]*EntityState{}
+	for _, step := range steps {
+		for _, access := range step.Accesses {
+			state, found := statesByEntity[access.Entity]
+			if !found {
+				state = &EntityState{Entity: access.Entity, Mode: access.Mode, State: Unchanged}
+				statesByEntity[access.Entity] = state
+				states = append(states, state)
+			} else if state.Mode != access.Mode {
+				state.Mode = "RW"
+			}
+
+			if access.Mode != "R" && committed[step.Name] && !compensated[step.Name] {
+				state.State = Updated
+			}
+		}
+	}
+	return states
+}
+
+func containsWrites(step *Step) bool {
+	for _, access := range step.Accesses {
+		if access.Mode != "R" {
+			return true
+		}
+	}
+	return false
+}
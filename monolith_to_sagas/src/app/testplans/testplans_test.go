@@ -0,0 +1,108 @@
+package testplans_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/common/log"
+	"automation/app/files"
+	"automation/app/testplans"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getScenario(plan *testplans.TestPlan, id string) *testplans.Scenario {
+	for _, scenario := range plan.Scenarios {
+		if scenario.ID == id {
+			return scenario
+		}
+	}
+	return nil
+}
+
+func TestGenerateTestPlan(t *testing.T) {
+	controller := &files.Controller{Name: "VirtualEditionController.approveParticipant"}
+	redesign := fixtures.Trace(controller.Name,
+		fixtures.Invocation(0, []interface{}{"W", float64(1)}),
+		fixtures.Invocation(1, []interface{}{"R", float64(2)}),
+		fixtures.Invocation(2, []interface{}{"RW", float64(3)}),
+		fixtures.Invocation(1, []interface{}{"W", float64(2)}),
+		fixtures.Invocation(0, []interface{}{"R", float64(1)}),
+	)
+	idToEntityMap := map[string]string{"1": "User", "2": "VirtualEdition", "3": "Member"}
+
+	plan := testplans.New(log.NewNopLogger()).GenerateTestPlan(controller, redesign, idToEntityMap)
+
+	// every step fails before and after committing, and step 3 is the pivot
+	assert.Len(t, plan.Steps, 5)
+	assert.Len(t, plan.Scenarios, 10)
+	assert.Equal(t, "PIVOT", plan.Steps[3].Type)
+
+	cases := []struct {
+		scenario      string
+		outcome       string
+		compensations []string
+		retries       []string
+		execution     []string
+		state         string
+	}{
+		// up to the pivot the saga rolls back, compensating the committed steps that write
+		{
+			"step2-before-commit",
+			testplans.RolledBack,
+			[]string{"CompensateStep0"},
+			[]string{},
+			[]string{"Step0", "Step1", "Step2", "CompensateStep0"},
+			testplans.Unchanged,
+		},
+		{
+			"step2-after-commit",
+			testplans.RolledBack,
+			[]string{"CompensateStep2", "CompensateStep0"},
+			[]string{},
+			[]string{"Step0", "Step1", "Step2", "CompensateStep2", "CompensateStep0"},
+			testplans.Unchanged,
+		},
+		{
+			"step3-before-commit",
+			testplans.RolledBack,
+			[]string{"CompensateStep2", "CompensateStep0"},
+			[]string{},
+			[]string{"Step0", "Step1", "Step2", "Step3", "CompensateStep2", "CompensateStep0"},
+			testplans.Unchanged,
+		},
+		// after the pivot the failed step is retried and the saga completes
+		{
+			"step3-after-commit",
+			testplans.Completed,
+			[]string{},
+			[]string{"Step3"},
+			[]string{"Step0", "Step1", "Step2", "Step3", "Step3", "Step4"},
+			testplans.Updated,
+		},
+		{
+			"step4-before-commit",
+			testplans.Completed,
+			[]string{},
+			[]string{"Step4"},
+			[]string{"Step0", "Step1", "Step2", "Step3", "Step4", "Step4"},
+			testplans.Updated,
+		},
+	}
+
+	for _, c := range cases {
+		scenario := getScenario(plan, c.scenario)
+		if !assert.NotNil(t, scenario, c.scenario) {
+			continue
+		}
+
+		assert.Equal(t, c.outcome, scenario.ExpectedOutcome, c.scenario)
+		assert.Equal(t, c.compensations, scenario.ExpectedCompensations, c.scenario)
+		assert.Equal(t, c.retries, scenario.ExpectedRetries, c.scenario)
+		assert.Equal(t, c.execution, scenario.ExpectedExecution, c.scenario)
+		assert.Equal(t, []*testplans.EntityState{
+			{Entity: "User", Mode: "RW", State: c.state},
+			{Entity: "VirtualEdition", Mode: "RW", State: c.state},
+			{Entity: "Member", Mode: "RW", State: c.state},
+		}, scenario.FinalState, c.scenario)
+	}
+}
@@ -10,7 +10,7 @@ import (
 
 type TrainingHandler interface {
 	CalculateControllerTrainingFeatures(*files.FunctionalityRedesign) map[int]*ClusterMetrics
-	AddDataToTrainingDataset([][]string, *files.Codebase, *files.Controller, map[int]*ClusterMetrics, *files.FunctionalityRedesign, map[string]string) [][]string
+	AddDataToTrainingDataset([][]string, *files.Codebase, *files.Controller, map[int]*ClusterMetrics, *files.FunctionalityRedesign, map[string]string, bool) [][]string
 }
 
 type DefaultHandler struct {
@@ -83,6 +83,13 @@ func (svc *DefaultHandler) CalculateControllerTrainingFeatures(redesign *files.F
 		featureMetrics.Invocations += 1
 	}
 
+	dependencyGraph := files.BuildDependencyGraph(redesign.Redesign)
+	for clusterID, depth := range dependencyGraph.ClusterDepths() {
+		if metrics, ok := clusterMetrics[clusterID]; ok {
+			metrics.DependencyDepth = depth
+		}
+	}
+
 	svc.calculateFinalClusterMetrics(&featureMetrics, clusterMetrics, redesign)
 	return clusterMetrics
 }
@@ -111,11 +118,15 @@ func (svc *DefaultHandler) calculateFinalClusterMetrics(featureMetrics *FeatureM
 		featureMetrics.AverageInvocationReadOperations += float32(metrics.AverageInvocationReadOperations) / float32(featureMetrics.Clusters)
 		featureMetrics.AverageInvocationWriteOperations += float32(metrics.AverageInvocationWriteOperations) / float32(featureMetrics.Clusters)
 		featureMetrics.AveragePivotInvocations += float32(metrics.AveragePivotInvocations) / float32(featureMetrics.Clusters)
+		featureMetrics.AverageDependencyDepth += float32(metrics.DependencyDepth) / float32(featureMetrics.Clusters)
 	}
 
 	for _, metrics := range clusterMetrics {
 		metrics.PivotInvocationFactor = float32(metrics.AveragePivotInvocations) / float32(featureMetrics.AveragePivotInvocations)
 		metrics.InvocationOperationFactor = float32(metrics.AverageInvocationOperations) / float32(featureMetrics.AverageInvocationOperations)
+		if featureMetrics.AverageDependencyDepth > 0 {
+			metrics.DependencyDepthFactor = float32(metrics.DependencyDepth) / featureMetrics.AverageDependencyDepth
+		}
 
 		metrics.SystemComplexityContributionPercentage = float32(metrics.ControllerstThatReadInWrittenEntities) / float32(redesign.SystemComplexity)
 		metrics.FunctionalityComplexityContributionPercentage = float32(metrics.ControllersThatWriteInReadEntities) / float32(redesign.FunctionalityComplexity)
@@ -124,6 +135,7 @@ func (svc *DefaultHandler) calculateFinalClusterMetrics(featureMetrics *FeatureM
 
 func (svc *DefaultHandler) AddDataToTrainingDataset(
 	data [][]string, codebase *files.Codebase, controller *files.Controller, clusterMetrics map[int]*ClusterMetrics, redesign *files.FunctionalityRedesign, idToEntityMap map[string]string,
+	dependencyFeatures bool,
 ) [][]string {
 	for cluster, metrics := range clusterMetrics {
 		var result int
@@ -142,7 +154,7 @@ func (svc *DefaultHandler) AddDataToTrainingDataset(
 			entityNamesCSVFormat += name + ", "
 		}
 
-		data = append(data, []string{
+		row := []string{
 			codebase.Name,
 			controller.Name,
 			//controller.Type,
@@ -160,7 +172,16 @@ func (svc *DefaultHandler) AddDataToTrainingDataset(
 			fmt.Sprintf("%f", metrics.SystemComplexityContributionPercentage),
 			fmt.Sprintf("%f", metrics.FunctionalityComplexityContributionPercentage),
 			strconv.Itoa(result),
-		})
+		}
+
+		if dependencyFeatures {
+			row = append(row,
+				strconv.Itoa(metrics.DependencyDepth),
+				fmt.Sprintf("%f", metrics.DependencyDepthFactor),
+			)
+		}
+
+		data = append(data, row)
 	}
 
 	return data
@@ -33,6 +33,8 @@ type ClusterMetrics struct {
 	OperationProbability                          float32 `json:"operation_probability,omitempty"`
 	PivotInvocationFactor                         float32 `json:"pivot_invocation_factor,omitempty"`
 	InvocationOperationFactor                     float32 `json:"invocation_operation_factor,omitempty"`
+	DependencyDepth                               int     `json:"dependency_depth,omitempty"`
+	DependencyDepthFactor                         float32 `json:"dependency_depth_factor,omitempty"`
 	ControllerstThatReadInWrittenEntities         int     `json:"controllerst_that_read_in_written_entities,omitempty"`
 	ControllersThatWriteInReadEntities            int     `json:"controllers_that_write_in_read_entities,omitempty"`
 	FunctionalityComplexityContributionPercentage float32
@@ -51,4 +53,5 @@ type FeatureMetrics struct {
 	AverageInvocationReadOperations  float32 `json:"average_invocation_read_operations,omitempty"`
 	AverageInvocationWriteOperations float32 `json:"average_invocation_write_operations,omitempty"`
 	AveragePivotInvocations          float32 `json:"average_pivot_invocations,omitempty"`
+	AverageDependencyDepth           float32 `json:"average_dependency_depth,omitempty"`
 }
@@ -0,0 +1,243 @@
+package workflows
+
+import (
+	"automation/app/files"
+	"encoding/json"
+	"fmt"
+)
+
+const lambdaInvokeResource = "arn:aws:states:::lambda:invoke"
+
+// the result of the tasks is discarded, so every task receives the input of the execution
+var discardResult = json.RawMessage("null")
+
+var aslStateTypes = map[string]bool{
+	"Task":     true,
+	"Pass":     true,
+	"Choice":   true,
+	"Wait":     true,
+	"Succeed":  true,
+	"Fail":     true,
+	"Parallel": true,
+	"Map":      true,
+}
+
+type StateMachine struct {
+	Comment string               `json:"Comment,omitempty"`
+	StartAt string               `json:"StartAt"`
+	States  map[string]*ASLState `json:"States"`
+}
+
+type ASLState struct {
+	Type       string                 `json:"Type"`
+	Comment    string                 `json:"Comment,omitempty"`
+	Resource   string                 `json:"Resource,omitempty"`
+	Parameters map[string]interface{} `json:"Parameters,omitempty"`
+	ResultPath json.RawMessage        `json:"ResultPath,omitempty"`
+	Next       string                 `json:"Next,omitempty"`
+	End        bool                   `json:"End,omitempty"`
+	Retry      []*ASLRetrier          `json:"Retry,omitempty"`
+	Catch      []*ASLCatcher          `json:"Catch,omitempty"`
+	Error      string                 `json:"Error,omitempty"`
+	Cause      string                 `json:"Cause,omitempty"`
+}
+
+type ASLRetrier struct {
+	ErrorEquals     []string `json:"ErrorEquals"`
+	IntervalSeconds int      `json:"IntervalSeconds,omitempty"`
+	MaxAttempts     *int     `json:"MaxAttempts,omitempty"`
+	BackoffRate     float32  `json:"BackoffRate,omitempty"`
+}
+
+type ASLCatcher struct {
+	ErrorEquals []string        `json:"ErrorEquals"`
+	Next        string          `json:"Next"`
+	ResultPath  json.RawMessage `json:"ResultPath,omitempty"`
+}
+
+// GenerateASL converts the saga redesign into an Amazon States Language state machine, where
+// each invocation is a task that invokes a lambda of its cluster. Failures are caught and
+// routed to the compensations of the completed steps, executed in reverse order.
+func (svc *DefaultHandler) GenerateASL(controller *files.Controller, redesign *files.FunctionalityRedesign, idToEntityMap map[string]string) ([]byte, error) {
+	steps := buildSagaSteps(redesign, idToEntityMap)
+
+	stateMachine := &StateMachine{
+		Comment: fmt.Sprintf("Saga of %s", controller.Name),
+		StartAt: succeededStateName,
+		States: map[string]*ASLState{
+			succeededStateName: {Type: "Succeed"},
+			failedStateName: {
+				Type:  "Fail",
+				Error: "SagaFailed",
+				Cause: fmt.Sprintf("%s was rolled back", controller.Name),
+			},
+		},
+	}
+
+	if len(steps) > 0 {
+		stateMachine.StartAt = steps[0].Name
+	}
+
+	targets := rollbackTargets(steps)
+	for idx, step := range steps {
+		next := succeededStateName
+		if idx < len(steps)-1 {
+			next = steps[idx+1].Name
+		}
+
+		state := &ASLState{
+			Type:     "Task",
+			Comment:  step.Description,
+			Resource: lambdaInvokeResource,
+			Parameters: map[string]interface{}{
+				"FunctionName": step.Resource(),
+				"Payload.$":    "$",
+			},
+			ResultPath: discardResult,
+			Next:       next,
+			Catch: []*ASLCatcher{{
+				ErrorEquals: []string{"States.ALL"},
+				Next:        targets[idx],
+				ResultPath:  json.RawMessage(`"$.error"`),
+			}},
+		}
+
+		if step.Retriable {
+			maxAttempts := retryMaxAttempts
+			state.Retry = []*ASLRetrier{{
+				ErrorEquals:     []string{"States.ALL"},
+				IntervalSeconds: retryIntervalSeconds,
+				MaxAttempts:     &maxAttempts,
+				BackoffRate:     retryBackoffRate,
+			}}
+		}
+
+		stateMachine.States[step.Name] = state
+	}
+
+	compensatableSteps, nextStates := compensationChain(steps)
+	for idx, step := range compensatableSteps {
+		stateMachine.States[step.CompensationName()] = &ASLState{
+			Type:     "Task",
+			Comment:  fmt.Sprintf("Compensation of %s", step.Description),
+			Resource: lambdaInvokeResource,
+			Parameters: map[string]interface{}{
+				"FunctionName": "compensate-" + step.Resource(),
+				"Payload.$":    "$",
+			},
+			ResultPath: discardResult,
+			Next:       nextStates[idx],
+		}
+	}
+
+	document, err := json.MarshalIndent(stateMachine, "", "  ")
+	if err != nil {
+		svc.logger.Log(err)
+		return nil, err
+	}
+
+	err = svc.ValidateASL(document)
+	if err != nil {
+		svc.logger.Log(err)
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// ValidateASL runs structural checks on the state machine, which cover only part of the Amazon
+// States Language specification: the start state and all transitions must target existing
+// states, every state must have a known type, non terminal states must either transition or end
+// and every state must be reachable from the start state
+func (svc *DefaultHandler) ValidateASL(document []byte) error {
+	var stateMachine StateMachine
+	err := json.Unmarshal(document, &stateMachine)
+	if err != nil {
+		return err
+	}
+
+	if len(stateMachine.States) == 0 {
+		return fmt.Errorf("state machine has no states")
+	}
+
+	if _, found := stateMachine.States[stateMachine.StartAt]; !found {
+		return fmt.Errorf("StartAt references unknown state %s", stateMachine.StartAt)
+	}
+
+	for name, state := range stateMachine.States {
+		if len(name) > 80 {
+			return fmt.Errorf("state name %s is longer than 80 characters", name)
+		}
+
+		if !aslStateTypes[state.Type] {
+			return fmt.Errorf("state %s has unknown type %s", name, state.Type)
+		}
+
+		terminal := state.Type == "Succeed" || state.Type == "Fail"
+		if terminal && (state.Next != "" || state.End) {
+			return fmt.Errorf("terminal state %s cannot have Next or End", name)
+		}
+
+		if !terminal && state.Type != "Choice" && (state.Next == "") == !state.End {
+			return fmt.Errorf("state %s must have exactly one of Next or End", name)
+		}
+
+		if state.Type == "Task" && state.Resource == "" {
+			return fmt.Errorf("task state %s has no Resource", name)
+		}
+
+		if state.Next != "" {
+			if _, found := stateMachine.States[state.Next]; !found {
+				return fmt.Errorf("state %s transitions to unknown state %s", name, state.Next)
+			}
+		}
+
+		for _, retrier := range state.Retry {
+			if len(retrier.ErrorEquals) == 0 {
+				return fmt.Errorf("retrier of state %s has no ErrorEquals", name)
+			}
+			if retrier.MaxAttempts != nil && *retrier.MaxAttempts < 0 {
+				return fmt.Errorf("retrier of state %s has negative MaxAttempts", name)
+			}
+			if retrier.BackoffRate != 0 && retrier.BackoffRate < 1 {
+				return fmt.Errorf("retrier of state %s has BackoffRate lower than 1", name)
+			}
+		}
+
+		for _, catcher := range state.Catch {
+			if len(catcher.ErrorEquals) == 0 {
+				return fmt.Errorf("catcher of state %s has no ErrorEquals", name)
+			}
+			if _, found := stateMachine.States[catcher.Next]; !found {
+				return fmt.Errorf("state %s catches to unknown state %s", name, catcher.Next)
+			}
+		}
+	}
+
+	reachable := map[string]bool{}
+	pending := []string{stateMachine.StartAt}
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if reachable[name] {
+			continue
+		}
+		reachable[name] = true
+
+		state := stateMachine.States[name]
+		if state.Next != "" {
+			pending = append(pending, state.Next)
+		}
+		for _, catcher := range state.Catch {
+			pending = append(pending, catcher.Next)
+		}
+	}
+
+	for name := range stateMachine.States {
+		if !reachable[name] && name != failedStateName {
+			return fmt.Errorf("state %s is not reachable", name)
+		}
+	}
+
+	return nil
+}
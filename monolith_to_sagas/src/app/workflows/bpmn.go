@@ -0,0 +1,380 @@
+package workflows
+
+import (
+	"automation/app/files"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+const (
+	bpmnModelNamespace  = "http://www.omg.org/spec/BPMN/20100524/MODEL"
+	camundaNamespace    = "http://camunda.org/schema/1.0/bpmn"
+	bpmnStartEventID    = "SagaStarted"
+	bpmnFailedErrorID   = "SagaFailedError"
+	bpmnTargetNamespace = "http://bpmn.io/schema/bpmn"
+	bpmnRetryTimeCycle  = "R%d/PT%dS"
+)
+
+var (
+	ncNamePattern           = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	invalidNCNameCharacters = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+	bpmnActivities = map[string]bool{
+		"task":             true,
+		"serviceTask":      true,
+		"userTask":         true,
+		"scriptTask":       true,
+		"sendTask":         true,
+		"receiveTask":      true,
+		"callActivity":     true,
+		"subProcess":       true,
+		"businessRuleTask": true,
+	}
+
+	bpmnEvents = map[string]bool{
+		"startEvent":             true,
+		"endEvent":               true,
+		"boundaryEvent":          true,
+		"intermediateThrowEvent": true,
+		"intermediateCatchEvent": true,
+	}
+
+	bpmnGateways = map[string]bool{
+		"exclusiveGateway": true,
+		"parallelGateway":  true,
+		"inclusiveGateway": true,
+	}
+)
+
+type bpmnDefinitions struct {
+	XMLName         xml.Name     `xml:"definitions"`
+	Xmlns           string       `xml:"xmlns,attr"`
+	XmlnsCamunda    string       `xml:"xmlns:camunda,attr"`
+	ID              string       `xml:"id,attr"`
+	TargetNamespace string       `xml:"targetNamespace,attr"`
+	Errors          []*bpmnError `xml:"error"`
+	Process         *bpmnProcess `xml:"process"`
+}
+
+type bpmnError struct {
+	ID        string `xml:"id,attr"`
+	Name      string `xml:"name,attr"`
+	ErrorCode string `xml:"errorCode,attr"`
+}
+
+type bpmnProcess struct {
+	ID             string               `xml:"id,attr"`
+	Name           string               `xml:"name,attr"`
+	IsExecutable   bool                 `xml:"isExecutable,attr"`
+	StartEvent     *bpmnEvent           `xml:"startEvent"`
+	ServiceTasks   []*bpmnServiceTask   `xml:"serviceTask"`
+	BoundaryEvents []*bpmnBoundaryEvent `xml:"boundaryEvent"`
+	EndEvents      []*bpmnEvent         `xml:"endEvent"`
+	SequenceFlows  []*bpmnSequenceFlow  `xml:"sequenceFlow"`
+}
+
+type bpmnEvent struct {
+	ID                   string                    `xml:"id,attr"`
+	Name                 string                    `xml:"name,attr,omitempty"`
+	ErrorEventDefinition *bpmnErrorEventDefinition `xml:"errorEventDefinition,omitempty"`
+}
+
+type bpmnErrorEventDefinition struct {
+	ErrorRef string `xml:"errorRef,attr,omitempty"`
+}
+
+type bpmnServiceTask struct {
+	ID                string                 `xml:"id,attr"`
+	Name              string                 `xml:"name,attr"`
+	Type              string                 `xml:"camunda:type,attr"`
+	Topic             string                 `xml:"camunda:topic,attr"`
+	AsyncBefore       bool                   `xml:"camunda:asyncBefore,attr,omitempty"`
+	ExtensionElements *bpmnExtensionElements `xml:"extensionElements,omitempty"`
+}
+
+type bpmnExtensionElements struct {
+	FailedJobRetryTimeCycle string `xml:"camunda:failedJobRetryTimeCycle"`
+}
+
+type bpmnBoundaryEvent struct {
+	ID                   string                    `xml:"id,attr"`
+	AttachedToRef        string                    `xml:"attachedToRef,attr"`
+	ErrorEventDefinition *bpmnErrorEventDefinition `xml:"errorEventDefinition"`
+}
+
+type bpmnSequenceFlow struct {
+	ID        string `xml:"id,attr"`
+	SourceRef string `xml:"sourceRef,attr"`
+	TargetRef string `xml:"targetRef,attr"`
+}
+
+// GenerateBPMN converts the saga redesign into a BPMN 2.0 process. Each invocation is a service
+// task with an error boundary event that leads to the compensations of the completed steps,
+// executed in reverse order, and retriable tasks are retried by the engine using the Camunda
+// failedJobRetryTimeCycle extension.
+func (svc *DefaultHandler) GenerateBPMN(controller *files.Controller, redesign *files.FunctionalityRedesign, idToEntityMap map[string]string) ([]byte, error) {
+	steps := buildSagaSteps(redesign, idToEntityMap)
+	processID := invalidNCNameCharacters.ReplaceAllString("Saga_"+controller.Name, "_")
+
+	process := &bpmnProcess{
+		ID:           processID,
+		Name:         controller.Name,
+		IsExecutable: true,
+		StartEvent:   &bpmnEvent{ID: bpmnStartEventID},
+		EndEvents: []*bpmnEvent{
+			{ID: succeededStateName},
+			{ID: failedStateName, ErrorEventDefinition: &bpmnErrorEventDefinition{ErrorRef: bpmnFailedErrorID}},
+		},
+	}
+
+	addFlow := func(sourceRef string, targetRef string) {
+		process.SequenceFlows = append(process.SequenceFlows, &bpmnSequenceFlow{
+			ID:        fmt.Sprintf("Flow_%d", len(process.SequenceFlows)),
+			SourceRef: sourceRef,
+			TargetRef: targetRef,
+		})
+	}
+
+	previous := bpmnStartEventID
+	targets := rollbackTargets(steps)
+	for idx, step := range steps {
+		task := &bpmnServiceTask{
+			ID:    step.Name,
+			Name:  step.Description,
+			Type:  "external",
+			Topic: step.Resource(),
+		}
+
+		if step.Retriable {
+			task.AsyncBefore = true
+			task.ExtensionElements = &bpmnExtensionElements{
+				FailedJobRetryTimeCycle: fmt.Sprintf(bpmnRetryTimeCycle, retryMaxAttempts, retryIntervalSeconds),
+			}
+		}
+
+		process.ServiceTasks = append(process.ServiceTasks, task)
+		addFlow(previous, step.Name)
+
+		boundaryEventID := step.Name + "Failed"
+		process.BoundaryEvents = append(process.BoundaryEvents, &bpmnBoundaryEvent{
+			ID:                   boundaryEventID,
+			AttachedToRef:        step.Name,
+			ErrorEventDefinition: &bpmnErrorEventDefinition{},
+		})
+		addFlow(boundaryEventID, targets[idx])
+
+		previous = step.Name
+	}
+	addFlow(previous, succeededStateName)
+
+	compensatableSteps, nextStates := compensationChain(steps)
+	for idx, step := range compensatableSteps {
+		process.ServiceTasks = append(process.ServiceTasks, &bpmnServiceTask{
+			ID:    step.CompensationName(),
+			Name:  fmt.Sprintf("Compensation of %s", step.Description),
+			Type:  "external",
+			Topic: "compensate-" + step.Resource(),
+		})
+		addFlow(step.CompensationName(), nextStates[idx])
+	}
+
+	definitions := &bpmnDefinitions{
+		Xmlns:           bpmnModelNamespace,
+		XmlnsCamunda:    camundaNamespace,
+		ID:              "Definitions_" + processID,
+		TargetNamespace: bpmnTargetNamespace,
+		Errors: []*bpmnError{
+			{ID: bpmnFailedErrorID, Name: failedStateName, ErrorCode: failedStateName},
+		},
+		Process: process,
+	}
+
+	document, err := xml.MarshalIndent(definitions, "", "  ")
+	if err != nil {
+		svc.logger.Log(err)
+		return nil, err
+	}
+	document = append([]byte(xml.Header), document...)
+
+	err = svc.ValidateBPMN(document)
+	if err != nil {
+		svc.logger.Log(err)
+		return nil, err
+	}
+
+	return document, nil
+}
+
+type bpmnElement struct {
+	Name          string
+	ID            string
+	Attributes    map[string]string
+	ParentElement string
+}
+
+// ValidateBPMN runs structural checks on the process, without validating it against the BPMN 2.0
+// XSD: the root must be the definitions of the BPMN model namespace, identifiers must be unique
+// NCNames, references must point to existing elements of the right kind, the process must
+// start with a single start event and every flow node must be reachable from it
+func (svc *DefaultHandler) ValidateBPMN(document []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+
+	elements := []*bpmnElement{}
+	ids := map[string]*bpmnElement{}
+	stack := []*bpmnElement{}
+	var root *bpmnElement
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch typedToken := token.(type) {
+		case xml.StartElement:
+			element := &bpmnElement{
+				Name:       typedToken.Name.Local,
+				Attributes: map[string]string{},
+			}
+			for _, attribute := range typedToken.Attr {
+				if attribute.Name.Space == "" {
+					element.Attributes[attribute.Name.Local] = attribute.Value
+				}
+			}
+			element.ID = element.Attributes["id"]
+
+			if len(stack) == 0 {
+				if typedToken.Name.Space != bpmnModelNamespace || element.Name != "definitions" {
+					return fmt.Errorf("root element must be definitions of namespace %s", bpmnModelNamespace)
+				}
+				if element.Attributes["targetNamespace"] == "" {
+					return fmt.Errorf("definitions must have a targetNamespace")
+				}
+				root = element
+			} else {
+				element.ParentElement = stack[len(stack)-1].Name
+			}
+
+			if typedToken.Name.Space == bpmnModelNamespace && element.ID != "" {
+				if !ncNamePattern.MatchString(element.ID) {
+					return fmt.Errorf("id %s of %s is not a valid NCName", element.ID, element.Name)
+				}
+				if _, exists := ids[element.ID]; exists {
+					return fmt.Errorf("id %s is duplicated", element.ID)
+				}
+				ids[element.ID] = element
+			}
+
+			if typedToken.Name.Space == bpmnModelNamespace {
+				elements = append(elements, element)
+			}
+			stack = append(stack, element)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	if root == nil {
+		return fmt.Errorf("document has no root element")
+	}
+
+	var processes int
+	startEvents := []string{}
+	outgoing := map[string][]string{}
+	incoming := map[string]int{}
+	attached := map[string][]string{}
+
+	for _, element := range elements {
+		isFlowNode := bpmnActivities[element.Name] || bpmnEvents[element.Name] || bpmnGateways[element.Name]
+
+		switch {
+		case element.Name == "process":
+			processes++
+			if element.ID == "" {
+				return fmt.Errorf("process must have an id")
+			}
+		case isFlowNode || element.Name == "sequenceFlow":
+			if element.ParentElement != "process" && element.ParentElement != "subProcess" {
+				return fmt.Errorf("%s %s must be inside a process", element.Name, element.ID)
+			}
+			if element.ID == "" {
+				return fmt.Errorf("%s must have an id", element.Name)
+			}
+		}
+
+		switch element.Name {
+		case "startEvent":
+			startEvents = append(startEvents, element.ID)
+		case "boundaryEvent":
+			activity, found := ids[element.Attributes["attachedToRef"]]
+			if !found || !bpmnActivities[activity.Name] {
+				return fmt.Errorf("boundary event %s must be attached to an activity", element.ID)
+			}
+			attached[activity.ID] = append(attached[activity.ID], element.ID)
+		case "sequenceFlow":
+			for _, reference := range []string{"sourceRef", "targetRef"} {
+				node, found := ids[element.Attributes[reference]]
+				if !found || !(bpmnActivities[node.Name] || bpmnEvents[node.Name] || bpmnGateways[node.Name]) {
+					return fmt.Errorf("%s of sequence flow %s must reference a flow node", reference, element.ID)
+				}
+			}
+			outgoing[element.Attributes["sourceRef"]] = append(outgoing[element.Attributes["sourceRef"]], element.Attributes["targetRef"])
+			incoming[element.Attributes["targetRef"]]++
+		case "errorEventDefinition":
+			errorRef := element.Attributes["errorRef"]
+			if errorRef != "" {
+				if definition, found := ids[errorRef]; !found || definition.Name != "error" {
+					return fmt.Errorf("errorRef %s must reference an error", errorRef)
+				}
+			}
+		}
+	}
+
+	if processes == 0 {
+		return fmt.Errorf("definitions must contain a process")
+	}
+
+	if len(startEvents) != 1 {
+		return fmt.Errorf("process must have exactly one start event, found %d", len(startEvents))
+	}
+
+	for _, element := range elements {
+		switch {
+		case element.Name == "startEvent" && incoming[element.ID] > 0:
+			return fmt.Errorf("start event %s cannot have incoming flows", element.ID)
+		case element.Name == "endEvent" && len(outgoing[element.ID]) > 0:
+			return fmt.Errorf("end event %s cannot have outgoing flows", element.ID)
+		case element.Name != "endEvent" && (bpmnActivities[element.Name] || bpmnEvents[element.Name] || bpmnGateways[element.Name]) && len(outgoing[element.ID]) == 0:
+			return fmt.Errorf("%s %s has no outgoing flow", element.Name, element.ID)
+		}
+	}
+
+	reachable := map[string]bool{}
+	pending := []string{startEvents[0]}
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		if reachable[id] {
+			continue
+		}
+		reachable[id] = true
+
+		pending = append(pending, outgoing[id]...)
+		pending = append(pending, attached[id]...)
+	}
+
+	for _, element := range elements {
+		isFlowNode := bpmnActivities[element.Name] || bpmnEvents[element.Name] || bpmnGateways[element.Name]
+		if isFlowNode && !reachable[element.ID] && element.ID != failedStateName {
+			return fmt.Errorf("%s %s is not reachable from the start event", element.Name, element.ID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package workflows
+
+import (
+	"automation/app/files"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	retryMaxAttempts     = 3
+	retryIntervalSeconds = 1
+	retryBackoffRate     = 2.0
+	failedStateName      = "SagaFailed"
+	succeededStateName   = "SagaSucceeded"
+)
+
+type WorkflowsHandler interface {
+	GenerateASL(*files.Controller, *files.FunctionalityRedesign, map[string]string) ([]byte, error)
+	GenerateBPMN(*files.Controller, *files.FunctionalityRedesign, map[string]string) ([]byte, error)
+	ValidateASL([]byte) error
+	ValidateBPMN([]byte) error
+}
+
+type DefaultHandler struct {
+	logger log.Logger
+}
+
+func New(logger log.Logger) WorkflowsHandler {
+	return &DefaultHandler{
+		logger: log.With(logger, "module", "workflowsHandler"),
+	}
+}
+
+// sagaStep is an invocation of the redesign that executes work in a participant
+type sagaStep struct {
+	Name          string
+	ClusterID     int
+	Description   string
+	Compensatable bool
+	Retriable     bool
+	Pivot         bool
+}
+
+func (s *sagaStep) CompensationName() string {
+	return "Compensate" + s.Name
+}
+
+func (s *sagaStep) Resource() string {
+	return fmt.Sprintf("cluster%d-%s", s.ClusterID, s.Name)
+}
+
+func buildSagaSteps(redesign *files.FunctionalityRedesign, idToEntityMap map[string]string) []*sagaStep {
+	steps := []*sagaStep{}
+	for idx, invocation := range redesign.Redesign {
+		if invocation.ClusterID == -1 || len(invocation.ClusterAccesses) == 0 {
+			continue
+		}
+
+		accesses := []string{}
+		for accessIdx := range invocation.ClusterAccesses {
+			entityID := strconv.Itoa(invocation.GetAccessEntityID(accessIdx))
+			entityName, found := idToEntityMap[entityID]
+			if !found {
+				entityName = entityID
+			}
+			accesses = append(accesses, fmt.Sprintf("%s (%s)", entityName, invocation.GetAccessType(accessIdx)))
+		}
+
+		stepType := redesign.GetStepType(idx)
+		steps = append(steps, &sagaStep{
+			Name:          fmt.Sprintf("Step%dCluster%d", len(steps), invocation.ClusterID),
+			ClusterID:     invocation.ClusterID,
+			Description:   fmt.Sprintf("%s: %s", stepType, strings.Join(accesses, ", ")),
+			Compensatable: stepType == "COMPENSATABLE",
+			Retriable:     stepType == "RETRIABLE",
+			Pivot:         stepType == "PIVOT",
+		})
+	}
+	return steps
+}
+
+// rollbackTargets returns, for each step, the name of the compensation to execute when the step
+// fails, which is the one of the last compensatable step completed before it. Once the pivot is
+// completed the saga can no longer be rolled back, so failures go straight to the failed state.
+func rollbackTargets(steps []*sagaStep) []string {
+	targets := []string{}
+	lastCompensation := failedStateName
+	for _, step := range steps {
+		targets = append(targets, lastCompensation)
+
+		if step.Compensatable {
+			lastCompensation = step.CompensationName()
+		}
+		if step.Pivot {
+			lastCompensation = failedStateName
+		}
+	}
+	return targets
+}
+
+// compensationChain returns the compensatable steps in the order they are compensated, together
+// with the state that follows each compensation
+func compensationChain(steps []*sagaStep) ([]*sagaStep, []string) {
+	compensatableSteps := []*sagaStep{}
+	for _, step := range steps {
+		if step.Pivot {
+			break
+		}
+		if step.Compensatable {
+			compensatableSteps = append([]*sagaStep{step}, compensatableSteps...)
+		}
+	}
+
+	nextStates := []string{}
+	for idx := range compensatableSteps {
+		if idx == len(compensatableSteps)-1 {
+			nextStates = append(nextStates, failedStateName)
+			continue
+		}
+		nextStates = append(nextStates, compensatableSteps[idx+1].CompensationName())
+	}
+
+	return compensatableSteps, nextStates
+}
@@ -0,0 +1,175 @@
+package workflows_test
+
+import (
+	"automation/app/common/fixtures"
+	"automation/app/common/log"
+	"automation/app/files"
+	"automation/app/workflows"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestValidateRejectsInvalidWorkflows(t *testing.T) {
+	handler := workflows.New(log.NewNopLogger())
+
+	document := `{"StartAt": "First", "States": {
+		"First": {"Type": "Task", "Resource": "arn", "Next": "Missing"}
+	}}`
+	assert.Error(t, handler.ValidateASL([]byte(document)))
+
+	document = `{"StartAt": "First", "States": {
+		"First": {"Type": "Task", "Resource": "arn", "End": true},
+		"Orphan": {"Type": "Succeed"}
+	}}`
+	assert.Error(t, handler.ValidateASL([]byte(document)))
+
+	assert.Error(t, handler.ValidateBPMN([]byte(`<definitions targetNamespace="x"></definitions>`)))
+}
+
+// validateASLSchema validates the state machine against the vendored Amazon States Language
+// JSON schema
+func validateASLSchema(t *testing.T, document []byte) error {
+	schema, err := ioutil.ReadFile(filepath.Join("testdata", "asl.schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schema), gojsonschema.NewBytesLoader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid() {
+		return fmt.Errorf("%v", result.Errors())
+	}
+	return nil
+}
+
+// validateBPMNSchema validates the process against the vendored BPMN 2.0 XSD with xmllint, as
+// there is no XSD validator in the standard library
+func validateBPMNSchema(t *testing.T, document []byte) error {
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint is needed to validate against the BPMN 2.0 XSD")
+	}
+
+	file, err := ioutil.TempFile("", "saga-*.bpmn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(document)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command(xmllint, "--noout", "--schema", filepath.Join("testdata", "bpmn20.xsd"), file.Name()).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s", output)
+	}
+	return nil
+}
+
+func TestGenerateWorkflows(t *testing.T) {
+	handler := workflows.New(log.NewNopLogger())
+
+	controller := &files.Controller{Name: "BookingController.bookTrip", Type: "SAGA"}
+	redesign := fixtures.Trace(controller.Name,
+		fixtures.Invocation(0, []interface{}{"W", 1}),
+		fixtures.Invocation(1, []interface{}{"RW", 2}),
+		fixtures.Invocation(2, []interface{}{"W", 3}),
+		fixtures.Invocation(1, []interface{}{"R", 2}),
+	)
+	idToEntityMap := map[string]string{"1": "Traveler", "2": "Booking", "3": "Flight"}
+
+	// replacement is a change of the generated document that makes it invalid
+	type replacement struct {
+		old string
+		new string
+	}
+
+	cases := []struct {
+		format         string
+		generate       func(*files.Controller, *files.FunctionalityRedesign, map[string]string) ([]byte, error)
+		validate       func([]byte) error
+		validateSchema func(*testing.T, []byte) error
+		check          func(*testing.T, []byte)
+		// rejected by the validator of the handler
+		invalid []replacement
+		// rejected by the schema of the format
+		schemaViolation replacement
+	}{
+		{
+			"ASL",
+			handler.GenerateASL,
+			handler.ValidateASL,
+			validateASLSchema,
+			func(t *testing.T, document []byte) {
+				var stateMachine workflows.StateMachine
+				assert.NoError(t, json.Unmarshal(document, &stateMachine))
+
+				assert.Equal(t, "Step0Cluster0", stateMachine.StartAt)
+				assert.Equal(t, "CompensateStep0Cluster0", stateMachine.States["Step1Cluster1"].Catch[0].Next)
+				assert.Equal(t, "CompensateStep1Cluster1", stateMachine.States["Step2Cluster2"].Catch[0].Next)
+				assert.Equal(t, "CompensateStep0Cluster0", stateMachine.States["CompensateStep1Cluster1"].Next)
+				assert.Equal(t, "SagaFailed", stateMachine.States["Step3Cluster1"].Catch[0].Next)
+				assert.Len(t, stateMachine.States["Step3Cluster1"].Retry, 1)
+				assert.NotContains(t, stateMachine.States, "CompensateStep2Cluster2")
+			},
+			[]replacement{
+				{`"Next": "SagaSucceeded"`, `"Next": "Missing"`},
+			},
+			// a state cannot both transition and end the execution
+			replacement{`"Next": "SagaSucceeded"`, `"Next": "SagaSucceeded", "End": true`},
+		},
+		{
+			"BPMN",
+			handler.GenerateBPMN,
+			handler.ValidateBPMN,
+			validateBPMNSchema,
+			func(t *testing.T, document []byte) {
+				process := string(document)
+				assert.Contains(t, process, `<process id="Saga_BookingController.bookTrip"`)
+				assert.Contains(t, process, `<boundaryEvent id="Step2Cluster2Failed" attachedToRef="Step2Cluster2">`)
+				assert.Contains(t, process, `sourceRef="Step2Cluster2Failed" targetRef="CompensateStep1Cluster1"`)
+				assert.Contains(t, process, `sourceRef="CompensateStep1Cluster1" targetRef="CompensateStep0Cluster0"`)
+				assert.Contains(t, process, `<camunda:failedJobRetryTimeCycle>R3/PT1S</camunda:failedJobRetryTimeCycle>`)
+			},
+			[]replacement{
+				{`targetRef="SagaSucceeded"`, `targetRef="Missing"`},
+				{`id="Step1Cluster1Failed"`, `id="Step0Cluster0Failed"`},
+			},
+			// the extension elements of a task come before its other content
+			replacement{`<extensionElements>`, `<errorEventDefinition></errorEventDefinition><extensionElements>`},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.format, func(t *testing.T) {
+			document, err := c.generate(controller, redesign, idToEntityMap)
+			assert.NoError(t, err)
+			c.check(t, document)
+
+			assert.NoError(t, c.validate(document))
+			for _, invalid := range c.invalid {
+				assert.Error(t, c.validate([]byte(strings.Replace(string(document), invalid.old, invalid.new, 1))), invalid.new)
+			}
+
+			// the schema is validated last, as the BPMN schema skips the rest without xmllint
+			assert.NoError(t, c.validateSchema(t, document))
+			violation := strings.Replace(string(document), c.schemaViolation.old, c.schemaViolation.new, 1)
+			assert.Error(t, c.validateSchema(t, []byte(violation)))
+		})
+	}
+}
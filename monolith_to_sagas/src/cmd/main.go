@@ -1,14 +1,24 @@
 package main
 
 import (
+	"automation/app/assignment"
+	"automation/app/asyncapi"
+	"automation/app/codegen"
 	"automation/app/common/log"
 	"automation/app/configuration"
+	"automation/app/diagrams"
 	"automation/app/files"
+	"automation/app/graphs"
 	"automation/app/metrics"
 	"automation/app/redesign"
+	"automation/app/replication"
+	"automation/app/simulation"
+	"automation/app/testplans"
 	"automation/app/training"
+	"automation/app/workflows"
 	"fmt"
 	"runtime"
+	"sort"
 	"time"
 )
 
@@ -25,24 +35,94 @@ func main() {
 			OnlyJoaoControllers:                   true,
 			GenerateComplexitiesCSV:               false,
 			GenerateMetricsCSV:                    false,
+			GenerateCouplingCSV:                   false,
 			Executions:                            1,
 			MinimizeSumBothComplexities:           false,
 			DataDependenceThreshold:               0,
 			ExcludeLowDistanceRedesigns:           false,
 			AcceptableComplexityDistanceThreshold: 0,
 			OnlyExportBestRedesign:                false,
-			PrintTraces:                           false,
-			PrintSpecificFunctionality:            "",
+			CompareChoreographies:                 false,
+			DetectParallelSteps:                   false,
+			ReorderInvocations:                    false,
+			AuditMerges:                           false,
+			SweepDecompositions:                   false,
+			CompareExpertDecompositions:           false,
+			RedesignQueries:                       false,
+			CalculatePerformance:                  false,
+			MinimizeLatency:                       false,
+			CostModel: configuration.CostModel{
+				RemoteInvocationLatency: 10,
+				LocalAccessCost:         1,
+				MessageHeaderSize:       256,
+				EntityAccessSize:        512,
+			},
+			SimulateFailures: false,
+			FailureModel: configuration.FailureModel{
+				RemoteStepFailureProbability: 0.05,
+				LocalStepFailureProbability:  0.01,
+				MaxRetries:                   3,
+				Runs:                         1000,
+				Seed:                         1,
+			},
+			SimulateContention: false,
+			ContentionModel: configuration.ContentionModel{
+				ArrivalRate:  5,
+				ArrivalRates: map[string]float32{},
+				LockTimeout:  500,
+				Duration:     600000,
+				Seed:         1,
+			},
+			Metrics:                     []string{},
+			RankingMetrics:              []string{},
+			AssignOrchestratorsGlobally: false,
+			MaxOrchestrationsPerCluster: 0,
+			SuggestReplicas:             false,
+			ReplicaMaxWriteRatio:        0.25,
+			GenerateSequenceDiagrams:    false,
+			GenerateGraphs:              false,
+			GenerateDependencyGraphs:    false,
+			GenerateWorkflows:           false,
+			GenerateAsyncAPI:            false,
+			GenerateTestPlans:           false,
+			CodeTemplates:               []string{},
+			CodeTemplatesFolder:         "",
+			PrintTraces:                 false,
+			PrintSpecificFunctionality:  "",
 		},
 	}
 	execution.Configuration.GenerateDefaultCodebaseConfiguration()
 
 	logger := log.NewLogger()
 	filesHandler := files.New(logger)
+	diagramsHandler := diagrams.New(logger)
+	graphsHandler := graphs.New(logger)
+	codegenHandler := codegen.New(logger)
+	workflowsHandler := workflows.New(logger)
+	asyncAPIHandler := asyncapi.New(logger)
+	simulationHandler := simulation.New(logger)
+	testPlansHandler := testplans.New(logger)
+	metricsHandler := metrics.New(logger)
+	replicationHandler := replication.New(logger, metricsHandler)
+	assignmentHandler := assignment.New(logger)
+
+	if execution.Configuration.CodeTemplatesFolder != "" {
+		err := codegenHandler.LoadTemplates(execution.Configuration.CodeTemplatesFolder)
+		if err != nil {
+			logger.Log("Failed to load code templates %s | %s", execution.Configuration.CodeTemplatesFolder, err.Error())
+		}
+	}
+	err := metrics.ValidateMetrics(execution.Configuration.MetricsToCalculate(), execution.Configuration.ShouldCalculatePerformance())
+	if err != nil {
+		logger.Log("Invalid metrics configuration | %s", err.Error())
+		return
+	}
+
 	redesignHandler := redesign.New(
 		logger,
-		metrics.New(logger),
+		metricsHandler,
 		training.New(logger),
+		simulationHandler,
 		execution,
 	)
 
@@ -85,6 +165,81 @@ func main() {
 				}
 			}
 
+			if execution.Configuration.GenerateCouplingCSV {
+				for _, row := range datasets.CouplingDataset {
+					results.Datasets.CouplingDataset = append(results.Datasets.CouplingDataset, row)
+				}
+			}
+
+			if execution.Configuration.RedesignQueries {
+				for _, row := range datasets.QueriesDataset {
+					results.Datasets.QueriesDataset = append(results.Datasets.QueriesDataset, row)
+				}
+			}
+
+			if execution.Configuration.SweepDecompositions {
+				for _, row := range datasets.SweepDataset {
+					results.Datasets.SweepDataset = append(results.Datasets.SweepDataset, row)
+				}
+			}
+
+			if execution.Configuration.CompareExpertDecompositions {
+				for _, row := range datasets.ExpertDataset {
+					results.Datasets.ExpertDataset = append(results.Datasets.ExpertDataset, row)
+				}
+			}
+
+			if execution.Configuration.DetectParallelSteps {
+				generateSagaDAGsFile(codebase.Name, datasets, filesHandler)
+			}
+
+			if execution.Configuration.GenerateSequenceDiagrams {
+				generateSequenceDiagramFiles(datasets, idToEntityMap, diagramsHandler, filesHandler)
+			}
+
+			if execution.Configuration.GenerateGraphs {
+				generateGraphFiles(codebase, datasets, graphsHandler, filesHandler)
+			}
+
+			if execution.Configuration.GenerateDependencyGraphs {
+				generateDependencyGraphFiles(codebase.Name, datasets, idToEntityMap, graphsHandler, filesHandler)
+			}
+
+			if execution.Configuration.AuditMerges {
+				generateMergeAuditFile(codebase.Name, datasets, filesHandler)
+			}
+
+			if execution.Configuration.GenerateWorkflows {
+				generateWorkflowFiles(datasets, idToEntityMap, workflowsHandler, filesHandler)
+			}
+
+			if execution.Configuration.GenerateAsyncAPI {
+				outputFileName := fmt.Sprintf("%s-asyncapi.json", codebase.Name)
+				fmt.Printf("\nGenerating AsyncAPI document: %v\n", outputFileName)
+				document := asyncAPIHandler.GenerateDocument(codebase.Name, datasets.Functionalities, idToEntityMap)
+				filesHandler.GenerateJSON(outputFileName, document)
+			}
+
+			if execution.Configuration.GenerateTestPlans {
+				generateTestPlansFiles(codebase.Name, datasets, idToEntityMap, testPlansHandler, filesHandler)
+			}
+
+			if execution.Configuration.SimulateContention {
+				generateContentionFiles(execution, codebase, datasets, idToEntityMap, simulationHandler, filesHandler)
+			}
+
+			if execution.Configuration.AssignOrchestratorsGlobally {
+				generateAssignmentFiles(execution, codebase, datasets, assignmentHandler, filesHandler)
+			}
+
+			if execution.Configuration.SuggestReplicas {
+				generateReplicationFiles(execution, codebase, datasets, idToEntityMap, replicationHandler, filesHandler)
+			}
+
+			if len(execution.Configuration.CodeTemplates) > 0 {
+				generateOrchestratorFiles(execution, datasets, idToEntityMap, codegenHandler, filesHandler)
+			}
+
 			fmt.Printf("Finished estimation for codebase %v\n", codebase.Name)
 		}
 
@@ -151,4 +306,317 @@ func generateCSVFiles(
 		fmt.Printf("\nGenerating metrics .csv: %v\n", outputFileName)
 		filesHandler.GenerateCSV(outputFileName, result.Datasets.MetricsDataset)
 	}
+
+	if execution.Configuration.GenerateCouplingCSV {
+		t := time.Now()
+		outputFileName := fmt.Sprintf("%s-coupling-%s.csv", identifier, t.Format("2006-01-02-15-04-05"))
+		fmt.Printf("\nGenerating coupling .csv: %v\n", outputFileName)
+		filesHandler.GenerateCSV(outputFileName, result.Datasets.CouplingDataset)
+	}
+
+	if execution.Configuration.RedesignQueries {
+		t := time.Now()
+		outputFileName := fmt.Sprintf("%s-queries-%s.csv", identifier, t.Format("2006-01-02-15-04-05"))
+		fmt.Printf("\nGenerating queries .csv: %v\n", outputFileName)
+		filesHandler.GenerateCSV(outputFileName, result.Datasets.QueriesDataset)
+	}
+
+	if execution.Configuration.SweepDecompositions {
+		t := time.Now()
+		outputFileName := fmt.Sprintf("%s-sweep-%s.csv", identifier, t.Format("2006-01-02-15-04-05"))
+		fmt.Printf("\nGenerating sweep .csv: %v\n", outputFileName)
+		filesHandler.GenerateCSV(outputFileName, result.Datasets.SweepDataset)
+	}
+
+	if execution.Configuration.CompareExpertDecompositions {
+		t := time.Now()
+		outputFileName := fmt.Sprintf("%s-expert-%s.csv", identifier, t.Format("2006-01-02-15-04-05"))
+		fmt.Printf("\nGenerating expert comparison .csv: %v\n", outputFileName)
+		filesHandler.GenerateCSV(outputFileName, result.Datasets.ExpertDataset)
+	}
+}
+
+func generateSagaDAGsFile(codebaseName string, datasets *configuration.Datasets, filesHandler files.FilesHandler) {
+	dags := map[string]*files.SagaDAG{}
+	for _, functionality := range datasets.Functionalities {
+		bestRedesign := functionality.GetBestRedesign()
+		if bestRedesign == nil {
+			continue
+		}
+		dags[functionalityKey(functionality)] = bestRedesign.DAG
+	}
+
+	t := time.Now()
+	outputFileName := fmt.Sprintf("%s-saga-dags-%s.json", codebaseName, t.Format("2006-01-02-15-04-05"))
+	fmt.Printf("\nGenerating saga DAGs .json: %v\n", outputFileName)
+	filesHandler.GenerateJSON(outputFileName, dags)
+}
+
+func generateSequenceDiagramFiles(
+	datasets *configuration.Datasets, idToEntityMap map[string]string, diagramsHandler diagrams.DiagramsHandler, filesHandler files.FilesHandler,
+) {
+	for _, functionality := range datasets.Functionalities {
+		bestRedesign := functionality.GetBestRedesign()
+		if bestRedesign == nil {
+			continue
+		}
+
+		outputFileName := fmt.Sprintf("%s-sequence", functionalityOutputName(functionality))
+		fmt.Printf("\nGenerating sequence diagrams: %v\n", outputFileName)
+
+		mermaid := diagramsHandler.GenerateMermaidDiagrams(functionality.Controller, functionality.InitialRedesign, bestRedesign, idToEntityMap)
+		filesHandler.GenerateTextFile(outputFileName+".md", mermaid)
+
+		plantUML := diagramsHandler.GeneratePlantUMLDiagrams(functionality.Controller, functionality.InitialRedesign, bestRedesign, idToEntityMap)
+		filesHandler.GenerateTextFile(outputFileName+".puml", plantUML)
+	}
+}
+
+// functionalityOutputName identifies the functionality in the names of the exported files, with
+// its dendrogram and decomposition since the same controller is redesigned in each decomposition
+func functionalityOutputName(functionality *configuration.FunctionalityResult) string {
+	return fmt.Sprintf("%s-%s", functionality.Codebase, functionalityKey(functionality))
+}
+
+// functionalityKey identifies the functionality among the functionalities of every decomposition
+// of the codebase exported in the same file
+func functionalityKey(functionality *configuration.FunctionalityResult) string {
+	return fmt.Sprintf("%s-%s-%s", functionality.Decomposition.DendogramName, functionality.Decomposition.Name, functionality.Controller.Name)
+}
+
+// getRedesignedDecompositions returns the decompositions of the codebase whose functionalities
+// were redesigned
+func getRedesignedDecompositions(datasets *configuration.Datasets) []*files.Decomposition {
+	decompositions := []*files.Decomposition{}
+	for _, functionality := range datasets.Functionalities {
+		var found bool
+		for _, decomposition := range decompositions {
+			if decomposition == functionality.Decomposition {
+				found = true
+			}
+		}
+
+		if !found {
+			decompositions = append(decompositions, functionality.Decomposition)
+		}
+	}
+	return decompositions
+}
+
+func generateGraphFiles(codebase *files.Codebase, datasets *configuration.Datasets, graphsHandler graphs.GraphsHandler, filesHandler files.FilesHandler) {
+	for _, decomposition := range getRedesignedDecompositions(datasets) {
+		outputFileName := fmt.Sprintf("%s-%s-%s", codebase.Name, decomposition.DendogramName, decomposition.Name)
+		fmt.Printf("\nGenerating graphs: %v\n", outputFileName)
+
+		graphs := map[string]string{
+			"coupling":      graphsHandler.GenerateCouplingGraph(decomposition),
+			"orchestrators": graphsHandler.GenerateOrchestrationGraph(decomposition, datasets.Functionalities),
+		}
+
+		for graphName, graph := range graphs {
+			filesHandler.GenerateTextFile(fmt.Sprintf("%s-%s.dot", outputFileName, graphName), graph)
+
+			svg, err := graphsHandler.RenderSVG(graph)
+			if err == nil {
+				filesHandler.GenerateTextFile(fmt.Sprintf("%s-%s.svg", outputFileName, graphName), svg)
+			}
+		}
+	}
+}
+
+// generateDependencyGraphFiles exports the data dependencies between the invocations of the
+// monolith trace of each functionality, all of them in a JSON file and each one in a DOT graph
+func generateDependencyGraphFiles(
+	codebaseName string, datasets *configuration.Datasets, idToEntityMap map[string]string, graphsHandler graphs.GraphsHandler, filesHandler files.FilesHandler,
+) {
+	dependencyGraphs := map[string]*files.DependencyGraph{}
+	for _, functionality := range datasets.Functionalities {
+		if functionality.InitialRedesign == nil {
+			continue
+		}
+
+		dependencyGraph := files.BuildDependencyGraph(functionality.InitialRedesign.Redesign)
+		dependencyGraphs[functionalityKey(functionality)] = dependencyGraph
+
+		outputFileName := fmt.Sprintf("%s-dependencies.dot", functionalityOutputName(functionality))
+		graph := graphsHandler.GenerateDependencyGraph(functionality.Controller.Name, functionality.InitialRedesign, dependencyGraph, idToEntityMap)
+		filesHandler.GenerateTextFile(outputFileName, graph)
+	}
+
+	t := time.Now()
+	outputFileName := fmt.Sprintf("%s-dependency-graphs-%s.json", codebaseName, t.Format("2006-01-02-15-04-05"))
+	fmt.Printf("\nGenerating dependency graphs .json: %v\n", outputFileName)
+	filesHandler.GenerateJSON(outputFileName, dependencyGraphs)
+}
+
+// mergeAuditEntry is a merge decision of a saga redesign, identified so each line of the audit can
+// be replayed on its own
+type mergeAuditEntry struct {
+	Codebase      string `json:"codebase"`
+	Dendrogram    string `json:"dendrogram"`
+	Decomposition string `json:"decomposition"`
+	Feature       string `json:"feature"`
+	Orchestrator  int    `json:"orchestrator"`
+	*files.MergeDecision
+}
+
+func generateMergeAuditFile(codebaseName string, datasets *configuration.Datasets, filesHandler files.FilesHandler) {
+	entries := []interface{}{}
+	for _, functionality := range datasets.Functionalities {
+		for _, redesign := range functionality.SagaRedesigns {
+			for _, decision := range redesign.MergeDecisions {
+				entries = append(entries, &mergeAuditEntry{
+					Codebase:      functionality.Codebase,
+					Dendrogram:    functionality.Decomposition.DendogramName,
+					Decomposition: functionality.Decomposition.Name,
+					Feature:       functionality.Controller.Name,
+					Orchestrator:  redesign.OrchestratorID,
+					MergeDecision: decision,
+				})
+			}
+		}
+	}
+
+	t := time.Now()
+	outputFileName := fmt.Sprintf("%s-merge-decisions-%s.jsonl", codebaseName, t.Format("2006-01-02-15-04-05"))
+	fmt.Printf("\nGenerating merge decisions .jsonl: %v\n", outputFileName)
+	filesHandler.GenerateJSONLines(outputFileName, entries)
+}
+
+func generateWorkflowFiles(
+	datasets *configuration.Datasets, idToEntityMap map[string]string, workflowsHandler workflows.WorkflowsHandler, filesHandler files.FilesHandler,
+) {
+	for _, functionality := range datasets.Functionalities {
+		bestRedesign := functionality.GetBestRedesign()
+		if bestRedesign == nil {
+			continue
+		}
+
+		outputFileName := functionalityOutputName(functionality)
+		fmt.Printf("\nGenerating workflows: %v\n", outputFileName)
+
+		asl, err := workflowsHandler.GenerateASL(functionality.Controller, bestRedesign, idToEntityMap)
+		if err != nil {
+			fmt.Printf("\nFailed to generate state machine of %s: %s\n", functionality.Controller.Name, err.Error())
+		} else {
+			filesHandler.GenerateTextFile(outputFileName+".asl.json", string(asl))
+		}
+
+		bpmn, err := workflowsHandler.GenerateBPMN(functionality.Controller, bestRedesign, idToEntityMap)
+		if err != nil {
+			fmt.Printf("\nFailed to generate BPMN process of %s: %s\n", functionality.Controller.Name, err.Error())
+		} else {
+			filesHandler.GenerateTextFile(outputFileName+".bpmn", string(bpmn))
+		}
+	}
+}
+
+// generateTestPlansFiles exports the test plans of the best redesigns of the functionalities, in
+// a file per decomposition
+func generateTestPlansFiles(
+	codebaseName string, datasets *configuration.Datasets, idToEntityMap map[string]string,
+	testPlansHandler testplans.TestPlansHandler, filesHandler files.FilesHandler,
+) {
+	for _, decomposition := range getRedesignedDecompositions(datasets) {
+		plans := []*testplans.TestPlan{}
+		for _, functionality := range datasets.Functionalities {
+			bestRedesign := functionality.GetBestRedesign()
+			if functionality.Decomposition != decomposition || bestRedesign == nil {
+				continue
+			}
+			plans = append(plans, testPlansHandler.GenerateTestPlan(functionality.Controller, bestRedesign, idToEntityMap))
+		}
+
+		sort.Slice(plans, func(i, j int) bool {
+			return plans[i].Functionality < plans[j].Functionality
+		})
+
+		outputFileName := fmt.Sprintf("%s-%s-%s-test-plans.json", codebaseName, decomposition.DendogramName, decomposition.Name)
+		fmt.Printf("\nGenerating failure scenario test plans: %v\n", outputFileName)
+		filesHandler.GenerateJSON(outputFileName, plans)
+	}
+}
+
+func generateContentionFiles(
+	execution configuration.Execution, codebase *files.Codebase, datasets *configuration.Datasets, idToEntityMap map[string]string,
+	simulationHandler simulation.SimulationHandler, filesHandler files.FilesHandler,
+) {
+	for _, decomposition := range getRedesignedDecompositions(datasets) {
+		report := simulationHandler.SimulateContention(
+			decomposition, datasets.Functionalities, execution.Configuration.ContentionModel, execution.Configuration.CostModel,
+		)
+
+		outputFileName := fmt.Sprintf("%s-%s-%s-contention", codebase.Name, decomposition.DendogramName, decomposition.Name)
+		fmt.Printf("\nGenerating contention simulation: %v\n", outputFileName)
+		fmt.Printf("Correlation between system complexity and lock wait time: %f\n", report.SystemComplexityWaitCorrelation)
+
+		filesHandler.GenerateCSV(outputFileName+"-functionalities.csv", report.FunctionalitiesDataset())
+		filesHandler.GenerateCSV(outputFileName+"-entities.csv", report.EntitiesDataset(idToEntityMap))
+	}
+}
+
+func generateAssignmentFiles(
+	execution configuration.Execution, codebase *files.Codebase, datasets *configuration.Datasets,
+	assignmentHandler assignment.AssignmentHandler, filesHandler files.FilesHandler,
+) {
+	for _, decomposition := range getRedesignedDecompositions(datasets) {
+		report, err := assignmentHandler.AssignOrchestrators(decomposition, datasets.Functionalities, execution.Configuration.MaxOrchestrationsPerCluster)
+		if err != nil {
+			fmt.Printf("\nSkipping orchestrator assignment: %s\n", err.Error())
+			continue
+		}
+
+		outputFileName := fmt.Sprintf("%s-%s-%s-assignment.csv", codebase.Name, decomposition.DendogramName, decomposition.Name)
+		fmt.Printf("\nGenerating orchestrator assignment: %v\n", outputFileName)
+		fmt.Printf("Total complexity: %v, chosen independently: %v\n", report.TotalComplexity, report.IndependentTotalComplexity)
+		filesHandler.GenerateCSV(outputFileName, report.Dataset())
+	}
+}
+
+func generateReplicationFiles(
+	execution configuration.Execution, codebase *files.Codebase, datasets *configuration.Datasets, idToEntityMap map[string]string,
+	replicationHandler replication.ReplicationHandler, filesHandler files.FilesHandler,
+) {
+	for _, decomposition := range getRedesignedDecompositions(datasets) {
+		report := replicationHandler.SuggestReplicas(decomposition, execution.Configuration.ReplicaMaxWriteRatio)
+
+		outputFileName := fmt.Sprintf("%s-%s-%s-replicas.csv", codebase.Name, decomposition.DendogramName, decomposition.Name)
+		fmt.Printf("\nGenerating replication suggestions: %v\n", outputFileName)
+		filesHandler.GenerateCSV(outputFileName, report.Dataset(idToEntityMap))
+	}
+}
+
+// generateOrchestratorFiles renders each template for the best redesign of every functionality,
+// in a folder per decomposition and template so that its sagas can share the same package
+func generateOrchestratorFiles(
+	execution configuration.Execution, datasets *configuration.Datasets, idToEntityMap map[string]string,
+	codegenHandler codegen.CodegenHandler, filesHandler files.FilesHandler,
+) {
+	for _, templateName := range execution.Configuration.CodeTemplates {
+		extension, err := codegenHandler.GetTemplateExtension(templateName)
+		if err != nil {
+			fmt.Printf("\nSkipping code generation: %s\n", err.Error())
+			continue
+		}
+
+		for _, functionality := range datasets.Functionalities {
+			bestRedesign := functionality.GetBestRedesign()
+			if bestRedesign == nil {
+				continue
+			}
+
+			code, err := codegenHandler.GenerateOrchestrator(templateName, functionality.Controller, bestRedesign, idToEntityMap)
+			if err != nil {
+				fmt.Printf("\nFailed to generate %s orchestrator of %s: %s\n", templateName, functionality.Controller.Name, err.Error())
+				continue
+			}
+
+			outputFileName := fmt.Sprintf(
+				"%s-%s-%s-%s/%s", functionality.Codebase, functionality.Decomposition.DendogramName, functionality.Decomposition.Name,
+				templateName, codegen.SagaFileName(functionality.Controller.Name, extension),
+			)
+			fmt.Printf("\nGenerating orchestrator skeleton: %v\n", outputFileName)
+			filesHandler.GenerateTextFile(outputFileName, code)
+		}
+	}
 }
@@ -0,0 +1,133 @@
+// Command optimize searches for a decomposition whose functionalities have simpler sagas, by
+// moving entities between clusters and merging clusters of the given decomposition. The optimized
+// decomposition is written in the codebase.json format, so it can be read like any other codebase,
+// together with the diff to the original one. The coupling of the decomposition and its clusters
+// is calculated again from the monolith traces, while the complexity and cohesion kept are the
+// ones of the original decomposition. Like the main command it is run from the cmd folder, since
+// the codebases are read relative to it:
+//
+//	go run ./optimize -codebase ldod-static -expert -iterations 500 -min-clusters 3
+package main
+
+import (
+	"automation/app/common/log"
+	"automation/app/configuration"
+	"automation/app/files"
+	"automation/app/metrics"
+	"automation/app/optimizer"
+	"automation/app/redesign"
+	"automation/app/simulation"
+	"automation/app/training"
+	"flag"
+	"fmt"
+	"os"
+)
+
+type optimizationSummary struct {
+	Codebase      string                       `json:"codebase"`
+	Dendrogram    string                       `json:"dendrogram"`
+	Decomposition string                       `json:"decomposition"`
+	InitialCost   int                          `json:"initialCost"`
+	FinalCost     int                          `json:"finalCost"`
+	Iterations    int                          `json:"iterations"`
+	AcceptedMoves int                          `json:"acceptedMoves"`
+	Diff          *optimizer.DecompositionDiff `json:"diff"`
+}
+
+func main() {
+	codebaseName := flag.String("codebase", "ldod-static", "name of the codebase folder")
+	cutValue := flag.Float64("cut", 0, "cut value of the decomposition")
+	useExpert := flag.Bool("expert", false, "use the expert decomposition")
+	dendrogramName := flag.String("dendrogram", "", "name of the dendrogram, the first one by default")
+	iterations := flag.Int("iterations", 200, "number of moves tried")
+	initialTemperature := flag.Float64("temperature", 10, "initial temperature of the annealing, 0 for hill climbing")
+	coolingRate := flag.Float64("cooling", 0.95, "rate the temperature is multiplied by after each move")
+	mergeProbability := flag.Float64("merge-probability", 0.1, "probability of trying to merge two clusters instead of moving an entity")
+	minClusters := flag.Int("min-clusters", 2, "minimum number of clusters of the decomposition")
+	maxClusterSize := flag.Int("max-cluster-size", 0, "maximum number of entities of a cluster, 0 for no limit")
+	seed := flag.Int64("seed", 0, "seed of the random moves")
+	output := flag.String("output", "", "prefix of the generated files, the codebase name by default")
+	flag.Parse()
+
+	if *output == "" {
+		*output = *codebaseName
+	}
+
+	logger := log.NewLogger()
+	filesHandler := files.New(logger)
+	execution := configuration.Execution{Configuration: &configuration.Configuration{MinimizeSumBothComplexities: true}}
+	redesignHandler := redesign.New(logger, metrics.New(logger), training.New(logger), simulation.New(logger), execution)
+	optimizerHandler := optimizer.New(logger, redesignHandler)
+
+	codebase, err := filesHandler.ReadCodebase(*codebaseName)
+	if err != nil {
+		logger.Log("Failed to decode codebase %s | %s", *codebaseName, err.Error())
+		os.Exit(1)
+	}
+
+	idToEntityMap, err := filesHandler.ReadIDToEntityFile(*codebaseName)
+	if err != nil {
+		logger.Log("Failed to decode id_to_entity map %s | %s", *codebaseName, err.Error())
+		os.Exit(1)
+	}
+
+	dendrogram, decomposition := getDecomposition(codebase, *dendrogramName, float32(*cutValue), *useExpert)
+	if decomposition == nil {
+		logger.Log("Failed to get decomposition from codebase %s", *codebaseName)
+		os.Exit(1)
+	}
+
+	result, err := optimizerHandler.Optimize(decomposition, configuration.OptimizationModel{
+		Iterations:         *iterations,
+		InitialTemperature: *initialTemperature,
+		CoolingRate:        *coolingRate,
+		MergeProbability:   *mergeProbability,
+		MinClusters:        *minClusters,
+		MaxClusterSize:     *maxClusterSize,
+		Seed:               *seed,
+	})
+	if err != nil {
+		logger.Log("Failed to optimize decomposition %s | %s", decomposition.Name, err.Error())
+		os.Exit(1)
+	}
+	result.Diff.NameEntities(idToEntityMap)
+
+	fmt.Printf("\nOptimized %v from cost %v to %v in %v iterations\n", decomposition.Name, result.InitialCost, result.FinalCost, result.Iterations)
+	for _, move := range result.Diff.MovedEntities {
+		fmt.Printf("\t%v: cluster %v -> %v\n", move.Entity, move.FromCluster, move.ToCluster)
+	}
+	for _, clusterName := range result.Diff.RemovedClusters {
+		fmt.Printf("\tcluster %v removed\n", clusterName)
+	}
+
+	filesHandler.GenerateJSON(fmt.Sprintf("%s-optimized-codebase.json", *output), &files.Codebase{
+		Name:     codebase.Name,
+		Profiles: codebase.Profiles,
+		Dendrograms: []*files.Dendogram{{
+			Name:           dendrogram.Name,
+			CodebaseName:   dendrogram.CodebaseName,
+			Decompositions: []*files.Decomposition{result.Decomposition},
+		}},
+	})
+
+	filesHandler.GenerateJSON(fmt.Sprintf("%s-optimized-diff.json", *output), &optimizationSummary{
+		Codebase:      codebase.Name,
+		Dendrogram:    dendrogram.Name,
+		Decomposition: decomposition.Name,
+		InitialCost:   result.InitialCost,
+		FinalCost:     result.FinalCost,
+		Iterations:    result.Iterations,
+		AcceptedMoves: result.AcceptedMoves,
+		Diff:          result.Diff,
+	})
+}
+
+func getDecomposition(codebase *files.Codebase, dendrogramName string, cutValue float32, useExpert bool) (*files.Dendogram, *files.Decomposition) {
+	for _, dendrogram := range codebase.Dendrograms {
+		if dendrogramName != "" && dendrogram.Name != dendrogramName {
+			continue
+		}
+		return dendrogram, dendrogram.GetDecomposition(cutValue, useExpert)
+	}
+	return nil, nil
+}
@@ -0,0 +1,111 @@
+// Command whatif reports how the best saga redesign of each functionality that accesses an entity
+// changes when the entity is moved to another cluster of the decomposition. Like the main command
+// it is run from the cmd folder, since the codebases are read relative to it:
+//
+//	go run ./whatif -codebase ldod-static -expert -entity VirtualEdition -cluster 2
+package main
+
+import (
+	"automation/app/common/log"
+	"automation/app/configuration"
+	"automation/app/files"
+	"automation/app/metrics"
+	"automation/app/redesign"
+	"automation/app/simulation"
+	"automation/app/training"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func main() {
+	codebaseName := flag.String("codebase", "ldod-static", "name of the codebase folder")
+	cutValue := flag.Float64("cut", 0, "cut value of the decomposition")
+	useExpert := flag.Bool("expert", false, "use the expert decomposition")
+	dendrogramName := flag.String("dendrogram", "", "name of the dendrogram, the first one by default")
+	entity := flag.String("entity", "", "name or id of the entity to move")
+	clusterName := flag.String("cluster", "", "name of the cluster the entity is moved to")
+	output := flag.String("output", "", "name of the .csv file to generate with the results")
+	flag.Parse()
+
+	if *entity == "" || *clusterName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := log.NewLogger()
+	filesHandler := files.New(logger)
+	execution := configuration.Execution{Configuration: &configuration.Configuration{}}
+	redesignHandler := redesign.New(logger, metrics.New(logger), training.New(logger), simulation.New(logger), execution)
+
+	codebase, err := filesHandler.ReadCodebase(*codebaseName)
+	if err != nil {
+		logger.Log("Failed to decode codebase %s | %s", *codebaseName, err.Error())
+		os.Exit(1)
+	}
+
+	idToEntityMap, err := filesHandler.ReadIDToEntityFile(*codebaseName)
+	if err != nil {
+		logger.Log("Failed to decode id_to_entity map %s | %s", *codebaseName, err.Error())
+		os.Exit(1)
+	}
+
+	decomposition := getDecomposition(codebase, *dendrogramName, float32(*cutValue), *useExpert)
+	if decomposition == nil {
+		logger.Log("Failed to get decomposition from codebase %s", *codebaseName)
+		os.Exit(1)
+	}
+
+	entityID, found := getEntityID(*entity, idToEntityMap)
+	if !found {
+		logger.Log("Failed to find entity %s", *entity)
+		os.Exit(1)
+	}
+
+	report, err := redesignHandler.EvaluateRelocation(decomposition, entityID, *clusterName)
+	if err != nil {
+		logger.Log("Failed to relocate entity %s | %s", *entity, err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nMoving %v from cluster %v to cluster %v of %v\n\n", idToEntityMap[strconv.Itoa(entityID)], report.FromCluster, report.ToCluster, report.Decomposition)
+	for _, result := range report.Functionalities {
+		fmt.Printf(
+			"%v\n\tSystem Complexity: %v -> %v\n\tFunctionality Complexity: %v -> %v\n\tOrchestrator: %v -> %v\n",
+			result.Functionality,
+			result.InitialSystemComplexity, result.RelocatedSystemComplexity,
+			result.InitialFunctionalityComplexity, result.RelocatedFunctionalityComplexity,
+			result.InitialOrchestratorID, result.RelocatedOrchestratorID,
+		)
+	}
+
+	if *output != "" {
+		filesHandler.GenerateCSV(*output, report.Dataset(idToEntityMap))
+	}
+}
+
+func getDecomposition(codebase *files.Codebase, dendrogramName string, cutValue float32, useExpert bool) *files.Decomposition {
+	for _, dendrogram := range codebase.Dendrograms {
+		if dendrogramName != "" && dendrogram.Name != dendrogramName {
+			continue
+		}
+		return dendrogram.GetDecomposition(cutValue, useExpert)
+	}
+	return nil
+}
+
+func getEntityID(entity string, idToEntityMap map[string]string) (int, bool) {
+	if _, found := idToEntityMap[entity]; found {
+		entityID, _ := strconv.Atoi(entity)
+		return entityID, true
+	}
+
+	for id, name := range idToEntityMap {
+		if name == entity {
+			entityID, _ := strconv.Atoi(id)
+			return entityID, true
+		}
+	}
+	return 0, false
+}
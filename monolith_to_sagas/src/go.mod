@@ -5,4 +5,5 @@ go 1.14
 require (
 	github.com/go-kit/kit v0.11.0
 	github.com/stretchr/testify v1.7.0
+	github.com/xeipuuv/gojsonschema v1.2.0
 )
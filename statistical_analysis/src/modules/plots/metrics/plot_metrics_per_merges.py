@@ -36,6 +36,8 @@ CSV_ROWS = [
     "CIOF",
     "SCCP",
     "FCCP",
+    "CDD",
+    "CDDF",
 ]
 
 ADAPTED_CSV_ROWS = [
@@ -54,6 +56,8 @@ ADAPTED_CSV_ROWS = [
     "SCCP",
     "FCCP",
     "Orchestrator",
+    "CDD",
+    "CDDF",
 ]
 
 #PLOT_SPECIFIC_CODEBASE = "ldod-static"
@@ -7,7 +7,7 @@ from matplotlib import pyplot as plt
 plt.style.use('ggplot')
 
 CSV_FILE = "../../output/all-metrics-2021-05-04-22-15-56.csv"
-CSV_ROWS = ["Codebase", "Feature", "Cluster", "CLIP", "CRIP", "CROP", "CWOP", "CIP", "CDDIP", "COP", "CPIF", "CIOF", "SCCP", "FCCP", "Orchestrator"]
+CSV_ROWS = ["Codebase", "Feature", "Cluster", "CLIP", "CRIP", "CROP", "CWOP", "CIP", "CDDIP", "COP", "CPIF", "CIOF", "SCCP", "FCCP", "Orchestrator", "CDD", "CDDF"]
 PLOT_METRICS_INDIVIDUALLY = False
 
 dataset = pd.read_csv(CSV_FILE, names=CSV_ROWS, skiprows=1)
@@ -37,6 +37,8 @@ CSV_ROWS = [
     "CIOF",
     "SCCP",
     "FCCP", 
+    "CDD",
+    "CDDF",
 ]
 
 ADAPTED_CSV_ROWS = [
@@ -55,6 +57,8 @@ ADAPTED_CSV_ROWS = [
     "SCCP",
     "FCCP", 
     "Orchestrator",
+    "CDD",
+    "CDDF",
 ]
 
 #PLOT_SPECIFIC_CODEBASE = "ldod-static"